@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AtomicOptions 原子写文件的选项,零值使用默认值
+type AtomicOptions struct {
+	// Perm 文件权限,为0时沿用已有文件的权限,文件不存在时为0644
+	Perm os.FileMode
+	// DirPerm 创建上层目录使用的权限,默认0755
+	DirPerm os.FileMode
+	// PreserveOwner 保留已有文件的属主和属组,只在类Unix系统上有效,改为其他用户通常需要root权限
+	PreserveOwner bool
+}
+
+// AtomicFile 原子写入的文件:数据先写入同一目录下的临时文件,Commit时fsync后重命名为目标文件并fsync目录,
+// 因此其他进程只会看到旧文件或完整的新文件,写入过程中崩溃也不会留下截断的文件。
+//
+// 用法:
+//
+//	f, err := NewAtomicFile(name, AtomicOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	if _, err = io.Copy(f, r); err != nil {
+//		return err
+//	}
+//	return f.Commit()
+type AtomicFile struct {
+	f    *os.File
+	name string
+	done bool
+}
+
+// NewAtomicFile 在filename所在目录创建临时文件,目录不存在时一并创建;
+// filename是符号链接时替换链接指向的文件。
+func NewAtomicFile(filename string, opts AtomicOptions) (*AtomicFile, error) {
+	if real, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = real
+	}
+	dir := filepath.Dir(filename)
+	dirPerm := opts.DirPerm
+	if dirPerm == 0 {
+		dirPerm = 0755
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, err
+	}
+
+	perm := opts.Perm
+	fi, err := os.Stat(filename)
+	switch {
+	case err == nil:
+		if fi.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: errors.New("is a directory")}
+		}
+		if perm == 0 {
+			perm = fi.Mode().Perm()
+		}
+	case os.IsNotExist(err):
+		fi = nil
+	default:
+		return nil, err
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	a := &AtomicFile{f: f, name: filename}
+	// 临时文件创建时为0600,在写入数据前设置最终的权限和属主
+	if err = f.Chmod(perm); err == nil && opts.PreserveOwner && fi != nil {
+		err = chownLike(f, fi)
+	}
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Name 目标文件名
+func (a *AtomicFile) Name() string {
+	return a.name
+}
+
+// Write 写入临时文件
+func (a *AtomicFile) Write(p []byte) (int, error) {
+	return a.f.Write(p)
+}
+
+// WriteString 写入临时文件
+func (a *AtomicFile) WriteString(s string) (int, error) {
+	return a.f.WriteString(s)
+}
+
+// Commit fsync临时文件,重命名为目标文件,再fsync所在目录使重命名持久化;失败时删除临时文件,目标文件保持不变
+func (a *AtomicFile) Commit() error {
+	if a.done {
+		return os.ErrClosed
+	}
+	a.done = true
+	err := a.f.Sync()
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(a.f.Name(), a.name)
+	}
+	if err != nil {
+		os.Remove(a.f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(a.name))
+}
+
+// Close 放弃未提交的写入并删除临时文件,Commit之后调用没有作用,可以放在defer中
+func (a *AtomicFile) Close() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+	err := a.f.Close()
+	if rerr := os.Remove(a.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// WriteFileAtomic 原子地将data写入filename,见AtomicFile
+func WriteFileAtomic(filename string, data []byte, opts AtomicOptions) error {
+	a, err := NewAtomicFile(filename, opts)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	if _, err = a.Write(data); err != nil {
+		return err
+	}
+	return a.Commit()
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package utils
+
+import (
+	"os"
+)
+
+// chownLike 其他平台不支持修改属主
+func chownLike(f *os.File, fi os.FileInfo) error {
+	return nil
+}
+
+// syncDir 其他平台(如Windows)无法fsync目录,重命名由文件系统保证
+func syncDir(dir string) error {
+	return nil
+}
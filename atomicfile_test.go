@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// tempFiles 返回目录中残留的临时文件
+func tempFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a", "b", "config.json")
+	if err := WriteFile(file, []byte("v1")); err != nil {
+		t.Fatalf("WriteFile:\n Expect => nil\n Got => %v\n", err)
+	}
+	fi, _ := os.Stat(file)
+	if data, _ := ioutil.ReadFile(file); string(data) != "v1" || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0644) {
+		t.Errorf("WriteFile:\n Expect => v1 0644\n Got => %s %v\n", data, fi.Mode())
+	}
+
+	// 覆盖时保留已有文件的权限,指定Perm时使用指定的权限
+	os.Chmod(file, 0600)
+	if err := WriteFile(file, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ = os.Stat(file); runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("WriteFile(keep mode):\n Expect => 0600\n Got => %v\n", fi.Mode())
+	}
+	if err := WriteFileAtomic(file, []byte("v3"), AtomicOptions{Perm: 0640, PreserveOwner: true}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ = os.Stat(file); runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+		t.Errorf("WriteFileAtomic(perm):\n Expect => 0640\n Got => %v\n", fi.Mode())
+	}
+	if files := tempFiles(t, filepath.Dir(file)); len(files) != 0 {
+		t.Errorf("WriteFileAtomic:\n Expect => no temp files\n Got => %v\n", files)
+	}
+
+	// 上层路径是文件时返回MkdirAll的错误
+	if err := WriteFile(filepath.Join(file, "x"), []byte("x")); err == nil {
+		t.Errorf("WriteFile(parent is file):\n Expect => error\n Got => nil\n")
+	}
+	if err := WriteFile(dir, []byte("x")); err == nil {
+		t.Errorf("WriteFile(dir):\n Expect => error\n Got => nil\n")
+	}
+}
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data")
+	ioutil.WriteFile(file, []byte("old"), 0644)
+
+	// 未提交的写入不影响目标文件
+	f, err := NewAtomicFile(file, AtomicOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("partial")
+	if data, _ := ioutil.ReadFile(file); string(data) != "old" {
+		t.Errorf("AtomicFile(before commit):\n Expect => old\n Got => %s\n", data)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != "old" || len(tempFiles(t, dir)) != 0 {
+		t.Errorf("AtomicFile(abort):\n Expect => old and no temp files\n Got => %s %v\n", data, tempFiles(t, dir))
+	}
+
+	f, _ = NewAtomicFile(file, AtomicOptions{})
+	f.Write([]byte("new"))
+	if err = f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Errorf("AtomicFile.Close(after commit):\n Expect => nil\n Got => %v\n", err)
+	}
+	if err = f.Commit(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("AtomicFile.Commit(twice):\n Expect => %v\n Got => %v\n", os.ErrClosed, err)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != "new" {
+		t.Errorf("AtomicFile(commit):\n Expect => new\n Got => %s\n", data)
+	}
+
+	// 符号链接保持不变,写入链接指向的文件
+	if runtime.GOOS != "windows" {
+		link := filepath.Join(dir, "link")
+		os.Symlink(file, link)
+		if err = WriteFile(link, []byte("via link")); err != nil {
+			t.Fatal(err)
+		}
+		fi, _ := os.Lstat(link)
+		if data, _ := ioutil.ReadFile(file); string(data) != "via link" || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("WriteFile(symlink):\n Expect => via link, link kept\n Got => %s %v\n", data, fi.Mode())
+		}
+	}
+}
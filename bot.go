@@ -0,0 +1,436 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// BotCategory 爬虫/自动化客户端的分类
+type BotCategory int
+
+const (
+	BotNone            BotCategory = iota // 不是爬虫
+	BotSearchEngine                       // 搜索引擎
+	BotSocialPreview                      // 社交平台链接预览
+	BotMonitoring                         // 监控、可用性探测
+	BotSEOTool                            // SEO工具
+	BotAICrawler                          // AI数据采集
+	BotHTTPLibrary                        // HTTP库、命令行工具
+	BotHeadlessBrowser                    // 无头浏览器
+	BotGeneric                            // 无法细分的其他爬虫
+)
+
+var botCategoryNames = [...]string{
+	BotNone:            "none",
+	BotSearchEngine:    "search-engine",
+	BotSocialPreview:   "social-preview",
+	BotMonitoring:      "monitoring",
+	BotSEOTool:         "seo-tool",
+	BotAICrawler:       "ai-crawler",
+	BotHTTPLibrary:     "http-library",
+	BotHeadlessBrowser: "headless-browser",
+	BotGeneric:         "generic",
+}
+
+func (c BotCategory) String() string {
+	if c >= 0 && int(c) < len(botCategoryNames) {
+		return botCategoryNames[c]
+	}
+	return "unknown"
+}
+
+// MarshalText 序列化为分类名称
+func (c BotCategory) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText 从分类名称解析,未知名称返回错误
+func (c *BotCategory) UnmarshalText(text []byte) error {
+	for i, name := range botCategoryNames {
+		if name == string(text) {
+			*c = BotCategory(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown bot category: %s", text)
+}
+
+// BotInfo 识别出的爬虫信息
+type BotInfo struct {
+	Name     string      `json:"name"`
+	Version  string      `json:"version,omitempty"`
+	Category BotCategory `json:"category"`
+}
+
+// IsBot 是否识别为爬虫
+func (b BotInfo) IsBot() bool {
+	return b.Category != BotNone
+}
+
+// botSignature 通过User-Agent中的特征串(不区分大小写)识别爬虫,
+// 靠前的规则优先匹配,所以更具体的特征需要放在前面。
+type botSignature struct {
+	token    string
+	name     string
+	category BotCategory
+}
+
+var botSignatures = []botSignature{
+	// AI crawlers
+	{"gptbot", "GPTBot", BotAICrawler},
+	{"chatgpt-user", "ChatGPT-User", BotAICrawler},
+	{"oai-searchbot", "OAI-SearchBot", BotAICrawler},
+	{"claudebot", "ClaudeBot", BotAICrawler},
+	{"claude-web", "Claude-Web", BotAICrawler},
+	{"anthropic-ai", "anthropic-ai", BotAICrawler},
+	{"ccbot", "CCBot", BotAICrawler},
+	{"perplexitybot", "PerplexityBot", BotAICrawler},
+	{"bytespider", "Bytespider", BotAICrawler},
+	{"google-extended", "Google-Extended", BotAICrawler},
+	{"applebot-extended", "Applebot-Extended", BotAICrawler},
+	{"cohere-ai", "cohere-ai", BotAICrawler},
+	{"diffbot", "Diffbot", BotAICrawler},
+	{"amazonbot", "Amazonbot", BotAICrawler},
+
+	// Social previews
+	{"facebookexternalhit", "facebookexternalhit", BotSocialPreview},
+	{"facebookcatalog", "facebookcatalog", BotSocialPreview},
+	{"facebot", "Facebot", BotSocialPreview},
+	{"twitterbot", "Twitterbot", BotSocialPreview},
+	{"linkedinbot", "LinkedInBot", BotSocialPreview},
+	{"slackbot", "Slackbot", BotSocialPreview},
+	{"slack-imgproxy", "Slack-ImgProxy", BotSocialPreview},
+	{"discordbot", "Discordbot", BotSocialPreview},
+	{"telegrambot", "TelegramBot", BotSocialPreview},
+	{"whatsapp", "WhatsApp", BotSocialPreview},
+	{"skypeuripreview", "SkypeUriPreview", BotSocialPreview},
+	{"pinterestbot", "Pinterestbot", BotSocialPreview},
+	{"redditbot", "redditbot", BotSocialPreview},
+	{"embedly", "Embedly", BotSocialPreview},
+	{"vkshare", "vkShare", BotSocialPreview},
+
+	// Monitoring
+	{"uptimerobot", "UptimeRobot", BotMonitoring},
+	{"pingdom", "Pingdom", BotMonitoring},
+	{"statuscake", "StatusCake", BotMonitoring},
+	{"site24x7", "Site24x7", BotMonitoring},
+	{"newrelicpinger", "NewRelicPinger", BotMonitoring},
+	{"datadog", "Datadog", BotMonitoring},
+	{"better uptime", "Better Uptime", BotMonitoring},
+	{"betteruptime", "Better Uptime", BotMonitoring},
+	{"checkly", "Checkly", BotMonitoring},
+	{"freshping", "Freshping", BotMonitoring},
+	{"kube-probe", "kube-probe", BotMonitoring},
+	{"elb-healthchecker", "ELB-HealthChecker", BotMonitoring},
+	{"googlehc", "GoogleHC", BotMonitoring},
+	{"prometheus", "Prometheus", BotMonitoring},
+	{"blackbox-exporter", "blackbox-exporter", BotMonitoring},
+	{"zabbix", "Zabbix", BotMonitoring},
+	{"nagios", "Nagios", BotMonitoring},
+	{"consul health check", "Consul", BotMonitoring},
+
+	// SEO tools
+	{"ahrefsbot", "AhrefsBot", BotSEOTool},
+	{"ahrefssiteaudit", "AhrefsSiteAudit", BotSEOTool},
+	{"semrushbot", "SemrushBot", BotSEOTool},
+	{"mj12bot", "MJ12bot", BotSEOTool},
+	{"dotbot", "DotBot", BotSEOTool},
+	{"rogerbot", "rogerbot", BotSEOTool},
+	{"screaming frog", "Screaming Frog SEO Spider", BotSEOTool},
+	{"blexbot", "BLEXBot", BotSEOTool},
+	{"serpstatbot", "serpstatbot", BotSEOTool},
+	{"dataforseobot", "DataForSeoBot", BotSEOTool},
+	{"seokicks", "SEOkicks", BotSEOTool},
+	{"sitebulb", "Sitebulb", BotSEOTool},
+
+	// Search engines
+	{"google-inspectiontool", "Google-InspectionTool", BotSearchEngine},
+	{"adsbot-google", "AdsBot-Google", BotSearchEngine},
+	{"mediapartners-google", "Mediapartners-Google", BotSearchEngine},
+	{"googlebot", "Googlebot", BotSearchEngine},
+	{"storebot-google", "Storebot-Google", BotSearchEngine},
+	{"bingbot", "bingbot", BotSearchEngine},
+	{"bingpreview", "BingPreview", BotSearchEngine},
+	{"adidxbot", "adidxbot", BotSearchEngine},
+	{"msnbot", "msnbot", BotSearchEngine},
+	{"baiduspider", "Baiduspider", BotSearchEngine},
+	{"yandex", "Yandex", BotSearchEngine},
+	{"duckduckbot", "DuckDuckBot", BotSearchEngine},
+	{"yahoo! slurp", "Yahoo! Slurp", BotSearchEngine},
+	{"sogou web spider", "Sogou web spider", BotSearchEngine},
+	{"360spider", "360Spider", BotSearchEngine},
+	{"yisouspider", "YisouSpider", BotSearchEngine},
+	{"petalbot", "PetalBot", BotSearchEngine},
+	{"applebot", "Applebot", BotSearchEngine},
+	{"seznambot", "SeznamBot", BotSearchEngine},
+	{"naverbot", "NaverBot", BotSearchEngine},
+	{"yeti", "Yeti", BotSearchEngine},
+	{"exabot", "Exabot", BotSearchEngine},
+	{"qwantify", "Qwantify", BotSearchEngine},
+
+	// Headless browsers
+	{"headlesschrome", "HeadlessChrome", BotHeadlessBrowser},
+	{"phantomjs", "PhantomJS", BotHeadlessBrowser},
+	{"puppeteer", "Puppeteer", BotHeadlessBrowser},
+	{"playwright", "Playwright", BotHeadlessBrowser},
+	{"selenium", "Selenium", BotHeadlessBrowser},
+	{"slimerjs", "SlimerJS", BotHeadlessBrowser},
+
+	// HTTP libraries and command line tools
+	{"curl/", "curl", BotHTTPLibrary},
+	{"wget/", "Wget", BotHTTPLibrary},
+	{"python-requests", "python-requests", BotHTTPLibrary},
+	{"python-urllib", "Python-urllib", BotHTTPLibrary},
+	{"python-httpx", "python-httpx", BotHTTPLibrary},
+	{"aiohttp", "aiohttp", BotHTTPLibrary},
+	{"scrapy", "Scrapy", BotHTTPLibrary},
+	{"go-http-client", "Go-http-client", BotHTTPLibrary},
+	{"okhttp", "okhttp", BotHTTPLibrary},
+	{"apache-httpclient", "Apache-HttpClient", BotHTTPLibrary},
+	{"java/", "Java", BotHTTPLibrary},
+	{"libwww-perl", "libwww-perl", BotHTTPLibrary},
+	{"node-fetch", "node-fetch", BotHTTPLibrary},
+	{"axios/", "axios", BotHTTPLibrary},
+	{"undici", "undici", BotHTTPLibrary},
+	{"got (https://github.com/sindresorhus/got)", "got", BotHTTPLibrary},
+	{"guzzlehttp", "GuzzleHttp", BotHTTPLibrary},
+	{"httpie", "HTTPie", BotHTTPLibrary},
+	{"postmanruntime", "PostmanRuntime", BotHTTPLibrary},
+	{"insomnia", "insomnia", BotHTTPLibrary},
+	{"restsharp", "RestSharp", BotHTTPLibrary},
+	{"reqwest", "reqwest", BotHTTPLibrary},
+	{"dart:io", "Dart", BotHTTPLibrary},
+}
+
+// DetectBot 根据User-Agent识别爬虫及其分类,未识别时ok为false。
+//
+// 先匹配已知的特征列表,再回退到通用的bot/crawler/spider关键字以及注释中的网址。
+func DetectBot(ua string) (info BotInfo, ok bool) {
+	if ua == "" {
+		return
+	}
+	lower := strings.ToLower(ua)
+	if n, idx := matchBotSignature(lower); n >= 0 {
+		s := botSignatures[n]
+		return BotInfo{
+			Name:     s.name,
+			Version:  botVersion(ua, idx+len(strings.TrimSuffix(s.token, "/"))),
+			Category: s.category,
+		}, true
+	}
+	var loc []int
+	if len(lower) == len(ua) {
+		loc = botKeywordIndex(lower)
+	} else {
+		loc = botRegex.FindStringIndex(ua)
+	}
+	if loc != nil || hasSiteURL(lower) {
+		info.Category = BotGeneric
+		info.Name, info.Version = botProduct(ua, loc)
+		return info, true
+	}
+	return
+}
+
+// botKeywords 与botRegex等价的关键字,用字符串查找代替正则以减少开销
+var botKeywords = []string{"bot", "crawler", "spider", "spyder", "search", "worm", "fetch", "nutch"}
+
+// botKeywordIndex 返回lower中最先出现的爬虫关键字的位置,未找到时返回nil。
+func botKeywordIndex(lower string) []int {
+	var loc []int
+	for _, k := range botKeywords {
+		if i := strings.Index(lower, k); i >= 0 && (loc == nil || i < loc[0]) {
+			loc = []int{i, i + len(k)}
+		}
+	}
+	return loc
+}
+
+// hasSiteURL 判断是否包含形如"http(s)://host.tld"的网址,等价于botFromSiteRegexp。
+func hasSiteURL(lower string) bool {
+	for i := strings.Index(lower, "://"); i >= 0; {
+		if strings.HasSuffix(lower[:i], "http") || strings.HasSuffix(lower[:i], "https") {
+			rest := lower[i+3:]
+			if j := strings.LastIndexByte(rest, '.'); j > 0 && j+1 < len(rest) && isWordChar(rest[j+1]) {
+				return true
+			}
+		}
+		next := strings.Index(lower[i+3:], "://")
+		if next < 0 {
+			break
+		}
+		i += 3 + next
+	}
+	return false
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// botSignatureIndex 按特征串首字节对botSignatures分组,只需扫描一遍User-Agent
+var botSignatureIndex = func() (index [256][]int) {
+	for i, s := range botSignatures {
+		index[s.token[0]] = append(index[s.token[0]], i)
+	}
+	return
+}()
+
+// matchBotSignature 返回lower中命中的、在botSignatures中最靠前的特征序号及其位置,
+// 未命中时序号为-1。
+func matchBotSignature(lower string) (n, idx int) {
+	n, idx = -1, -1
+	for i := 0; i < len(lower); i++ {
+		for _, k := range botSignatureIndex[lower[i]] {
+			if (n < 0 || k < n) && strings.HasPrefix(lower[i:], botSignatures[k].token) {
+				n, idx = k, i
+			}
+		}
+	}
+	return
+}
+
+// botVersion 读取特征串之后紧跟的"/版本号"。
+func botVersion(ua string, end int) string {
+	if end >= len(ua) || ua[end] != '/' {
+		return ""
+	}
+	i := end + 1
+	for ; i < len(ua); i++ {
+		if c := ua[i]; c == ' ' || c == ';' || c == ')' || c == '(' || c == ',' {
+			break
+		}
+	}
+	return ua[end+1 : i]
+}
+
+// botProduct 提取通用匹配命中的产品名和版本,优先使用命中关键字所在的"名称/版本"片段。
+func botProduct(ua string, loc []int) (string, string) {
+	if loc == nil {
+		return parseProduct(readUntil(ua, new(int), ' ', false))
+	}
+	start := loc[0]
+	for start > 0 && !strings.ContainsRune(" ;(+,", rune(ua[start-1])) {
+		start--
+	}
+	end := loc[1]
+	for end < len(ua) && !strings.ContainsRune(" ;)(,", rune(ua[end])) {
+		end++
+	}
+	return parseProduct(ua[start:end])
+}
+
+// BotResolver 验证爬虫时使用的DNS解析器,*net.Resolver满足该接口,测试时可替换为离线实现。
+type BotResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// BotVerifier 通过反向DNS+正向确认(FCrDNS)验证自称搜索引擎的请求是否真实。
+type BotVerifier struct {
+	// Resolver 为nil时使用net.DefaultResolver
+	Resolver BotResolver
+	// Domains 爬虫名称对应的合法反向解析域名后缀
+	Domains map[string][]string
+	// IPResolver VerifyRequest解析客户端IP的可信代理解析器,为nil时使用RemoteAddr;
+	// 部署在代理之后时必须设置,否则验证的是代理的地址
+	IPResolver *IPResolver
+}
+
+// DefaultBotDomains 各搜索引擎官方公布的反向解析域名
+var DefaultBotDomains = map[string][]string{
+	"Googlebot":             {"googlebot.com", "google.com"},
+	"Google-InspectionTool": {"googlebot.com", "google.com"},
+	"AdsBot-Google":         {"googlebot.com", "google.com"},
+	"Mediapartners-Google":  {"googlebot.com", "google.com"},
+	"Storebot-Google":       {"googlebot.com", "google.com"},
+	"bingbot":               {"search.msn.com"},
+	"BingPreview":           {"search.msn.com"},
+	"adidxbot":              {"search.msn.com"},
+	"msnbot":                {"search.msn.com"},
+	"Baiduspider":           {"baidu.com", "baidu.jp"},
+	"Yandex":                {"yandex.ru", "yandex.net", "yandex.com"},
+	"DuckDuckBot":           {"duckduckgo.com"},
+	"Applebot":              {"applebot.apple.com"},
+	"PetalBot":              {"petalsearch.com"},
+	"Sogou web spider":      {"sogou.com"},
+}
+
+// NewBotVerifier 使用给定解析器和默认域名表创建验证器
+func NewBotVerifier(r BotResolver) *BotVerifier {
+	return &BotVerifier{Resolver: r, Domains: DefaultBotDomains}
+}
+
+// Verifiable 判断该爬虫是否有可用于验证的域名
+func (v *BotVerifier) Verifiable(info BotInfo) bool {
+	return len(v.Domains[info.Name]) > 0
+}
+
+// Verify 验证ip是否属于info声明的爬虫:ip的反向解析结果需落在允许的域名下,
+// 且该主机名的正向解析结果包含ip。不可验证的爬虫或ip格式错误时返回false。
+func (v *BotVerifier) Verify(ctx context.Context, info BotInfo, ip string) (bool, error) {
+	domains := v.Domains[info.Name]
+	addr := net.ParseIP(ip)
+	if len(domains) == 0 || addr == nil {
+		return false, nil
+	}
+	resolver := v.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	hosts, err := resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.ToLower(host), ".")
+		if !hasDomainSuffix(host, domains) {
+			continue
+		}
+		ips, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		for _, a := range ips {
+			if a.IP.Equal(addr) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// VerifyRequest 识别请求中的爬虫并验证客户端IP。
+// 客户端IP由IPResolver解析,未设置时使用RemoteAddr,不读取X-Real-IP等可被伪造的请求头
+func (v *BotVerifier) VerifyRequest(r *http.Request) (info BotInfo, verified bool, err error) {
+	info, ok := DetectBot(r.UserAgent())
+	if !ok {
+		return info, false, nil
+	}
+	verified, err = v.Verify(r.Context(), info, requestIP(r, v.IPResolver))
+	return info, verified, err
+}
+
+func hasDomainSuffix(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	var de *net.DNSError
+	return errors.As(err, &de) && de.IsNotFound
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+var botStrings = []struct {
+	ua       string
+	name     string
+	version  string
+	category BotCategory
+}{
+	{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Googlebot", "2.1", BotSearchEngine},
+	{"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; bingbot/2.0; +https://www.bing.com/bingbot.htm) Chrome/116.0.1938.76 Safari/537.36", "bingbot", "2.0", BotSearchEngine},
+	{"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)", "Baiduspider", "2.0", BotSearchEngine},
+	{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", "facebookexternalhit", "1.1", BotSocialPreview},
+	{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "Slackbot", "", BotSocialPreview},
+	{"Mozilla/5.0 (compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", "UptimeRobot", "2.0", BotMonitoring},
+	{"kube-probe/1.27", "kube-probe", "1.27", BotMonitoring},
+	{"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)", "AhrefsBot", "7.0", BotSEOTool},
+	{"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.0; +https://openai.com/gptbot)", "GPTBot", "1.0", BotAICrawler},
+	{"CCBot/2.0 (https://commoncrawl.org/faq/)", "CCBot", "2.0", BotAICrawler},
+	{"curl/8.4.0", "curl", "8.4.0", BotHTTPLibrary},
+	{"python-requests/2.31.0", "python-requests", "2.31.0", BotHTTPLibrary},
+	{"Go-http-client/1.1", "Go-http-client", "1.1", BotHTTPLibrary},
+	{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/119.0.6045.105 Safari/537.36", "HeadlessChrome", "119.0.6045.105", BotHeadlessBrowser},
+	{"Mozilla/5.0 (compatible; FooCrawler/3.1; +https://foo.example.com/crawler)", "FooCrawler", "3.1", BotGeneric},
+	{"SomeAgent/1.0 (+https://agent.example.org)", "SomeAgent", "1.0", BotGeneric},
+}
+
+func TestDetectBot(t *testing.T) {
+	for _, tt := range botStrings {
+		info, ok := DetectBot(tt.ua)
+		if !ok || info.Name != tt.name || info.Version != tt.version || info.Category != tt.category {
+			t.Errorf("DetectBot(%q):\n Expect => %s %s %s\n Got => %s %s %s\n", tt.ua, tt.name, tt.version, tt.category, info.Name, info.Version, info.Category)
+		}
+		ua := New(tt.ua)
+		if !ua.Bot() || ua.BotInfo().Category != tt.category {
+			t.Errorf("UserAgent(%q).BotInfo:\n Expect => %s\n Got => %v %s\n", tt.ua, tt.category, ua.Bot(), ua.BotInfo().Category)
+		}
+	}
+
+	humans := []string{
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/55.0.2883.75 Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 7_0_3 like Mac OS X) AppleWebKit/537.51.1 (KHTML, like Gecko) Version/7.0 Mobile/11B511 Safari/9537.53",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/119.0",
+		"",
+	}
+	for _, h := range humans {
+		if info, ok := DetectBot(h); ok {
+			t.Errorf("DetectBot(%q):\n Expect => %v\n Got => %v %s\n", h, false, ok, info.Name)
+		}
+	}
+}
+
+type fakeResolver struct {
+	addr map[string][]string
+	host map[string][]string
+}
+
+func (f *fakeResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	if names, ok := f.addr[addr]; ok {
+		return names, nil
+	}
+	// 解析器可能包装*net.DNSError
+	return nil, fmt.Errorf("fake resolver: %w", &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true})
+}
+
+func (f *fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := f.host[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	res := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		res = append(res, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return res, nil
+}
+
+func TestBotVerifier(t *testing.T) {
+	v := NewBotVerifier(&fakeResolver{
+		addr: map[string][]string{
+			"66.249.66.1":  {"crawl-66-249-66-1.googlebot.com."},
+			"157.55.39.1":  {"msnbot-157-55-39-1.search.msn.com."},
+			"203.0.113.9":  {"crawl-fake.googlebot.com.evil.example."},
+			"198.51.100.7": {"crawl-198-51-100-7.googlebot.com."},
+			"34.1.2.3":     {"3.2.1.34.bc.googleusercontent.com."},
+		},
+		host: map[string][]string{
+			"crawl-66-249-66-1.googlebot.com":       {"66.249.66.1"},
+			"msnbot-157-55-39-1.search.msn.com":     {"157.55.39.1"},
+			"crawl-198-51-100-7.googlebot.com":      {"66.249.66.2"},
+			"crawl-fake.googlebot.com.evil.example": {"203.0.113.9"},
+			"3.2.1.34.bc.googleusercontent.com":     {"34.1.2.3"},
+		},
+	})
+	googlebot := BotInfo{Name: "Googlebot", Category: BotSearchEngine}
+	cases := []struct {
+		info BotInfo
+		ip   string
+		ok   bool
+	}{
+		{googlebot, "66.249.66.1", true},
+		{BotInfo{Name: "bingbot", Category: BotSearchEngine}, "157.55.39.1", true},
+		{googlebot, "203.0.113.9", false},
+		{googlebot, "198.51.100.7", false},
+		{googlebot, "192.0.2.1", false},
+		// 任何GCP虚拟机都可以设置googleusercontent.com下的反向解析
+		{googlebot, "34.1.2.3", false},
+		{googlebot, "not-an-ip", false},
+		{BotInfo{Name: "curl", Category: BotHTTPLibrary}, "66.249.66.1", false},
+	}
+	for _, c := range cases {
+		ok, err := v.Verify(context.Background(), c.info, c.ip)
+		if err != nil || ok != c.ok {
+			t.Errorf("Verify(%s, %s):\n Expect => %v\n Got => %v %v\n", c.info.Name, c.ip, c.ok, ok, err)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "66.249.66.1:41234"
+	r.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	info, ok, err := v.VerifyRequest(r)
+	if err != nil || !ok || info.Name != "Googlebot" {
+		t.Errorf("VerifyRequest:\n Expect => %v\n Got => %v %v %v\n", true, info.Name, ok, err)
+	}
+
+	// 伪造的X-Real-IP、X-Forwarded-For指向真实的Googlebot地址,不能通过验证
+	r.RemoteAddr = "203.0.113.50:41234"
+	r.Header.Set("X-Real-IP", "66.249.66.1")
+	r.Header.Set("X-Forwarded-For", "66.249.66.1")
+	if _, ok, err = v.VerifyRequest(r); err != nil || ok {
+		t.Errorf("VerifyRequest(spoofed):\n Expect => %v\n Got => %v %v\n", false, ok, err)
+	}
+
+	// 部署在代理之后时通过IPResolver读取代理链
+	v.IPResolver, _ = NewIPResolver("10.0.0.0/8")
+	r.RemoteAddr = "10.0.0.1:41234"
+	r.Header.Del("X-Real-IP")
+	if _, ok, err = v.VerifyRequest(r); err != nil || !ok {
+		t.Errorf("VerifyRequest(proxy):\n Expect => %v\n Got => %v %v\n", true, ok, err)
+	}
+	r.Header.Set("X-Forwarded-For", "66.249.66.1, 203.0.113.50")
+	if _, ok, err = v.VerifyRequest(r); err != nil || ok {
+		t.Errorf("VerifyRequest(proxy spoofed):\n Expect => %v\n Got => %v %v\n", false, ok, err)
+	}
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashAlgorithm 摘要/校验和算法
+type HashAlgorithm int
+
+const (
+	HashMD5 HashAlgorithm = iota + 1
+	HashSHA1
+	HashSHA256
+	HashSHA512
+	// HashCRC32 IEEE多项式,与zip、gzip和crc32命令相同
+	HashCRC32
+	// HashXXHash64 xxHash64,种子为0,速度快但不具备抗碰撞性,只用于检测传输错误
+	HashXXHash64
+	HashSHA224
+	HashSHA384
+	HashSM3
+)
+
+var hashNames = map[HashAlgorithm]string{
+	HashMD5:      "md5",
+	HashSHA1:     "sha1",
+	HashSHA256:   "sha256",
+	HashSHA512:   "sha512",
+	HashCRC32:    "crc32",
+	HashXXHash64: "xxhash64",
+	HashSHA224:   "sha224",
+	HashSHA384:   "sha384",
+	HashSM3:      "sm3",
+}
+
+func (h HashAlgorithm) String() string {
+	if s, ok := hashNames[h]; ok {
+		return s
+	}
+	return fmt.Sprintf("HashAlgorithm(%d)", int(h))
+}
+
+// New 创建摘要,未知算法返回nil
+func (h HashAlgorithm) New() hash.Hash {
+	switch h {
+	case HashMD5:
+		return md5.New()
+	case HashSHA1:
+		return sha1.New()
+	case HashSHA256:
+		return sha256.New()
+	case HashSHA512:
+		return sha512.New()
+	case HashCRC32:
+		return crc32.NewIEEE()
+	case HashXXHash64:
+		return xxhash.New()
+	case HashSHA224:
+		return sha256.New224()
+	case HashSHA384:
+		return sha512.New384()
+	case HashSM3:
+		return NewSM3()
+	}
+	return nil
+}
+
+// ParseHashAlgorithm 按名称查找算法,不区分大小写,"sha-256"与"sha256"等价
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	name = strings.ToLower(strings.Replace(name, "-", "", -1))
+	if name == "xxh64" || name == "xxhash" {
+		return HashXXHash64, nil
+	}
+	for h, s := range hashNames {
+		if s == name {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("checksum: unknown hash algorithm %q", name)
+}
+
+// ErrChecksumMismatch 数据的校验和与期望值不一致
+var ErrChecksumMismatch = errors.New("checksum: mismatch")
+
+// Checksum 期望的校验和,用于Copy和HttpGetToFile的完整性校验
+type Checksum struct {
+	Algorithm HashAlgorithm
+	Sum       []byte
+}
+
+// ParseChecksum 解析"算法:十六进制摘要"格式的校验和,例如"sha256:e3b0c442..."
+func ParseChecksum(s string) (Checksum, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return Checksum{}, fmt.Errorf("checksum: missing algorithm in %q", s)
+	}
+	alg, err := ParseHashAlgorithm(s[:i])
+	if err != nil {
+		return Checksum{}, err
+	}
+	sum, err := hex.DecodeString(s[i+1:])
+	if err != nil {
+		return Checksum{}, fmt.Errorf("checksum: %v", err)
+	}
+	if len(sum) != alg.New().Size() {
+		return Checksum{}, fmt.Errorf("checksum: %s digest must be %d bytes", alg, alg.New().Size())
+	}
+	return Checksum{Algorithm: alg, Sum: sum}, nil
+}
+
+// String 返回"算法:十六进制摘要"
+func (c Checksum) String() string {
+	return c.Algorithm.String() + ":" + hex.EncodeToString(c.Sum)
+}
+
+// checksumWriter 同时计算多个摘要
+type checksumWriter struct {
+	want   []Checksum
+	hashes []hash.Hash
+	io.Writer
+}
+
+func newChecksumWriter(want []Checksum) (*checksumWriter, error) {
+	cw := &checksumWriter{want: want, hashes: make([]hash.Hash, len(want))}
+	ws := make([]io.Writer, len(want))
+	for i, c := range want {
+		if cw.hashes[i] = c.Algorithm.New(); cw.hashes[i] == nil {
+			return nil, fmt.Errorf("checksum: unknown hash algorithm %s", c.Algorithm)
+		}
+		ws[i] = cw.hashes[i]
+	}
+	cw.Writer = io.MultiWriter(ws...)
+	return cw, nil
+}
+
+// verify 校验所有摘要,不一致时返回包装了ErrChecksumMismatch的错误
+func (cw *checksumWriter) verify() error {
+	for i, c := range cw.want {
+		if got := cw.hashes[i].Sum(nil); !bytes.Equal(got, c.Sum) {
+			return fmt.Errorf("%w: expected %s, got %s:%x", ErrChecksumMismatch, c, c.Algorithm, got)
+		}
+	}
+	return nil
+}
+
+// copyVerify 复制数据并校验,want为空时等同于io.Copy
+func copyVerify(dst io.Writer, src io.Reader, want []Checksum) error {
+	if len(want) == 0 {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	cw, err := newChecksumWriter(want)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(io.MultiWriter(dst, cw), src); err != nil {
+		return err
+	}
+	return cw.verify()
+}
+
+// FileChecksum 流式计算文件的摘要,返回十六进制字符串
+func FileChecksum(file string, alg HashAlgorithm) (string, error) {
+	sums, err := FileChecksums(file, alg)
+	if err != nil {
+		return "", err
+	}
+	return sums[alg], nil
+}
+
+// FileChecksums 只读取一次文件,同时计算多个摘要,返回算法到十六进制字符串的映射
+func FileChecksums(file string, algs ...HashAlgorithm) (map[HashAlgorithm]string, error) {
+	want := make([]Checksum, len(algs))
+	for i, alg := range algs {
+		want[i].Algorithm = alg
+	}
+	cw, err := newChecksumWriter(want)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err = io.Copy(cw, f); err != nil {
+		return nil, err
+	}
+	sums := make(map[HashAlgorithm]string, len(algs))
+	for i, alg := range algs {
+		sums[alg] = hex.EncodeToString(cw.hashes[i].Sum(nil))
+	}
+	return sums, nil
+}
+
+// VerifyFile 校验文件的摘要,不一致时返回包装了ErrChecksumMismatch的错误
+func VerifyFile(file string, want ...Checksum) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return copyVerify(io.Discard, f, want)
+}
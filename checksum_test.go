@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileChecksums(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data")
+	if err := ioutil.WriteFile(file, []byte("123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[HashAlgorithm]string{
+		HashMD5:      "25f9e794323b453885f5181f1b624d0b",
+		HashSHA1:     "f7c3bc1d808e04732adf679965ccc34ca7ae3441",
+		HashSHA256:   "15e2b0d3c33891ebb0f1ef609ec419420c20e320ce94c65fbc8c3312448eb225",
+		HashSHA512:   "d9e6762dd1c8eaf6d61b3c6192fc408d4d6d5f1176d0c29169bc24e71c3f274ad27fcd5811b313d681f7e55ec02d73d499c95455b6b5bb503acf574fba8ffe85",
+		HashCRC32:    "cbf43926",
+		HashXXHash64: "8cb841db40e6ae83",
+	}
+	sums, err := FileChecksums(file, HashMD5, HashSHA1, HashSHA256, HashSHA512, HashCRC32, HashXXHash64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for alg, sum := range expected {
+		if sums[alg] != sum {
+			t.Errorf("FileChecksums(%s):\n Expect => %s\n Got => %s\n", alg, sum, sums[alg])
+		}
+		if got, _ := FileChecksum(file, alg); got != sum {
+			t.Errorf("FileChecksum(%s):\n Expect => %s\n Got => %s\n", alg, sum, got)
+		}
+		c, err := ParseChecksum(strings.ToUpper(alg.String()) + ":" + sum)
+		if err != nil || c.String() != alg.String()+":"+sum {
+			t.Errorf("ParseChecksum(%s):\n Expect => %s:%s\n Got => %s %v\n", alg, alg, sum, c, err)
+		}
+		if err = VerifyFile(file, c); err != nil {
+			t.Errorf("VerifyFile(%s):\n Expect => nil\n Got => %v\n", alg, err)
+		}
+	}
+	if got, _ := HashReader(HashXXHash64, strings.NewReader("abc")); got.Hex() != "44bc2cf5ad770999" {
+		t.Errorf("HashReader:\n Expect => 44bc2cf5ad770999\n Got => %x\n", got)
+	}
+
+	bad, _ := ParseChecksum("sha-256:" + strings.Repeat("0", 64))
+	if err := VerifyFile(file, bad); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyFile(mismatch):\n Expect => %v\n Got => %v\n", ErrChecksumMismatch, err)
+	}
+	for _, s := range []string{"sha256", "sha3:00", "md5:xyz", "md5:00"} {
+		if _, err := ParseChecksum(s); err == nil {
+			t.Errorf("ParseChecksum(%q):\n Expect => error\n Got => nil\n", s)
+		}
+	}
+
+	// 复制时校验,不一致时不保留目标文件
+	good, _ := ParseChecksum("crc32:cbf43926")
+	if err := Copy(file, filepath.Join(dir, "copy"), good); err != nil || !IsFile(filepath.Join(dir, "copy")) {
+		t.Errorf("Copy(checksum):\n Expect => nil\n Got => %v\n", err)
+	}
+	if err := Copy(file, filepath.Join(dir, "bad"), good, bad); !errors.Is(err, ErrChecksumMismatch) || IsExist(filepath.Join(dir, "bad")) {
+		t.Errorf("Copy(mismatch):\n Expect => %v and no file\n Got => %v\n", ErrChecksumMismatch, err)
+	}
+}
+
+func TestHttpGetToFileChecksum(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("123456789"))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	good, _ := ParseChecksum("sha256:15e2b0d3c33891ebb0f1ef609ec419420c20e320ce94c65fbc8c3312448eb225")
+	file := filepath.Join(dir, "ok")
+	if err := HttpGetToFile(srv.Client(), srv.URL, nil, file, good); err != nil {
+		t.Fatalf("HttpGetToFile:\n Expect => nil\n Got => %v\n", err)
+	}
+	if data, _ := ioutil.ReadFile(file); string(data) != "123456789" {
+		t.Errorf("HttpGetToFile:\n Expect => 123456789\n Got => %s\n", data)
+	}
+
+	bad, _ := ParseChecksum("md5:00000000000000000000000000000000")
+	file = filepath.Join(dir, "bad")
+	if err := HttpGetToFile(srv.Client(), srv.URL, nil, file, bad); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("HttpGetToFile(mismatch):\n Expect => %v\n Got => %v\n", ErrChecksumMismatch, err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("HttpGetToFile(mismatch):\n Expect => file removed\n Got => %v\n", err)
+	}
+}
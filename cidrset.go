@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ipRange 闭区间[start, end],IPv4为4字节,IPv6为16字节
+type ipRange struct {
+	start, end net.IP
+}
+
+// CIDRSet 地址集合,内部以排序且不重叠的区间保存,IPv4和IPv6分开存放。
+//
+// 零值为空集合,可直接使用;CIDRSet不是并发安全的,只读使用时可以共享。
+type CIDRSet struct {
+	v4, v6 []ipRange
+}
+
+// NewCIDRSet 使用CIDR或单个IP创建集合
+func NewCIDRSet(cidrs ...string) (*CIDRSet, error) {
+	var v4, v6 []ipRange
+	for _, c := range cidrs {
+		n, err := parseCIDROrIP(c)
+		if err != nil {
+			return nil, err
+		}
+		if r := netRange(n); len(r.start) == net.IPv4len {
+			v4 = append(v4, r)
+		} else {
+			v6 = append(v6, r)
+		}
+	}
+	// 一次性排序合并,避免逐个加入时反复排序。
+	return &CIDRSet{v4: unionRanges(v4, nil), v6: unionRanges(v6, nil)}, nil
+}
+
+// Add 加入CIDR或单个IP
+func (s *CIDRSet) Add(cidr string) error {
+	n, err := parseCIDROrIP(cidr)
+	if err != nil {
+		return err
+	}
+	s.AddNet(n)
+	return nil
+}
+
+// AddNet 加入地址段
+func (s *CIDRSet) AddNet(n *net.IPNet) {
+	r := netRange(n)
+	if len(r.start) == net.IPv4len {
+		s.v4 = unionRanges(s.v4, []ipRange{r})
+	} else {
+		s.v6 = unionRanges(s.v6, []ipRange{r})
+	}
+}
+
+// Contains 判断ip是否在集合中
+func (s *CIDRSet) Contains(ip net.IP) bool {
+	ranges := s.v6
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, ranges = ip4, s.v4
+	} else if len(ip) != net.IPv6len {
+		return false
+	}
+	i := sort.Search(len(ranges), func(i int) bool {
+		return bytes.Compare(ranges[i].end, ip) >= 0
+	})
+	return i < len(ranges) && bytes.Compare(ranges[i].start, ip) <= 0
+}
+
+// Merge 将other并入集合
+func (s *CIDRSet) Merge(other *CIDRSet) {
+	s.v4 = unionRanges(s.v4, other.v4)
+	s.v6 = unionRanges(s.v6, other.v6)
+}
+
+// Subtract 从集合中去掉other包含的地址
+func (s *CIDRSet) Subtract(other *CIDRSet) {
+	s.v4 = subtractRanges(s.v4, other.v4)
+	s.v6 = subtractRanges(s.v6, other.v6)
+}
+
+// IsEmpty 集合是否为空
+func (s *CIDRSet) IsEmpty() bool {
+	return len(s.v4) == 0 && len(s.v6) == 0
+}
+
+// Range 按地址顺序遍历覆盖集合的最少CIDR,IPv4在前;f返回false时停止。
+func (s *CIDRSet) Range(f func(n *net.IPNet) bool) {
+	for _, ranges := range [][]ipRange{s.v4, s.v6} {
+		for _, r := range ranges {
+			if !rangeToCIDRs(r, f) {
+				return
+			}
+		}
+	}
+}
+
+// CIDRs 返回覆盖集合的最少CIDR
+func (s *CIDRSet) CIDRs() []*net.IPNet {
+	var res []*net.IPNet
+	s.Range(func(n *net.IPNet) bool {
+		res = append(res, n)
+		return true
+	})
+	return res
+}
+
+func (s *CIDRSet) String() string {
+	var parts []string
+	s.Range(func(n *net.IPNet) bool {
+		parts = append(parts, n.String())
+		return true
+	})
+	return strings.Join(parts, ",")
+}
+
+// netRange 地址段的首尾地址
+func netRange(n *net.IPNet) ipRange {
+	ip := n.IP
+	mask := n.Mask
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+	}
+	start := make(net.IP, len(ip))
+	end := make(net.IP, len(ip))
+	for i := range ip {
+		start[i] = ip[i] & mask[i]
+		end[i] = ip[i] | ^mask[i]
+	}
+	return ipRange{start: start, end: end}
+}
+
+// unionRanges 合并两个排序区间列表,相邻或重叠的区间会被合并
+func unionRanges(a, b []ipRange) []ipRange {
+	all := make([]ipRange, 0, len(a)+len(b))
+	all = append(append(all, a...), b...)
+	sort.Slice(all, func(i, j int) bool {
+		return bytes.Compare(all[i].start, all[j].start) < 0
+	})
+	var res []ipRange
+	for _, r := range all {
+		if n := len(res); n > 0 {
+			last := &res[n-1]
+			next := ipAdd(last.end, 1)
+			if next == nil || bytes.Compare(r.start, next) <= 0 {
+				if bytes.Compare(r.end, last.end) > 0 {
+					last.end = r.end
+				}
+				continue
+			}
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// subtractRanges 计算a-b,a和b均为排序且不重叠的区间列表
+func subtractRanges(a, b []ipRange) []ipRange {
+	var res []ipRange
+	j := 0
+	for _, r := range a {
+		start := r.start
+		for ; j < len(b) && bytes.Compare(b[j].end, start) < 0; j++ {
+		}
+		k := j
+		for ; k < len(b) && bytes.Compare(b[k].start, r.end) <= 0; k++ {
+			if bytes.Compare(b[k].start, start) > 0 {
+				res = append(res, ipRange{start: start, end: ipAdd(b[k].start, -1)})
+			}
+			if bytes.Compare(b[k].end, r.end) >= 0 {
+				start = nil
+				break
+			}
+			start = ipAdd(b[k].end, 1)
+		}
+		if start != nil {
+			res = append(res, ipRange{start: start, end: r.end})
+		}
+	}
+	return res
+}
+
+// ipAdd 返回ip加上delta(1或-1)后的地址,溢出时返回nil
+func ipAdd(ip net.IP, delta int) net.IP {
+	res := make(net.IP, len(ip))
+	copy(res, ip)
+	for i := len(res) - 1; i >= 0; i-- {
+		if delta > 0 {
+			res[i]++
+			if res[i] != 0 {
+				return res
+			}
+		} else {
+			res[i]--
+			if res[i] != 0xff {
+				return res
+			}
+		}
+	}
+	return nil
+}
+
+// rangeToCIDRs 将区间拆分成最少的CIDR,f返回false时停止并返回false
+func rangeToCIDRs(r ipRange, f func(n *net.IPNet) bool) bool {
+	bits := len(r.start) * 8
+	start := r.start
+	for start != nil && bytes.Compare(start, r.end) <= 0 {
+		// 从最大的块开始尝试:块必须以start对齐,且不能超过end。
+		prefix := bits - trailingZeros(start)
+		for ; prefix < bits; prefix++ {
+			mask := net.CIDRMask(prefix, bits)
+			last := netRange(&net.IPNet{IP: start, Mask: mask}).end
+			if bytes.Compare(last, r.end) <= 0 {
+				break
+			}
+		}
+		n := &net.IPNet{IP: start, Mask: net.CIDRMask(prefix, bits)}
+		if !f(n) {
+			return false
+		}
+		start = ipAdd(netRange(n).end, 1)
+	}
+	return true
+}
+
+// trailingZeros 地址末尾0比特的个数
+func trailingZeros(ip net.IP) int {
+	n := 0
+	for i := len(ip) - 1; i >= 0; i-- {
+		if ip[i] == 0 {
+			n += 8
+			continue
+		}
+		for b := ip[i]; b&1 == 0; b >>= 1 {
+			n++
+		}
+		break
+	}
+	return n
+}
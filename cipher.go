@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"errors"
+	"fmt"
+)
+
+// CipherAlgorithm 分组密码算法
+type CipherAlgorithm int
+
+const (
+	CipherAES128 CipherAlgorithm = iota + 1
+	CipherAES192
+	CipherAES256
+	// Cipher3DES 即Java的DESede,密钥为24字节,16字节的密钥按K1K2K1扩展
+	Cipher3DES
+	CipherSM4
+	// CipherDES 只用于兼容已有数据
+	CipherDES
+)
+
+func (a CipherAlgorithm) String() string {
+	switch a {
+	case CipherAES128:
+		return "AES-128"
+	case CipherAES192:
+		return "AES-192"
+	case CipherAES256:
+		return "AES-256"
+	case Cipher3DES:
+		return "3DES"
+	case CipherSM4:
+		return "SM4"
+	case CipherDES:
+		return "DES"
+	}
+	return fmt.Sprintf("CipherAlgorithm(%d)", int(a))
+}
+
+// CipherMode 工作模式
+type CipherMode int
+
+const (
+	ModeCBC CipherMode = iota + 1
+	// ModeCTR 整个分组作为大端计数器,同Java的CTR和pycryptodome的MODE_CTR(initial_value=iv, nonce=b"")
+	ModeCTR
+	// ModeCFB 128位(一个分组)反馈,同Java的CFB;pycryptodome需要指定segment_size=128
+	ModeCFB
+	// ModeCFB8 8位反馈,同Java的CFB8和pycryptodome默认的MODE_CFB
+	ModeCFB8
+	ModeOFB
+	// ModeECB 相同的明文分组得到相同的密文分组,只用于兼容已有系统
+	ModeECB
+)
+
+func (m CipherMode) String() string {
+	switch m {
+	case ModeCBC:
+		return "CBC"
+	case ModeCTR:
+		return "CTR"
+	case ModeCFB:
+		return "CFB"
+	case ModeCFB8:
+		return "CFB8"
+	case ModeOFB:
+		return "OFB"
+	case ModeECB:
+		return "ECB"
+	}
+	return fmt.Sprintf("CipherMode(%d)", int(m))
+}
+
+// isBlockMode CBC和ECB需要按分组填充,其他模式按流加密
+func (m CipherMode) isBlockMode() bool {
+	return m == ModeCBC || m == ModeECB
+}
+
+// CipherPadding 填充方式,只用于CBC和ECB
+type CipherPadding int
+
+const (
+	// PaddingPKCS7 同Java的PKCS5Padding和pycryptodome的pad(data, block_size)
+	PaddingPKCS7 CipherPadding = iota + 1
+	// PaddingZero 用0补足分组,长度已是分组整数倍时不填充;解密时去掉末尾所有的0,不能用于以0结尾的二进制数据
+	PaddingZero
+	// PaddingNone 不填充,明文长度必须是分组的整数倍
+	PaddingNone
+)
+
+// IVStrategy IV的来源
+type IVStrategy int
+
+const (
+	// IVRandomPrefix 每次加密生成随机IV并放在密文前面,解密时从密文中取出,默认方式
+	IVRandomPrefix IVStrategy = iota + 1
+	// IVExplicit 使用固定的IV,由双方约定,同一密钥下重复使用IV会泄露明文信息
+	IVExplicit
+	// IVDerived 使用密钥的前一个分组作为IV,与AesEncrypt相同,只用于兼容已有系统
+	IVDerived
+)
+
+// ErrPlaintextLength 不填充时明文长度不是分组长度的整数倍
+var ErrPlaintextLength = errors.New("crypt: plaintext is not a multiple of the block size")
+
+// CipherBuilder 配置分组密码,默认为CBC模式、PKCS7填充、随机IV前缀。例如与Java的
+// Cipher.getInstance("AES/CBC/PKCS5Padding")使用固定IV互通:
+//
+//	c, err := NewCipherBuilder(CipherAES128, key).Mode(ModeCBC).Padding(PaddingPKCS7).IV(iv).Build()
+type CipherBuilder struct {
+	alg        CipherAlgorithm
+	key        []byte
+	mode       CipherMode
+	padding    CipherPadding
+	ivStrategy IVStrategy
+	iv         []byte
+}
+
+// NewCipherBuilder 使用算法和密钥创建CipherBuilder
+func NewCipherBuilder(alg CipherAlgorithm, key []byte) *CipherBuilder {
+	return &CipherBuilder{alg: alg, key: key, mode: ModeCBC}
+}
+
+// Mode 设置工作模式
+func (b *CipherBuilder) Mode(mode CipherMode) *CipherBuilder {
+	b.mode = mode
+	return b
+}
+
+// Padding 设置填充方式,CBC和ECB默认为PKCS7,其他模式不填充
+func (b *CipherBuilder) Padding(padding CipherPadding) *CipherBuilder {
+	b.padding = padding
+	return b
+}
+
+// IV 使用固定的IV
+func (b *CipherBuilder) IV(iv []byte) *CipherBuilder {
+	b.ivStrategy, b.iv = IVExplicit, iv
+	return b
+}
+
+// RandomIV 每次加密使用随机IV,并放在密文前面
+func (b *CipherBuilder) RandomIV() *CipherBuilder {
+	b.ivStrategy, b.iv = IVRandomPrefix, nil
+	return b
+}
+
+// DerivedIV 使用密钥的前一个分组作为IV
+func (b *CipherBuilder) DerivedIV() *CipherBuilder {
+	b.ivStrategy, b.iv = IVDerived, nil
+	return b
+}
+
+// Build 校验配置并创建Cipher
+func (b *CipherBuilder) Build() (*Cipher, error) {
+	block, err := newBlockCipher(b.alg, b.key)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cipher{block: block, mode: b.mode, padding: b.padding, ivStrategy: b.ivStrategy}
+	switch b.mode {
+	case ModeCBC, ModeECB:
+		if c.padding == 0 {
+			c.padding = PaddingPKCS7
+		}
+		if c.padding < PaddingPKCS7 || c.padding > PaddingNone {
+			return nil, fmt.Errorf("crypt: unknown padding %d", c.padding)
+		}
+	case ModeCTR, ModeCFB, ModeCFB8, ModeOFB:
+		if c.padding != 0 && c.padding != PaddingNone {
+			return nil, fmt.Errorf("crypt: %s mode does not use padding", b.mode)
+		}
+		c.padding = PaddingNone
+	default:
+		return nil, fmt.Errorf("crypt: unknown cipher mode %d", b.mode)
+	}
+	if b.mode == ModeECB {
+		if b.ivStrategy != 0 {
+			return nil, errors.New("crypt: ECB mode does not use an IV")
+		}
+		return c, nil
+	}
+	bs := block.BlockSize()
+	switch b.ivStrategy {
+	case 0:
+		c.ivStrategy = IVRandomPrefix
+	case IVRandomPrefix:
+	case IVExplicit:
+		if len(b.iv) != bs {
+			return nil, fmt.Errorf("crypt: IV length must be %d, got %d", bs, len(b.iv))
+		}
+		c.iv = append([]byte(nil), b.iv...)
+	case IVDerived:
+		if len(b.key) < bs {
+			return nil, fmt.Errorf("crypt: key is shorter than the block size")
+		}
+		c.iv = append([]byte(nil), b.key[:bs]...)
+	default:
+		return nil, fmt.Errorf("crypt: unknown IV strategy %d", b.ivStrategy)
+	}
+	return c, nil
+}
+
+func newBlockCipher(alg CipherAlgorithm, key []byte) (cipher.Block, error) {
+	size := map[CipherAlgorithm]int{CipherAES128: 16, CipherAES192: 24, CipherAES256: 32, CipherSM4: 16, CipherDES: 8}
+	if n, ok := size[alg]; ok && len(key) != n {
+		return nil, fmt.Errorf("crypt: %s key must be %d bytes, got %d", alg, n, len(key))
+	}
+	switch alg {
+	case CipherAES128, CipherAES192, CipherAES256:
+		return aes.NewCipher(key)
+	case Cipher3DES:
+		switch len(key) {
+		case 16:
+			key = append(append([]byte(nil), key...), key[:8]...)
+		case 24:
+		default:
+			return nil, fmt.Errorf("crypt: 3DES key must be 16 or 24 bytes, got %d", len(key))
+		}
+		return des.NewTripleDESCipher(key)
+	case CipherSM4:
+		return NewSM4Cipher(key)
+	case CipherDES:
+		return des.NewCipher(key)
+	}
+	return nil, fmt.Errorf("crypt: unknown cipher algorithm %d", alg)
+}
+
+// Cipher 由CipherBuilder创建的分组密码,可以并发使用。
+// 只提供机密性,不能发现篡改,新代码请优先使用NewAEAD。
+type Cipher struct {
+	block      cipher.Block
+	mode       CipherMode
+	padding    CipherPadding
+	ivStrategy IVStrategy
+	iv         []byte
+}
+
+// Encrypt 加密,IVRandomPrefix时返回IV+密文
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	bs := c.block.BlockSize()
+	data := plaintext
+	if c.mode.isBlockMode() {
+		switch c.padding {
+		case PaddingPKCS7:
+			data = pkcs7Padding(plaintext, bs)
+		case PaddingZero:
+			if r := len(plaintext) % bs; r != 0 {
+				data = append(append([]byte(nil), plaintext...), make([]byte, bs-r)...)
+			}
+		case PaddingNone:
+			if len(plaintext)%bs != 0 {
+				return nil, ErrPlaintextLength
+			}
+		}
+	}
+	iv := c.iv
+	var prefix int
+	if c.ivStrategy == IVRandomPrefix {
+		prefix = bs
+	}
+	out := make([]byte, prefix+len(data))
+	if prefix > 0 {
+		var err error
+		if iv, err = RandomBytes(bs); err != nil {
+			return nil, err
+		}
+		copy(out, iv)
+	}
+	c.crypt(out[prefix:], data, iv, false)
+	return out, nil
+}
+
+// Decrypt 解密,填充不合法时返回ErrInvalidPadding
+func (c *Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	bs := c.block.BlockSize()
+	iv := c.iv
+	if c.ivStrategy == IVRandomPrefix {
+		if len(ciphertext) < bs {
+			return nil, ErrCiphertextLength
+		}
+		iv, ciphertext = ciphertext[:bs], ciphertext[bs:]
+	}
+	if c.mode.isBlockMode() {
+		if len(ciphertext)%bs != 0 || len(ciphertext) == 0 && c.padding == PaddingPKCS7 {
+			return nil, ErrCiphertextLength
+		}
+	}
+	out := make([]byte, len(ciphertext))
+	c.crypt(out, ciphertext, iv, true)
+	if !c.mode.isBlockMode() {
+		return out, nil
+	}
+	switch c.padding {
+	case PaddingPKCS7:
+		return PKCS7UnPadding(out, bs)
+	case PaddingZero:
+		return bytes.TrimRight(out, "\x00"), nil
+	}
+	return out, nil
+}
+
+func (c *Cipher) crypt(dst, src, iv []byte, decrypt bool) {
+	switch c.mode {
+	case ModeCBC:
+		if decrypt {
+			cipher.NewCBCDecrypter(c.block, iv).CryptBlocks(dst, src)
+		} else {
+			cipher.NewCBCEncrypter(c.block, iv).CryptBlocks(dst, src)
+		}
+	case ModeECB:
+		bs := c.block.BlockSize()
+		for i := 0; i < len(src); i += bs {
+			if decrypt {
+				c.block.Decrypt(dst[i:i+bs], src[i:i+bs])
+			} else {
+				c.block.Encrypt(dst[i:i+bs], src[i:i+bs])
+			}
+		}
+	case ModeCTR:
+		cipher.NewCTR(c.block, iv).XORKeyStream(dst, src)
+	case ModeOFB:
+		cipher.NewOFB(c.block, iv).XORKeyStream(dst, src)
+	case ModeCFB:
+		if decrypt {
+			cipher.NewCFBDecrypter(c.block, iv).XORKeyStream(dst, src)
+		} else {
+			cipher.NewCFBEncrypter(c.block, iv).XORKeyStream(dst, src)
+		}
+	case ModeCFB8:
+		newCFB8(c.block, iv, decrypt).XORKeyStream(dst, src)
+	}
+}
+
+// cfb8 8位反馈的CFB模式,cipher包只提供整个分组反馈的CFB
+type cfb8 struct {
+	block    cipher.Block
+	register []byte
+	out      []byte
+	decrypt  bool
+}
+
+func newCFB8(block cipher.Block, iv []byte, decrypt bool) cipher.Stream {
+	return &cfb8{
+		block:    block,
+		register: append([]byte(nil), iv...),
+		out:      make([]byte, block.BlockSize()),
+		decrypt:  decrypt,
+	}
+}
+
+func (x *cfb8) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		x.block.Encrypt(x.out, x.register)
+		c := src[i]
+		dst[i] = c ^ x.out[0]
+		if !x.decrypt {
+			c = dst[i]
+		}
+		// 寄存器左移一个字节,末尾放入密文
+		copy(x.register, x.register[1:])
+		x.register[len(x.register)-1] = c
+	}
+}
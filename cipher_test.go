@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestCipherSP80038A(t *testing.T) {
+	// NIST SP 800-38A 附录F,AES-128
+	key := mustHex("2b7e151628aed2a6abf7158809cf4f3c")
+	pt := mustHex("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	iv := mustHex("000102030405060708090a0b0c0d0e0f")
+	cases := []struct {
+		mode     CipherMode
+		iv       []byte
+		pt       []byte
+		expected string
+	}{
+		{ModeECB, nil, pt, "3ad77bb40d7a3660a89ecaf32466ef97f5d3d58503b9699de785895a96fdbaaf43b1cd7f598ece23881b00e3ed0306887b0c785e27e8ad3f8223207104725dd4"},
+		{ModeCBC, iv, pt, "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7"},
+		{ModeCFB, iv, pt, "3b3fd92eb72dad20333449f8e83cfb4ac8a64537a0b3a93fcde3cdad9f1ce58b26751f67a3cbb140b1808cf187a4f4dfc04b05357c5d1c0eeac4c66f9ff7f2e6"},
+		{ModeCFB8, iv, pt[:18], "3b79424c9c0dd436bace9e0ed4586a4f32b9"},
+		{ModeOFB, iv, pt, "3b3fd92eb72dad20333449f8e83cfb4a7789508d16918f03f53c52dac54ed8259740051e9c5fecf64344f7a82260edcc304c6528f659c77866a510d9c1d6ae5e"},
+		{ModeCTR, mustHex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"), pt, "874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee"},
+	}
+	for _, c := range cases {
+		b := NewCipherBuilder(CipherAES128, key).Mode(c.mode)
+		if c.mode.isBlockMode() {
+			b.Padding(PaddingNone)
+		}
+		if c.iv != nil {
+			b.IV(c.iv)
+		}
+		ci, err := b.Build()
+		if err != nil {
+			t.Fatalf("Build(%s): %v", c.mode, err)
+		}
+		got, err := ci.Encrypt(c.pt)
+		if err != nil || hex.EncodeToString(got) != c.expected {
+			t.Errorf("Encrypt(%s):\n Expect => %s\n Got => %x %v\n", c.mode, c.expected, got, err)
+		}
+		if back, err := ci.Decrypt(got); err != nil || !bytes.Equal(back, c.pt) {
+			t.Errorf("Decrypt(%s):\n Expect => %x\n Got => %x %v\n", c.mode, c.pt, back, err)
+		}
+	}
+}
+
+func TestCipherInterop(t *testing.T) {
+	// 以下密文由 openssl enc -<cipher> -K <key> -iv <iv> 生成,与Java Cipher和pycryptodome的结果相同
+	key := []byte("1234567890abcdef")
+	iv := []byte("fedcba0987654321")
+	pt := []byte("hello, 世界! Go/Java/Python")
+	cases := []struct {
+		alg      CipherAlgorithm
+		key      []byte
+		iv       []byte
+		mode     CipherMode
+		expected string
+	}{
+		{CipherAES128, key, iv, ModeCBC, "670d7f75efcd67a62ff729124641b927d90293d2035fb25c9c7e3ce81fec701d"},
+		{CipherAES128, key, nil, ModeECB, "8242e3e8b3fc7287b663a762b12a07caed16a1b4b6e1221d20950fb79a2f8dce"},
+		{CipherAES128, key, iv, ModeCTR, "de2942284f9a603592e33362b50b70e1a599738be9d40ac9f29ac7c273"},
+		{CipherAES128, key, iv, ModeOFB, "de2942284f9a603592e33362b50b70e16917bbe79e359e9876a581c06b"},
+		{CipherAES128, key, iv, ModeCFB, "de2942284f9a603592e33362b50b70e1e7409327ceed2215b62924657c"},
+		{CipherAES128, key, iv, ModeCFB8, "de42cf45eeff34c9fd9ceccbd53a42cd9c89bbce7b57d1cdfc87c3b50c"},
+		{CipherAES192, append(append([]byte(nil), key...), "12345678"...), iv, ModeCBC, "538e26766d3c5498c226fe508858566ee4b9b4f10108eb5f53d7f647046ae007"},
+		{CipherAES256, append(append([]byte(nil), key...), key...), iv, ModeCBC, "9d96d35af49b878478254f5bf3191bf04febfcf9398b7cb3c45b4a3f7a64bff3"},
+		{Cipher3DES, []byte("1234567890abcdefghijklmn"), []byte("12345678"), ModeCBC, "d077484521461d0cca52817b7e630c5bb3008ae60df228177358e5907810c792"},
+		{CipherSM4, key, iv, ModeCBC, "837130a4193f8f2467212b2849bd41aacad51b3a4d4bd41f672b644926fce522"},
+		{CipherSM4, key, nil, ModeECB, "b95fa03d280efc73b65f2b03969f7a2addf474f32ebfb66eccbeda75f9eb47ff"},
+		{CipherSM4, key, iv, ModeCTR, "315a519d665120c1638ef554e5a04741a73472528733cbf9d4e2a91048"},
+		{CipherSM4, key, iv, ModeOFB, "315a519d665120c1638ef554e5a0474157acd19f4f94ab99779e1be4ab"},
+		{CipherSM4, key, iv, ModeCFB, "315a519d665120c1638ef554e5a047412311b9334a3b5a33fb2846de5a"},
+	}
+	for _, c := range cases {
+		b := NewCipherBuilder(c.alg, c.key).Mode(c.mode)
+		if c.iv != nil {
+			b.IV(c.iv)
+		}
+		ci, err := b.Build()
+		if err != nil {
+			t.Fatalf("Build(%s/%s): %v", c.alg, c.mode, err)
+		}
+		got, err := ci.Encrypt(pt)
+		if err != nil || hex.EncodeToString(got) != c.expected {
+			t.Errorf("Encrypt(%s/%s):\n Expect => %s\n Got => %x %v\n", c.alg, c.mode, c.expected, got, err)
+		}
+		if back, err := ci.Decrypt(got); err != nil || !bytes.Equal(back, pt) {
+			t.Errorf("Decrypt(%s/%s):\n Expect => %s\n Got => %s %v\n", c.alg, c.mode, pt, back, err)
+		}
+	}
+
+	// IVDerived与AesEncrypt兼容
+	legacy, _ := AesEncrypt(pt, key)
+	ci, _ := NewCipherBuilder(CipherAES128, key).DerivedIV().Build()
+	if got, _ := ci.Encrypt(pt); !bytes.Equal(got, legacy) {
+		t.Errorf("DerivedIV:\n Expect => %x\n Got => %x\n", legacy, got)
+	}
+	des, _ := NewCipherBuilder(CipherDES, key[:8]).DerivedIV().Build()
+	legacy, _ = Encrypt(pt, key[:8])
+	if got, _ := des.Encrypt(pt); !bytes.Equal(got, legacy) {
+		t.Errorf("DES DerivedIV:\n Expect => %x\n Got => %x\n", legacy, got)
+	}
+	// 16字节的3DES密钥按K1K2K1扩展
+	c2, _ := NewCipherBuilder(Cipher3DES, []byte("1234567890abcdef")).IV([]byte("12345678")).Build()
+	c3, _ := NewCipherBuilder(Cipher3DES, []byte("1234567890abcdef12345678")).IV([]byte("12345678")).Build()
+	e2, _ := c2.Encrypt(pt)
+	e3, _ := c3.Encrypt(pt)
+	if !bytes.Equal(e2, e3) {
+		t.Errorf("3DES 16 byte key:\n Expect => %x\n Got => %x\n", e3, e2)
+	}
+}
+
+func TestCipherOptions(t *testing.T) {
+	key := []byte("1234567890abcdef")
+
+	// 默认使用随机IV前缀
+	ci, err := NewCipherBuilder(CipherAES128, key).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e1, _ := ci.Encrypt([]byte("data"))
+	e2, _ := ci.Encrypt([]byte("data"))
+	if len(e1) != 32 || bytes.Equal(e1, e2) {
+		t.Errorf("RandomIV:\n Expect => different 32 byte ciphertexts\n Got => %x %x\n", e1, e2)
+	}
+	if got, err := ci.Decrypt(e1); err != nil || string(got) != "data" {
+		t.Errorf("RandomIV Decrypt:\n Expect => data\n Got => %s %v\n", got, err)
+	}
+	for _, bad := range [][]byte{nil, e1[:16], e1[:31]} {
+		if _, err := ci.Decrypt(bad); !errors.Is(err, ErrCiphertextLength) {
+			t.Errorf("Decrypt(len %d):\n Expect => %v\n Got => %v\n", len(bad), ErrCiphertextLength, err)
+		}
+	}
+
+	// 零填充
+	zc, _ := NewCipherBuilder(CipherSM4, key).Mode(ModeECB).Padding(PaddingZero).Build()
+	for _, s := range []string{"", "abc", "0123456789abcdef"} {
+		e, _ := zc.Encrypt([]byte(s))
+		if len(e)%16 != 0 || len(e) > 16 {
+			t.Errorf("PaddingZero(%q):\n Expect => one block\n Got => %x\n", s, e)
+		}
+		if got, err := zc.Decrypt(e); err != nil || string(got) != s {
+			t.Errorf("PaddingZero(%q):\n Expect => %q\n Got => %q %v\n", s, s, got, err)
+		}
+	}
+
+	// 不填充
+	nc, _ := NewCipherBuilder(CipherAES128, key).Padding(PaddingNone).IV(key).Build()
+	if _, err := nc.Encrypt([]byte("abc")); !errors.Is(err, ErrPlaintextLength) {
+		t.Errorf("PaddingNone:\n Expect => %v\n Got => %v\n", ErrPlaintextLength, err)
+	}
+
+	// 流模式的密文长度等于明文长度
+	sc, _ := NewCipherBuilder(CipherAES256, bytes.Repeat(key, 2)).Mode(ModeCTR).Build()
+	if e, _ := sc.Encrypt([]byte("abc")); len(e) != 16+3 {
+		t.Errorf("CTR RandomIV:\n Expect => 19 bytes\n Got => %x\n", e)
+	}
+
+	for name, b := range map[string]*CipherBuilder{
+		"key size":     NewCipherBuilder(CipherAES256, key),
+		"3des key":     NewCipherBuilder(Cipher3DES, key[:8]),
+		"iv size":      NewCipherBuilder(CipherAES128, key).IV(key[:8]),
+		"ecb iv":       NewCipherBuilder(CipherAES128, key).Mode(ModeECB).IV(key),
+		"ctr padding":  NewCipherBuilder(CipherAES128, key).Mode(ModeCTR).Padding(PaddingPKCS7),
+		"unknown mode": NewCipherBuilder(CipherAES128, key).Mode(99),
+		"unknown alg":  NewCipherBuilder(99, key),
+		"unknown pad":  NewCipherBuilder(CipherAES128, key).Padding(99),
+	} {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("Build(%s):\n Expect => error\n Got => nil\n", name)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// 客户端IP的来源
+const (
+	SourceRemoteAddr     = "RemoteAddr"
+	SourceCFConnectingIP = "CF-Connecting-IP"
+	SourceTrueClientIP   = "True-Client-IP"
+	SourceForwarded      = "Forwarded"
+	SourceXForwardedFor  = "X-Forwarded-For"
+	SourceXRealIP        = "X-Real-IP"
+)
+
+// DefaultIPHeaders IPResolver默认依次检查的请求头,只包含从右向左遍历的代理链。
+//
+// CF-Connecting-IP、True-Client-IP和X-Real-IP只有一个值,普通代理(nginx、ELB等)会原样转发客户端发送的值,
+// 只有可信代理确实是Cloudflare、Akamai或会覆盖该请求头的nginx时,才应通过Headers启用。
+var DefaultIPHeaders = []string{
+	SourceForwarded,
+	SourceXForwardedFor,
+}
+
+// IPResolver 结合可信代理解析客户端IP。
+//
+// 只有当请求直接来自可信代理时才会读取代理相关的请求头;
+// Forwarded和X-Forwarded-For从右向左遍历,跳过可信代理,第一个不可信的地址即为客户端IP。
+type IPResolver struct {
+	trusted []*net.IPNet
+	// Headers 依次检查的请求头,为空时使用DefaultIPHeaders;
+	// 例如可信代理为Cloudflare时使用 []string{SourceCFConnectingIP, SourceXForwardedFor}
+	Headers []string
+}
+
+// NewIPResolver 使用可信代理的CIDR创建解析器,单个IP按/32或/128处理。
+func NewIPResolver(trustedProxies ...string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, s := range trustedProxies {
+		n, err := parseCIDROrIP(s)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, n)
+	}
+	return r, nil
+}
+
+// parseCIDROrIP 解析CIDR或单个IP
+func parseCIDROrIP(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP or CIDR: %q", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Trusted 判断ip是否为可信代理
+func (r *IPResolver) Trusted(ip net.IP) bool {
+	for _, n := range r.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 返回客户端IP以及它的来源(请求头名称或RemoteAddr),无法解析时ip为空。
+func (r *IPResolver) Resolve(req *http.Request) (ip string, source string) {
+	remote := parseHostIP(req.RemoteAddr)
+	if remote == nil {
+		return "", ""
+	}
+	if !r.Trusted(remote) {
+		return remote.String(), SourceRemoteAddr
+	}
+	headers := r.Headers
+	if len(headers) == 0 {
+		headers = DefaultIPHeaders
+	}
+	for _, h := range headers {
+		var found net.IP
+		switch http.CanonicalHeaderKey(h) {
+		case http.CanonicalHeaderKey(SourceForwarded):
+			found = r.walk(forwardedFor(req.Header.Values(SourceForwarded)))
+		case http.CanonicalHeaderKey(SourceXForwardedFor):
+			found = r.walk(splitHeaderList(req.Header.Values(SourceXForwardedFor)))
+		default:
+			found = parseHostIP(strings.TrimSpace(req.Header.Get(h)))
+		}
+		if found != nil {
+			return found.String(), h
+		}
+	}
+	return remote.String(), SourceRemoteAddr
+}
+
+// walk 从右向左遍历代理链,跳过可信代理;遇到无法解析的地址时放弃该链。
+// 整条链都可信时返回最左边的地址。
+func (r *IPResolver) walk(hops []string) net.IP {
+	var last net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHostIP(hops[i])
+		if ip == nil {
+			return nil
+		}
+		if !r.Trusted(ip) {
+			return ip
+		}
+		last = ip
+	}
+	return last
+}
+
+// splitHeaderList 合并多个请求头并按逗号拆分
+func splitHeaderList(values []string) []string {
+	var res []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+	}
+	return res
+}
+
+// forwardedFor 按RFC 7239解析Forwarded头中每一跳的for参数
+func forwardedFor(values []string) []string {
+	var res []string
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			for _, pair := range splitQuoted(element, ';') {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					res = append(res, strings.Trim(kv[1], `"`))
+				}
+			}
+		}
+	}
+	return res
+}
+
+// splitQuoted 按分隔符拆分,忽略双引号内的分隔符
+func splitQuoted(s string, sep byte) []string {
+	var res []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, s[start:])
+}
+
+// parseHostIP 解析"ip"、"ip:port"、"[ipv6]"或"[ipv6]:port"格式的地址
+func parseHostIP(s string) net.IP {
+	if s == "" {
+		return nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return net.ParseIP(s[1 : len(s)-1])
+	}
+	return nil
+}
+
+// requestIP 使用resolver解析客户端IP,resolver为nil时只使用RemoteAddr,不读取可被伪造的请求头
+func requestIP(r *http.Request, resolver *IPResolver) string {
+	if resolver != nil {
+		ip, _ := resolver.Resolve(r)
+		return ip
+	}
+	if ip := parseHostIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// defaultIPResolver 由SetTrustedProxies设置,GetIP优先使用它
+var defaultIPResolver atomic.Value
+
+// SetTrustedProxies 设置GetIP使用的可信代理,设置后GetIP只信任来自这些代理的请求头;
+// 不传参数时恢复GetIP原有的行为。
+func SetTrustedProxies(trustedProxies ...string) error {
+	if len(trustedProxies) == 0 {
+		defaultIPResolver.Store((*IPResolver)(nil))
+		return nil
+	}
+	r, err := NewIPResolver(trustedProxies...)
+	if err != nil {
+		return err
+	}
+	defaultIPResolver.Store(r)
+	return nil
+}
+
+// DefaultIPResolver 返回SetTrustedProxies设置的解析器,未设置时返回nil
+func DefaultIPResolver() *IPResolver {
+	r, _ := defaultIPResolver.Load().(*IPResolver)
+	return r
+}
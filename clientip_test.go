@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPResolver(t *testing.T) {
+	r, err := NewIPResolver("10.0.0.0/8", "192.0.2.1", "2001:db8::/32")
+	if err != nil {
+		t.Fatalf("NewIPResolver:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	cases := []struct {
+		title   string
+		remote  string
+		headers map[string][]string
+		ip      string
+		source  string
+	}{
+		{"untrusted peer ignores headers", "203.0.113.5:1234", map[string][]string{"X-Forwarded-For": {"1.1.1.1"}, "X-Real-Ip": {"1.1.1.1"}}, "203.0.113.5", SourceRemoteAddr},
+		{"xff right to left", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"6.6.6.6, 198.51.100.7, 10.1.2.3"}}, "198.51.100.7", SourceXForwardedFor},
+		{"xff multiple headers", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"6.6.6.6", "198.51.100.8,192.0.2.1"}}, "198.51.100.8", SourceXForwardedFor},
+		{"xff all trusted", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.9.9.9, 10.1.1.1"}}, "10.9.9.9", SourceXForwardedFor},
+		{"xff garbage on the right", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7, garbage"}}, "10.0.0.1", SourceRemoteAddr},
+		{"forwarded", "10.0.0.1:1234", map[string][]string{"Forwarded": {`for=198.51.100.9;proto=https, for="[2001:db8:cafe::17]:4711"`}}, "198.51.100.9", SourceForwarded},
+		{"forwarded v6 client", "[2001:db8::1]:443", map[string][]string{"Forwarded": {`for="[2001:dead::17]:4711";by=10.0.0.1`}}, "2001:dead::17", SourceForwarded},
+		// 普通代理原样转发客户端伪造的单值请求头,默认不读取
+		{"spoofed cf-connecting-ip", "10.0.0.1:1234", map[string][]string{"Cf-Connecting-Ip": {"6.6.6.6"}, "X-Forwarded-For": {"198.51.100.7"}}, "198.51.100.7", SourceXForwardedFor},
+		{"spoofed true-client-ip", "10.0.0.1:1234", map[string][]string{"True-Client-Ip": {"6.6.6.6"}, "X-Real-Ip": {"6.6.6.6"}}, "10.0.0.1", SourceRemoteAddr},
+		{"no headers", "10.0.0.1:1234", nil, "10.0.0.1", SourceRemoteAddr},
+		{"bad remote", "bad", nil, "", ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remote
+		for k, vs := range c.headers {
+			req.Header[k] = vs
+		}
+		ip, source := r.Resolve(req)
+		if ip != c.ip || source != c.source {
+			t.Errorf("Resolve(%s):\n Expect => %s %s\n Got => %s %s\n", c.title, c.ip, c.source, ip, source)
+		}
+	}
+
+	// 可信代理为Cloudflare等CDN时显式启用对应的请求头
+	cdn, _ := NewIPResolver("10.0.0.0/8")
+	cdn.Headers = []string{SourceCFConnectingIP, SourceTrueClientIP, SourceXForwardedFor, SourceXRealIP}
+	for _, c := range []struct {
+		headers map[string][]string
+		ip      string
+		source  string
+	}{
+		{map[string][]string{"Cf-Connecting-Ip": {"198.51.100.10"}, "X-Forwarded-For": {"198.51.100.7"}}, "198.51.100.10", SourceCFConnectingIP},
+		{map[string][]string{"True-Client-Ip": {"198.51.100.11"}}, "198.51.100.11", SourceTrueClientIP},
+		{map[string][]string{"X-Real-Ip": {"198.51.100.12"}}, "198.51.100.12", SourceXRealIP},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		for k, vs := range c.headers {
+			req.Header[k] = vs
+		}
+		if ip, source := cdn.Resolve(req); ip != c.ip || source != c.source {
+			t.Errorf("Resolve(%s):\n Expect => %s %s\n Got => %s %s\n", c.source, c.ip, c.source, ip, source)
+		}
+	}
+
+	if _, err = NewIPResolver("not-a-cidr"); err == nil {
+		t.Errorf("NewIPResolver:\n Expect => error\n Got => %v\n", err)
+	}
+}
+
+func TestGetIPTrustedProxies(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+	if ip := GetIP(req); ip != "1.1.1.1" {
+		t.Errorf("GetIP:\n Expect => %s\n Got => %s\n", "1.1.1.1", ip)
+	}
+
+	if err := SetTrustedProxies("10.0.0.0/8"); err != nil {
+		t.Fatalf("SetTrustedProxies:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	defer SetTrustedProxies()
+	if ip := GetIP(req); ip != "203.0.113.5" {
+		t.Errorf("GetIP:\n Expect => %s\n Got => %s\n", "203.0.113.5", ip)
+	}
+	req.RemoteAddr = "10.0.0.1:1234"
+	if ip := GetIP(req); ip != "2.2.2.2" {
+		t.Errorf("GetIP:\n Expect => %s\n Got => %s\n", "2.2.2.2", ip)
+	}
+}
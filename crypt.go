@@ -6,29 +6,61 @@ import (
 	"crypto/cipher"
 	"crypto/des"
 	"crypto/hmac"
-	"crypto/md5"
-	"crypto/sha1"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"hash"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	// ErrInvalidPadding 解密后的填充不合法,通常是密钥错误或密文被篡改
+	ErrInvalidPadding = errors.New("crypt: invalid padding")
+	// ErrCiphertextLength 密文为空或长度不是分组长度的整数倍
+	ErrCiphertextLength = errors.New("crypt: ciphertext is not a multiple of the block size")
+	// ErrKeySize 密钥长度与算法不符
+	ErrKeySize = errors.New("crypt: invalid key size")
 )
 
-// pkcs7Padding 填充
+// pkcs7Padding 填充,返回新的切片,不修改data
 func pkcs7Padding(data []byte, blockSize int) []byte {
 	padding := blockSize - len(data)%blockSize
-	padText := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(data, padText...)
+	res := make([]byte, len(data), len(data)+padding)
+	copy(res, data)
+	return append(res, bytes.Repeat([]byte{byte(padding)}, padding)...)
 }
 
-// pkcs7UnPadding 填充的反向操作
-func pkcs7UnPadding(data []byte) ([]byte, error) {
+// PKCS7UnPadding 校验并去除PKCS#7填充,填充不合法时返回ErrInvalidPadding。
+// 校验时间只与blockSize有关,与填充内容无关,避免填充预言攻击。
+func PKCS7UnPadding(data []byte, blockSize int) ([]byte, error) {
 	length := len(data)
-	unPadding := int(data[length-1])
-	return data[:(length - unPadding)], nil
+	if blockSize <= 0 || blockSize > 255 || length == 0 || length%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+	padding := int(data[length-1])
+	good := subtle.ConstantTimeLessOrEq(1, padding) & subtle.ConstantTimeLessOrEq(padding, blockSize)
+	// 检查最后一个分组中属于填充的字节是否都等于padding
+	for i := 1; i <= blockSize; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, padding)
+		match := subtle.ConstantTimeByteEq(data[length-i], byte(padding))
+		good &= subtle.ConstantTimeSelect(inPadding, match, 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return data[:length-padding], nil
 }
 
-// AesEncrypt 加密
+// AesEncrypt 加密,使用CBC模式且以密钥作为IV,相同明文得到相同密文且无法发现篡改,
+// 仅用于兼容已有数据,新代码请使用AEADEncrypt或NewAEAD。
 func AesEncrypt(data []byte, key []byte) ([]byte, error) {
+	if err := checkAESKey(key); err != nil {
+		return nil, err
+	}
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -43,26 +75,35 @@ func AesEncrypt(data []byte, key []byte) ([]byte, error) {
 
 // AesDecrypt 解密
 func AesDecrypt(data []byte, key []byte) ([]byte, error) {
+	if err := checkAESKey(key); err != nil {
+		return nil, err
+	}
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 	blockSize := block.BlockSize()
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, ErrCiphertextLength
+	}
 	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
 	crypted := make([]byte, len(data))
 	blockMode.CryptBlocks(crypted, data)
-	crypted, err = pkcs7UnPadding(crypted)
-	if err != nil {
-		return nil, err
+	return PKCS7UnPadding(crypted, blockSize)
+}
+
+// checkAESKey 检查AES密钥长度
+func checkAESKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
 	}
-	return crypted, nil
+	return fmt.Errorf("%w: AES key must be 16, 24 or 32 bytes, got %d", ErrKeySize, len(key))
 }
 
-// 给指定的字符串进行MD5加密
+// 给指定的字符串进行MD5加密,返回十六进制字符串;其他算法和输出格式请使用Hash
 func MD5(origData string) string {
-	m := md5.New()
-	m.Write([]byte(origData))
-	return hex.EncodeToString(m.Sum(nil))
+	return HashString(HashMD5, origData).Hex()
 }
 
 /**
@@ -77,36 +118,35 @@ func Authenticate(attemptedPassword, encryptedPassword, salt string) bool {
 	// 用相同的盐值对用户输入的密码进行加密
 	eap := EncryptedPassword(attemptedPassword, salt)
 	// 把加密后的密文和原密文进行比较，相同则验证成功，否则失败
-	return eap == encryptedPassword
+	return subtle.ConstantTimeCompare([]byte(eap), []byte(encryptedPassword)) == 1
 }
 
 /**
- * 通过提供加密的强随机数生成器 生成盐
+ * 通过加密的强随机数生成器生成盐(16字节的十六进制字符串)
  *
  * @return
  */
 func GenerateSalt() string {
-	keyword := RandString(10)
-	data := []byte(keyword)
-	hash := SHA1(SHA1(data))
-	key := hash[0:16]
-	return hex.EncodeToString(key)
+	return mustRandom(RandomHex(16))
 }
+
+// SHA1 计算SHA-1摘要,返回原始字节;其他算法和输出格式请使用Hash
 func SHA1(data []byte) []byte {
-	h := sha1.New()
-	h.Write(data)
-	return h.Sum(nil)
+	return Hash(HashSHA1, data)
 }
 
+// legacyPasswordIterations EncryptedPassword使用的PBKDF2迭代次数
+const legacyPasswordIterations = 10000
+
 /**
- * 生成密文
+ * 生成密文,新代码请使用HashPassword
  *
  * @param rawPwd 明文密码
  * @param salt     盐值
  * @return
  */
 func EncryptedPassword(rawPwd string, salt string) string {
-	pwd := PBKDF2([]byte(rawPwd), []byte(salt), 10000, 50, sha256.New)
+	pwd := PBKDF2([]byte(rawPwd), []byte(salt), legacyPasswordIterations, 50, sha256.New)
 	return hex.EncodeToString(pwd)
 }
 func PBKDF2(password, salt []byte, iter, keyLen int, h func() hash.Hash) []byte {
@@ -167,11 +207,13 @@ func Decrypt(cryptic, key []byte) ([]byte, error) {
 		return nil, err
 	}
 	blockSize := block.BlockSize()
+	if len(cryptic) == 0 || len(cryptic)%blockSize != 0 {
+		return nil, ErrCiphertextLength
+	}
 	blockMode := cipher.NewCBCDecrypter(block, keyBytes[:blockSize])
 	origData := make([]byte, len(cryptic))
 	blockMode.CryptBlocks(origData, cryptic)
-	origData = PKCS5UnPadding(origData)
-	return origData, nil
+	return PKCS7UnPadding(origData, blockSize)
 }
 func getKeyBytes(key string) []byte {
 	keyBytes := []byte(key)
@@ -183,14 +225,251 @@ func getKeyBytes(key string) []byte {
 	}
 	return keyBytes
 }
+
+// PKCS5Padding 填充,返回新的切片,不修改cipher
 func PKCS5Padding(cipher []byte, blockSize int) []byte {
-	padding := blockSize - len(cipher)%blockSize
-	text := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(cipher, text...)
+	return pkcs7Padding(cipher, blockSize)
 }
 
+// PKCS5UnPadding 去除填充,填充不合法时返回nil;需要区分错误时使用PKCS7UnPadding。
 func PKCS5UnPadding(origData []byte) []byte {
 	length := len(origData)
-	un := int(origData[length-1])
-	return origData[:(length - un)]
+	if length == 0 {
+		return nil
+	}
+	// 不知道分组长度,只能按填充值本身校验
+	padding := int(origData[length-1])
+	if padding == 0 || padding > length {
+		return nil
+	}
+	var diff byte
+	for _, b := range origData[length-padding:] {
+		diff |= b ^ byte(padding)
+	}
+	if diff != 0 {
+		return nil
+	}
+	return origData[:length-padding]
+}
+
+// AEADAlgorithm 认证加密算法
+type AEADAlgorithm byte
+
+const (
+	// AEADAESGCM AES-GCM,密钥长度16、24或32字节,nonce为12字节
+	AEADAESGCM AEADAlgorithm = 1
+	// AEADChaCha20Poly1305 ChaCha20-Poly1305,密钥32字节,nonce为12字节
+	AEADChaCha20Poly1305 AEADAlgorithm = 2
+	// AEADXChaCha20Poly1305 XChaCha20-Poly1305,密钥32字节,nonce为24字节,适合同一密钥加密大量消息
+	AEADXChaCha20Poly1305 AEADAlgorithm = 3
+)
+
+func (a AEADAlgorithm) String() string {
+	switch a {
+	case AEADAESGCM:
+		return "AES-GCM"
+	case AEADChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	case AEADXChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	}
+	return fmt.Sprintf("AEADAlgorithm(%d)", byte(a))
+}
+
+// aeadEnvelopeVersion 密文格式的版本
+const aeadEnvelopeVersion = 1
+
+var (
+	// ErrAEADEnvelope 密文格式错误
+	ErrAEADEnvelope = errors.New("aead: malformed ciphertext envelope")
+	// ErrAEADAuth 密文或附加数据被篡改,或密钥不正确
+	ErrAEADAuth = errors.New("aead: message authentication failed")
+	// ErrAEADKeyNotFound 没有与密文的算法和密钥ID匹配的密钥
+	ErrAEADKeyNotFound = errors.New("aead: no key for ciphertext")
+)
+
+// AEADEnvelope 自描述的密文格式:
+//
+//	version(1字节) | algorithm(1字节) | len(key id)(1字节) | key id | nonce | ciphertext+tag
+//
+// version、algorithm和key id作为附加数据的一部分参与认证。
+type AEADEnvelope struct {
+	Version    byte
+	Algorithm  AEADAlgorithm
+	KeyID      string
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// header 返回参与认证的头部
+func (e *AEADEnvelope) header() []byte {
+	h := make([]byte, 0, 3+len(e.KeyID))
+	h = append(h, e.Version, byte(e.Algorithm), byte(len(e.KeyID)))
+	return append(h, e.KeyID...)
+}
+
+// Bytes 编码为密文
+func (e *AEADEnvelope) Bytes() []byte {
+	h := e.header()
+	res := make([]byte, 0, len(h)+len(e.Nonce)+len(e.Ciphertext))
+	res = append(res, h...)
+	res = append(res, e.Nonce...)
+	return append(res, e.Ciphertext...)
+}
+
+// ParseAEADEnvelope 解析密文,不解密
+func ParseAEADEnvelope(data []byte) (*AEADEnvelope, error) {
+	if len(data) < 3 {
+		return nil, ErrAEADEnvelope
+	}
+	if data[0] != aeadEnvelopeVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrAEADEnvelope, data[0])
+	}
+	e := &AEADEnvelope{Version: data[0], Algorithm: AEADAlgorithm(data[1])}
+	nonceSize, overhead := aeadSizes(e.Algorithm)
+	if nonceSize == 0 {
+		return nil, fmt.Errorf("%w: unknown algorithm %d", ErrAEADEnvelope, data[1])
+	}
+	idLen := int(data[2])
+	data = data[3:]
+	if len(data) < idLen+nonceSize+overhead {
+		return nil, fmt.Errorf("%w: too short", ErrAEADEnvelope)
+	}
+	e.KeyID = string(data[:idLen])
+	e.Nonce = data[idLen : idLen+nonceSize]
+	e.Ciphertext = data[idLen+nonceSize:]
+	return e, nil
+}
+
+// aeadSizes 算法的nonce长度和认证标签长度,未知算法返回0
+func aeadSizes(alg AEADAlgorithm) (nonceSize, overhead int) {
+	switch alg {
+	case AEADAESGCM:
+		return 12, 16
+	case AEADChaCha20Poly1305:
+		return chacha20poly1305.NonceSize, chacha20poly1305.Overhead
+	case AEADXChaCha20Poly1305:
+		return chacha20poly1305.NonceSizeX, chacha20poly1305.Overhead
+	}
+	return 0, 0
+}
+
+// AEAD 带密钥ID的认证加密器,每条消息使用随机nonce,可以并发使用。
+//
+// 随机nonce下AES-GCM和ChaCha20-Poly1305每个密钥加密的消息数不应超过2^32条,
+// 更多时请轮换密钥或使用XChaCha20-Poly1305。
+type AEAD struct {
+	alg   AEADAlgorithm
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewAEAD 创建认证加密器,keyID写入密文用于解密时选择密钥,最长255字节,可以为空。
+func NewAEAD(alg AEADAlgorithm, keyID string, key []byte) (*AEAD, error) {
+	if len(keyID) > 255 {
+		return nil, errors.New("aead: key id longer than 255 bytes")
+	}
+	aead, err := newCipherAEAD(alg, key)
+	if err != nil {
+		return nil, err
+	}
+	return &AEAD{alg: alg, keyID: keyID, aead: aead}, nil
+}
+
+// newCipherAEAD 创建算法对应的cipher.AEAD
+func newCipherAEAD(alg AEADAlgorithm, key []byte) (cipher.AEAD, error) {
+	switch alg {
+	case AEADAESGCM:
+		if err := checkAESKey(key); err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AEADChaCha20Poly1305, AEADXChaCha20Poly1305:
+		if len(key) != chacha20poly1305.KeySize {
+			return nil, fmt.Errorf("%w: %s key must be %d bytes, got %d", ErrKeySize, alg, chacha20poly1305.KeySize, len(key))
+		}
+		if alg == AEADChaCha20Poly1305 {
+			return chacha20poly1305.New(key)
+		}
+		return chacha20poly1305.NewX(key)
+	}
+	return nil, fmt.Errorf("aead: unknown algorithm %s", alg)
+}
+
+// Algorithm 加密算法
+func (a *AEAD) Algorithm() AEADAlgorithm {
+	return a.alg
+}
+
+// KeyID 密钥ID
+func (a *AEAD) KeyID() string {
+	return a.keyID
+}
+
+// Seal 加密并认证plaintext,aad为可选的附加数据,解密时必须提供相同的aad。
+func (a *AEAD) Seal(plaintext, aad []byte) ([]byte, error) {
+	e := &AEADEnvelope{Version: aeadEnvelopeVersion, Algorithm: a.alg, KeyID: a.keyID}
+	e.Nonce = make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, err
+	}
+	header := e.header()
+	e.Ciphertext = a.aead.Seal(nil, e.Nonce, plaintext, append(header, aad...))
+	return e.Bytes(), nil
+}
+
+// Open 解密Seal生成的密文,密文的算法或密钥ID不匹配时返回ErrAEADKeyNotFound,认证失败时返回ErrAEADAuth。
+func (a *AEAD) Open(data, aad []byte) ([]byte, error) {
+	e, err := ParseAEADEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	return a.open(e, aad)
+}
+
+func (a *AEAD) open(e *AEADEnvelope, aad []byte) ([]byte, error) {
+	if e.Algorithm != a.alg || e.KeyID != a.keyID {
+		return nil, ErrAEADKeyNotFound
+	}
+	plaintext, err := a.aead.Open(nil, e.Nonce, e.Ciphertext, append(e.header(), aad...))
+	if err != nil {
+		return nil, ErrAEADAuth
+	}
+	return plaintext, nil
+}
+
+// OpenAEAD 使用与密文的算法和密钥ID匹配的密钥解密,用于密钥轮换期间同时接受新旧密钥。
+func OpenAEAD(data, aad []byte, keys ...*AEAD) ([]byte, error) {
+	e, err := ParseAEADEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.alg == e.Algorithm && k.keyID == e.KeyID {
+			return k.open(e, aad)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s key %q", ErrAEADKeyNotFound, e.Algorithm, e.KeyID)
+}
+
+// AEADEncrypt 使用AES-GCM加密,替代AesEncrypt;key为16、24或32字节。
+func AEADEncrypt(plaintext, key, aad []byte) ([]byte, error) {
+	a, err := NewAEAD(AEADAESGCM, "", key)
+	if err != nil {
+		return nil, err
+	}
+	return a.Seal(plaintext, aad)
+}
+
+// AEADDecrypt 解密AEADEncrypt生成的密文
+func AEADDecrypt(data, key, aad []byte) ([]byte, error) {
+	a, err := NewAEAD(AEADAESGCM, "", key)
+	if err != nil {
+		return nil, err
+	}
+	return a.Open(data, aad)
 }
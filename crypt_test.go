@@ -1,6 +1,12 @@
 package utils
 
-import "testing"
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
 
 func Test_EncryptedPassword(t *testing.T) {
 	expected := "d31016aa4230cc95bf653b5266ca1dff5a35ebce09a1dd659c704fcb162ef0432137a8ed0d225e8bf67c49fbba93b6e34a6c"
@@ -20,3 +26,196 @@ func Test_Authenticate(t *testing.T) {
 		t.Error("生成的密码不匹配", len(actual), actual, salt)
 	}
 }
+
+func TestAEAD(t *testing.T) {
+	keys := map[AEADAlgorithm][]byte{
+		AEADAESGCM:            bytes.Repeat([]byte{1}, 32),
+		AEADChaCha20Poly1305:  bytes.Repeat([]byte{2}, 32),
+		AEADXChaCha20Poly1305: bytes.Repeat([]byte{3}, 32),
+	}
+	plaintext := []byte("hello, 世界")
+	aad := []byte("user:42")
+	for alg, key := range keys {
+		a, err := NewAEAD(alg, "k1", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c1, err := a.Seal(plaintext, aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c2, _ := a.Seal(plaintext, aad)
+		if bytes.Equal(c1, c2) {
+			t.Errorf("Seal(%s):\n Expect => different ciphertexts\n Got => same\n", alg)
+		}
+		e, err := ParseAEADEnvelope(c1)
+		if err != nil || e.Algorithm != alg || e.KeyID != "k1" || e.Version != 1 {
+			t.Errorf("ParseAEADEnvelope(%s):\n Got => %+v %v\n", alg, e, err)
+		}
+		if got, err := a.Open(c1, aad); err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("Open(%s):\n Expect => %s\n Got => %s %v\n", alg, plaintext, got, err)
+		}
+		if _, err := a.Open(c1, []byte("user:43")); !errors.Is(err, ErrAEADAuth) {
+			t.Errorf("Open(%s, wrong aad):\n Expect => %v\n Got => %v\n", alg, ErrAEADAuth, err)
+		}
+		// 篡改密文的任何位置都会被发现
+		for i := 3 + len("k1"); i < len(c1); i++ {
+			tampered := append([]byte(nil), c1...)
+			tampered[i] ^= 1
+			if _, err := a.Open(tampered, aad); !errors.Is(err, ErrAEADAuth) {
+				t.Fatalf("Open(%s, tampered byte %d):\n Expect => %v\n Got => %v\n", alg, i, ErrAEADAuth, err)
+			}
+		}
+		if _, err := a.Open(c1[:len(c1)-1], aad); !errors.Is(err, ErrAEADAuth) {
+			t.Errorf("Open(%s, truncated):\n Expect => %v\n Got => %v\n", alg, ErrAEADAuth, err)
+		}
+	}
+
+	// 密钥轮换
+	oldKey, _ := NewAEAD(AEADAESGCM, "2023", bytes.Repeat([]byte{4}, 16))
+	newKey, _ := NewAEAD(AEADChaCha20Poly1305, "2024", bytes.Repeat([]byte{5}, 32))
+	old, _ := oldKey.Seal(plaintext, nil)
+	if got, err := OpenAEAD(old, nil, newKey, oldKey); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("OpenAEAD:\n Expect => %s\n Got => %s %v\n", plaintext, got, err)
+	}
+	if _, err := OpenAEAD(old, nil, newKey); !errors.Is(err, ErrAEADKeyNotFound) {
+		t.Errorf("OpenAEAD(missing key):\n Expect => %v\n Got => %v\n", ErrAEADKeyNotFound, err)
+	}
+	if _, err := newKey.Open(old, nil); !errors.Is(err, ErrAEADKeyNotFound) {
+		t.Errorf("Open(other key):\n Expect => %v\n Got => %v\n", ErrAEADKeyNotFound, err)
+	}
+	// 修改头部中的密钥ID后无法通过认证
+	forged := append([]byte(nil), old...)
+	copy(forged[3:], "2024")
+	forgedKey, _ := NewAEAD(AEADAESGCM, "2024", bytes.Repeat([]byte{4}, 16))
+	if _, err := forgedKey.Open(forged, nil); !errors.Is(err, ErrAEADAuth) {
+		t.Errorf("Open(forged key id):\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+	}
+
+	for _, bad := range [][]byte{nil, {1, 1}, {2, 1, 0}, {1, 9, 0}, append([]byte{1, 1, 0}, make([]byte, 27)...)} {
+		if _, err := ParseAEADEnvelope(bad); !errors.Is(err, ErrAEADEnvelope) {
+			t.Errorf("ParseAEADEnvelope(%x):\n Expect => %v\n Got => %v\n", bad, ErrAEADEnvelope, err)
+		}
+	}
+	if _, err := NewAEAD(AEADChaCha20Poly1305, "", make([]byte, 16)); err == nil {
+		t.Errorf("NewAEAD(short key):\n Expect => error\n Got => nil\n")
+	}
+	if _, err := NewAEAD(AEADAESGCM, string(make([]byte, 256)), make([]byte, 16)); err == nil {
+		t.Errorf("NewAEAD(long key id):\n Expect => error\n Got => nil\n")
+	}
+}
+
+func TestAEADEnvelopeFormat(t *testing.T) {
+	// 按文档中的格式手工构造密文,确认与Seal的格式一致
+	key := []byte("0123456789abcdef")
+	nonce := []byte("unique nonce")
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	header := []byte{1, byte(AEADAESGCM), 2, 'i', 'd'}
+	data := append(append(append([]byte(nil), header...), nonce...),
+		gcm.Seal(nil, nonce, []byte("payload"), append(append([]byte(nil), header...), "aad"...))...)
+	a, _ := NewAEAD(AEADAESGCM, "id", key)
+	if got, err := a.Open(data, []byte("aad")); err != nil || string(got) != "payload" {
+		t.Errorf("Open:\n Expect => payload\n Got => %s %v\n", got, err)
+	}
+
+	c, err := AEADEncrypt([]byte("legacy"), key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := AEADDecrypt(c, key, nil); err != nil || string(got) != "legacy" {
+		t.Errorf("AEADDecrypt:\n Expect => legacy\n Got => %s %v\n", got, err)
+	}
+}
+
+func TestPKCS7UnPadding(t *testing.T) {
+	valid := map[string]string{
+		"abc\x05\x05\x05\x05\x05":                  "abc",
+		"abcdefg\x01":                              "abcdefg",
+		"\x08\x08\x08\x08\x08\x08\x08\x08":         "",
+		"12345678abc\x05\x05\x05\x05\x05":          "12345678abc",
+		"12345678\x08\x08\x08\x08\x08\x08\x08\x08": "12345678",
+	}
+	for in, expected := range valid {
+		if got, err := PKCS7UnPadding([]byte(in), 8); err != nil || string(got) != expected {
+			t.Errorf("PKCS7UnPadding(%q):\n Expect => %q\n Got => %q %v\n", in, expected, got, err)
+		}
+	}
+	invalid := []string{
+		"",
+		"abcdefg",                 // 长度不是分组的整数倍
+		"abcdefg\x00",             // 填充为0
+		"abcdefg\x09",             // 填充超过分组长度
+		"abc\x05\x05\x04\x05\x05", // 填充字节不一致
+		"\xff\xff\xff\xff\xff\xff\xff\xff",
+	}
+	for _, in := range invalid {
+		if got, err := PKCS7UnPadding([]byte(in), 8); !errors.Is(err, ErrInvalidPadding) {
+			t.Errorf("PKCS7UnPadding(%q):\n Expect => %v\n Got => %q %v\n", in, ErrInvalidPadding, got, err)
+		}
+		if got := PKCS5UnPadding([]byte(in)); in != "abcdefg" && got != nil {
+			t.Errorf("PKCS5UnPadding(%q):\n Expect => nil\n Got => %q\n", in, got)
+		}
+	}
+	if got := PKCS5UnPadding([]byte("abc\x05\x05\x05\x05\x05")); string(got) != "abc" {
+		t.Errorf("PKCS5UnPadding:\n Expect => abc\n Got => %q\n", got)
+	}
+
+	// 填充不修改调用方的数据
+	buf := make([]byte, 3, 16)
+	copy(buf, "abc")
+	PKCS5Padding(buf, 8)
+	if buf[:4][3] != 0 {
+		t.Errorf("PKCS5Padding:\n Expect => input untouched\n Got => %q\n", buf[:8])
+	}
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	aesKey := []byte("0123456789abcdef")
+	desKey := []byte("01234567")
+	plaintext := "hello, world, 123"
+	c, err := AesEncrypt([]byte(plaintext), aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := AesDecrypt(c, aesKey); err != nil || string(got) != plaintext {
+		t.Errorf("AesDecrypt:\n Expect => %s\n Got => %q %v\n", plaintext, got, err)
+	}
+	d, err := Encrypt([]byte("hello"), desKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decrypt(d, desKey); err != nil || string(got) != "hello" {
+		t.Errorf("Decrypt:\n Expect => hello\n Got => %q %v\n", got, err)
+	}
+
+	for _, data := range [][]byte{nil, {}, c[:15], c[:17], append(c, 1)} {
+		if _, err := AesDecrypt(data, aesKey); !errors.Is(err, ErrCiphertextLength) {
+			t.Errorf("AesDecrypt(len %d):\n Expect => %v\n Got => %v\n", len(data), ErrCiphertextLength, err)
+		}
+	}
+	for _, data := range [][]byte{nil, d[:7], append(d, 1)} {
+		if _, err := Decrypt(data, desKey); !errors.Is(err, ErrCiphertextLength) {
+			t.Errorf("Decrypt(len %d):\n Expect => %v\n Got => %v\n", len(data), ErrCiphertextLength, err)
+		}
+	}
+	// 错误的密钥或被篡改的密文得到错误的填充,不会panic
+	failures := 0
+	for i := 0; i < 256; i++ {
+		tampered := append([]byte(nil), c...)
+		tampered[0] ^= byte(i)
+		tampered[len(tampered)-1] ^= byte(i)
+		if _, err := AesDecrypt(tampered, aesKey); err != nil {
+			if !errors.Is(err, ErrInvalidPadding) {
+				t.Fatalf("AesDecrypt(tampered):\n Expect => %v\n Got => %v\n", ErrInvalidPadding, err)
+			}
+			failures++
+		}
+	}
+	if failures == 0 {
+		t.Errorf("AesDecrypt(tampered):\n Expect => some %v\n Got => none\n", ErrInvalidPadding)
+	}
+	if _, err := AesDecrypt(c, []byte("fedcba9876543210")); err != nil && !errors.Is(err, ErrInvalidPadding) {
+		t.Errorf("AesDecrypt(wrong key):\n Expect => %v\n Got => %v\n", ErrInvalidPadding, err)
+	}
+}
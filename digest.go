@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Encoding 二进制数据的文本编码
+type Encoding int
+
+const (
+	// EncodingHex 小写十六进制,解码时不区分大小写
+	EncodingHex Encoding = iota + 1
+	// EncodingBase64 标准base64,带填充
+	EncodingBase64
+	// EncodingBase64URL URL安全的base64,不带填充(JWT等使用)
+	EncodingBase64URL
+	// EncodingBase32 标准base32,带填充
+	EncodingBase32
+	// EncodingBase58 比特币字母表的base58
+	EncodingBase58
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingHex:
+		return "hex"
+	case EncodingBase64:
+		return "base64"
+	case EncodingBase64URL:
+		return "base64url"
+	case EncodingBase32:
+		return "base32"
+	case EncodingBase58:
+		return "base58"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// EncodeToString 编码,未知编码时panic
+func (e Encoding) EncodeToString(b []byte) string {
+	switch e {
+	case EncodingHex:
+		return hex.EncodeToString(b)
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(b)
+	case EncodingBase32:
+		return base32.StdEncoding.EncodeToString(b)
+	case EncodingBase58:
+		return EncodeBase58(b)
+	}
+	panic("digest: unknown encoding " + e.String())
+}
+
+// DecodeString 解码
+func (e Encoding) DecodeString(s string) ([]byte, error) {
+	switch e {
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	case EncodingBase32:
+		return base32.StdEncoding.DecodeString(s)
+	case EncodingBase58:
+		return DecodeBase58(s)
+	}
+	return nil, fmt.Errorf("digest: unknown encoding %s", e)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() (idx [256]int8) {
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		idx[base58Alphabet[i]] = int8(i)
+	}
+	return
+}()
+
+// ErrBase58 base58字符串包含非法字符
+var ErrBase58 = errors.New("base58: invalid character")
+
+// EncodeBase58 使用比特币字母表编码,每个前导0字节编码为'1'
+func EncodeBase58(b []byte) string {
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+	// log(256)/log(58) < 1.37
+	digits := make([]byte, 0, (len(b)-zeros)*137/100+1)
+	for _, c := range b[zeros:] {
+		carry := int(c)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = '1'
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+	return string(out)
+}
+
+// DecodeBase58 解码EncodeBase58的结果
+func DecodeBase58(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	// log(58)/log(256) < 0.74
+	digits := make([]byte, 0, (len(s)-zeros)*74/100+1)
+	for i := zeros; i < len(s); i++ {
+		v := base58Index[s[i]]
+		if v < 0 {
+			return nil, fmt.Errorf("%w %q at offset %d", ErrBase58, s[i], i)
+		}
+		carry := int(v)
+		for j := range digits {
+			carry += int(digits[j]) * 58
+			digits[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry))
+			carry >>= 8
+		}
+	}
+	out := make([]byte, zeros+len(digits))
+	for i, c := range digits {
+		out[len(out)-1-i] = c
+	}
+	return out, nil
+}
+
+// Digest 摘要或HMAC的结果,可以按需要的格式输出
+type Digest []byte
+
+// Bytes 原始字节
+func (d Digest) Bytes() []byte {
+	return []byte(d)
+}
+
+// Hex 小写十六进制
+func (d Digest) Hex() string {
+	return hex.EncodeToString(d)
+}
+
+// Base64 标准base64
+func (d Digest) Base64() string {
+	return base64.StdEncoding.EncodeToString(d)
+}
+
+// Base64URL 不带填充的URL安全base64
+func (d Digest) Base64URL() string {
+	return base64.RawURLEncoding.EncodeToString(d)
+}
+
+// Base58 比特币字母表的base58
+func (d Digest) Base58() string {
+	return EncodeBase58(d)
+}
+
+// Encode 按指定编码输出
+func (d Digest) Encode(e Encoding) string {
+	return e.EncodeToString(d)
+}
+
+// String 与Hex相同
+func (d Digest) String() string {
+	return d.Hex()
+}
+
+// Equal 常量时间比较,用于比较MAC等需要防止时序攻击的值
+func (d Digest) Equal(other []byte) bool {
+	return hmac.Equal(d, other)
+}
+
+// newHash 创建摘要,未知算法时panic,与crypto.Hash.New的行为一致
+func (h HashAlgorithm) newHash() hash.Hash {
+	d := h.New()
+	if d == nil {
+		panic("digest: unknown hash algorithm " + h.String())
+	}
+	return d
+}
+
+// canHMAC 是否可用于HMAC,CRC32和xxHash不是密码学摘要,不能用于HMAC
+func (h HashAlgorithm) canHMAC() bool {
+	return h != HashCRC32 && h != HashXXHash64 && h.New() != nil
+}
+
+// newHMAC 创建HMAC,算法不能用于HMAC时panic
+func (h HashAlgorithm) newHMAC(key []byte) hash.Hash {
+	if !h.canHMAC() {
+		panic("digest: " + h.String() + " cannot be used for HMAC")
+	}
+	return hmac.New(h.New, key)
+}
+
+// Hash 计算data的摘要,例如 Hash(HashSHA256, data).Hex()
+func Hash(alg HashAlgorithm, data []byte) Digest {
+	d := alg.newHash()
+	d.Write(data)
+	return d.Sum(nil)
+}
+
+// HashString 计算字符串的摘要
+func HashString(alg HashAlgorithm, s string) Digest {
+	return Hash(alg, []byte(s))
+}
+
+// HashReader 流式计算r的摘要
+func HashReader(alg HashAlgorithm, r io.Reader) (Digest, error) {
+	d := alg.New()
+	if d == nil {
+		return nil, fmt.Errorf("digest: unknown hash algorithm %s", alg)
+	}
+	if _, err := io.Copy(d, r); err != nil {
+		return nil, err
+	}
+	return d.Sum(nil), nil
+}
+
+// HMAC 计算data的HMAC,例如 HMAC(HashSHA256, secret, body).Base64();算法未知或为CRC32、xxHash时panic
+func HMAC(alg HashAlgorithm, key, data []byte) Digest {
+	m := alg.newHMAC(key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// HMACString 计算字符串的HMAC
+func HMACString(alg HashAlgorithm, key []byte, s string) Digest {
+	return HMAC(alg, key, []byte(s))
+}
+
+// HMACReader 流式计算r的HMAC
+func HMACReader(alg HashAlgorithm, key []byte, r io.Reader) (Digest, error) {
+	if !alg.canHMAC() {
+		return nil, fmt.Errorf("digest: %s cannot be used for HMAC", alg)
+	}
+	m := alg.newHMAC(key)
+	if _, err := io.Copy(m, r); err != nil {
+		return nil, err
+	}
+	return m.Sum(nil), nil
+}
+
+// VerifyHMAC 使用常量时间比较校验data的HMAC,算法未知或不能用于HMAC时返回false
+func VerifyHMAC(alg HashAlgorithm, key, data, mac []byte) bool {
+	if !alg.canHMAC() {
+		return false
+	}
+	return HMAC(alg, key, data).Equal(mac)
+}
+
+// VerifyHMACString 校验编码后的HMAC,例如Webhook请求头中的十六进制或base64签名;mac无法解码或算法不能用于HMAC时返回false
+func VerifyHMACString(alg HashAlgorithm, key, data []byte, mac string, enc Encoding) bool {
+	b, err := enc.DecodeString(mac)
+	if err != nil {
+		return false
+	}
+	return VerifyHMAC(alg, key, data, b)
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	cases := []struct {
+		alg      HashAlgorithm
+		expected string
+	}{
+		{HashMD5, "900150983cd24fb0d6963f7d28e17f72"},
+		{HashSHA1, "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{HashSHA224, "23097d223405d8228642a477bda255b32aadbce4bda0b3f7e36c9da7"},
+		{HashSHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{HashSHA384, "cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7"},
+		{HashSM3, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		{HashCRC32, "352441c2"},
+	}
+	for _, c := range cases {
+		if got := Hash(c.alg, []byte("abc")).Hex(); got != c.expected {
+			t.Errorf("Hash(%s):\n Expect => %s\n Got => %s\n", c.alg, c.expected, got)
+		}
+		if got := HashString(c.alg, "abc").String(); got != c.expected {
+			t.Errorf("HashString(%s):\n Expect => %s\n Got => %s\n", c.alg, c.expected, got)
+		}
+		if got, err := HashReader(c.alg, strings.NewReader("abc")); err != nil || got.Hex() != c.expected {
+			t.Errorf("HashReader(%s):\n Expect => %s\n Got => %s %v\n", c.alg, c.expected, got, err)
+		}
+	}
+	if _, err := HashReader(0, strings.NewReader("abc")); err == nil {
+		t.Errorf("HashReader(unknown):\n Expect => error\n Got => nil\n")
+	}
+	// MD5和SHA1保持原有的输出格式
+	if got := MD5("abc"); got != cases[0].expected {
+		t.Errorf("MD5:\n Expect => %s\n Got => %s\n", cases[0].expected, got)
+	}
+	if got := SHA1([]byte("abc")); Digest(got).Hex() != cases[1].expected {
+		t.Errorf("SHA1:\n Expect => %s\n Got => %x\n", cases[1].expected, got)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	// RFC 2202 / RFC 4231 测试用例2,HMAC-SM3由openssl dgst -sm3 -hmac生成
+	key := []byte("Jefe")
+	data := []byte("what do ya want for nothing?")
+	cases := []struct {
+		alg      HashAlgorithm
+		expected string
+	}{
+		{HashMD5, "750c783e6ab0b503eaa86e310a5db738"},
+		{HashSHA1, "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79"},
+		{HashSHA256, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{HashSHA512, "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"},
+		{HashSM3, "2e87f1d16862e6d964b50a5200bf2b10b764faa9680a296a2405f24bec39f882"},
+	}
+	for _, c := range cases {
+		mac := HMAC(c.alg, key, data)
+		if mac.Hex() != c.expected {
+			t.Errorf("HMAC(%s):\n Expect => %s\n Got => %s\n", c.alg, c.expected, mac)
+		}
+		if got, err := HMACReader(c.alg, key, bytes.NewReader(data)); err != nil || !got.Equal(mac) {
+			t.Errorf("HMACReader(%s):\n Expect => %s\n Got => %s %v\n", c.alg, c.expected, got, err)
+		}
+		if !VerifyHMAC(c.alg, key, data, mac) || VerifyHMAC(c.alg, key, data, mac[1:]) || VerifyHMAC(c.alg, []byte("jefe"), data, mac) {
+			t.Errorf("VerifyHMAC(%s):\n Expect => only the correct mac is accepted\n", c.alg)
+		}
+		for _, enc := range []Encoding{EncodingHex, EncodingBase64, EncodingBase64URL, EncodingBase32, EncodingBase58} {
+			if !VerifyHMACString(c.alg, key, data, mac.Encode(enc), enc) {
+				t.Errorf("VerifyHMACString(%s, %s):\n Expect => true\n Got => false\n", c.alg, enc)
+			}
+		}
+	}
+	if VerifyHMACString(HashSHA256, key, data, "not hex", EncodingHex) {
+		t.Errorf("VerifyHMACString(invalid):\n Expect => false\n Got => true\n")
+	}
+	if got := HMACString(HashSM3, key, string(data)); !bytes.Equal(got, HmacSM3(key, data)) {
+		t.Errorf("HMACString(sm3):\n Expect => %x\n Got => %s\n", HmacSM3(key, data), got)
+	}
+	if _, err := HMACReader(HashCRC32, key, bytes.NewReader(data)); err == nil {
+		t.Errorf("HMACReader(crc32):\n Expect => error\n Got => nil\n")
+	}
+	// 算法来自配置等外部输入时,校验只返回false而不panic
+	for _, alg := range []HashAlgorithm{HashCRC32, HashXXHash64, HashAlgorithm(0), HashAlgorithm(255)} {
+		if VerifyHMAC(alg, key, data, []byte("mac")) || VerifyHMACString(alg, key, data, "6d6163", EncodingHex) {
+			t.Errorf("VerifyHMAC(%s):\n Expect => false\n Got => true\n", alg)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// draft-msporny-base58 与 RFC 4648 的测试向量
+	cases := []struct {
+		enc      Encoding
+		data     []byte
+		expected string
+	}{
+		{EncodingBase58, []byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+		{EncodingBase58, []byte("The quick brown fox jumps over the lazy dog."), "USm3fpXnKG5EUBx2ndxBDMPVciP5hGey2Jh4NDv6gmeo1LkMeiKrLJUUBk6Z"},
+		{EncodingBase58, []byte{0, 0, 0x28, 0x7f, 0xb4, 0xcd}, "11233QC4"},
+		{EncodingBase58, []byte{0}, "1"},
+		{EncodingBase58, nil, ""},
+		{EncodingBase32, []byte("foobar"), "MZXW6YTBOI======"},
+		{EncodingBase64, []byte("foob"), "Zm9vYg=="},
+		{EncodingBase64URL, []byte{0xfb, 0xff}, "-_8"},
+		{EncodingHex, []byte{0xab, 0x01}, "ab01"},
+	}
+	for _, c := range cases {
+		if got := c.enc.EncodeToString(c.data); got != c.expected {
+			t.Errorf("EncodeToString(%s):\n Expect => %s\n Got => %s\n", c.enc, c.expected, got)
+		}
+		if got, err := c.enc.DecodeString(c.expected); err != nil || !bytes.Equal(got, c.data) {
+			t.Errorf("DecodeString(%s):\n Expect => %x\n Got => %x %v\n", c.enc, c.data, got, err)
+		}
+	}
+	d := Digest{0xfb, 0xff}
+	if d.Base64() != "+/8=" || d.Base64URL() != "-_8" || d.Hex() != "fbff" || d.Base58() != "LBG" || !bytes.Equal(d.Bytes(), []byte{0xfb, 0xff}) {
+		t.Errorf("Digest:\n Got => %s %s %s %s\n", d.Base64(), d.Base64URL(), d.Hex(), d.Base58())
+	}
+	for _, s := range []string{"0OIl", "abc+"} {
+		if _, err := DecodeBase58(s); err == nil {
+			t.Errorf("DecodeBase58(%q):\n Expect => error\n Got => nil\n", s)
+		}
+	}
+}
@@ -3,8 +3,6 @@ package utils
 import (
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"math"
 	"os"
 	"path"
@@ -64,7 +62,8 @@ func FileSize(file string) (int64, error) {
 }
 
 // 从源地址复制到目标地址
-func Copy(src, dest string) error {
+// 目标文件原子写入,指定checksums时在复制过程中校验源文件的摘要,不一致时不写入目标文件并返回包装了ErrChecksumMismatch的错误
+func Copy(src, dest string, checksums ...Checksum) error {
 	// Gather file information to set back later.
 	si, err := os.Lstat(src)
 	if err != nil {
@@ -88,13 +87,16 @@ func Copy(src, dest string) error {
 	}
 	defer sr.Close()
 
-	dw, err := os.Create(dest)
+	dw, err := NewAtomicFile(dest, AtomicOptions{Perm: si.Mode().Perm()})
 	if err != nil {
 		return err
 	}
 	defer dw.Close()
 
-	if _, err = io.Copy(dw, sr); err != nil {
+	if err = copyVerify(dw, sr, checksums); err != nil {
+		return err
+	}
+	if err = dw.Commit(); err != nil {
 		return err
 	}
 
@@ -107,9 +109,9 @@ func Copy(src, dest string) error {
 
 // 将数据写入文件名指定文件。
 // 如果文件不存在，Write File将创建它及其上层路径。
+// 写入是原子的,失败或崩溃时原文件保持不变;已有文件保留原权限,新文件权限为0644,其他选项请使用WriteFileAtomic。
 func WriteFile(filename string, data []byte) error {
-	os.MkdirAll(path.Dir(filename), os.ModePerm)
-	return ioutil.WriteFile(filename, data, 0655)
+	return WriteFileAtomic(filename, data, AtomicOptions{})
 }
 
 // 判断给定路径是不是文件以及是否存在
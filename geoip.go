@@ -0,0 +1,510 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrGeoIPNotFound 数据库中没有该IP的记录
+	ErrGeoIPNotFound = errors.New("geoip: address not found")
+	// ErrGeoIPClosed GeoIP已经关闭
+	ErrGeoIPClosed = errors.New("geoip: closed")
+)
+
+// GeoIPRecord IP的地理位置和自治系统信息,数据库中没有的字段为零值
+type GeoIPRecord struct {
+	IP string `json:"ip"`
+	// Network 命中的地址段
+	Network       string  `json:"network,omitempty"`
+	ContinentCode string  `json:"continent_code,omitempty"`
+	Continent     string  `json:"continent,omitempty"`
+	CountryCode   string  `json:"country_code,omitempty"`
+	Country       string  `json:"country,omitempty"`
+	RegionCode    string  `json:"region_code,omitempty"`
+	Region        string  `json:"region,omitempty"`
+	City          string  `json:"city,omitempty"`
+	PostalCode    string  `json:"postal_code,omitempty"`
+	TimeZone      string  `json:"time_zone,omitempty"`
+	Latitude      float64 `json:"latitude,omitempty"`
+	Longitude     float64 `json:"longitude,omitempty"`
+	ASN           uint32  `json:"asn,omitempty"`
+	ASOrg         string  `json:"as_org,omitempty"`
+}
+
+// merge 用other补全record中为空的字段
+func (record *GeoIPRecord) merge(other GeoIPRecord) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&record.Network, other.Network)
+	fill(&record.ContinentCode, other.ContinentCode)
+	fill(&record.Continent, other.Continent)
+	fill(&record.CountryCode, other.CountryCode)
+	fill(&record.Country, other.Country)
+	fill(&record.RegionCode, other.RegionCode)
+	fill(&record.Region, other.Region)
+	fill(&record.City, other.City)
+	fill(&record.PostalCode, other.PostalCode)
+	fill(&record.TimeZone, other.TimeZone)
+	fill(&record.ASOrg, other.ASOrg)
+	if record.Latitude == 0 && record.Longitude == 0 {
+		record.Latitude, record.Longitude = other.Latitude, other.Longitude
+	}
+	if record.ASN == 0 {
+		record.ASN = other.ASN
+	}
+}
+
+// geoDB 一个已打开的数据库文件
+type geoDB interface {
+	lookup(ip net.IP, lang string) (GeoIPRecord, bool, error)
+	close() error
+}
+
+// geoSource 数据库文件及其当前版本
+type geoSource struct {
+	filename string
+	stamp    string
+	db       geoDB
+}
+
+// GeoIP 离线IP地理位置查询,支持MaxMind DB(.mmdb)和CSV区间格式(见parseGeoCSV)。
+//
+// 可以同时打开多个文件,例如城市库和ASN库,查询结果按打开顺序合并。
+// MMDB文件通过mmap映射到内存;GeoIP可以并发查询,Reload和WatchFile会在文件变化后原子替换数据库。
+// 更新数据库时应先写入临时文件再重命名覆盖,直接改写已映射的文件会破坏正在进行的查询。
+type GeoIP struct {
+	// reloadMu 在Reload和Close的整个过程中持有,保证旧数据库只被关闭一次
+	reloadMu sync.Mutex
+	mu       sync.RWMutex
+	sources  []*geoSource
+	// closed 同时持有reloadMu和mu时才能修改,持有其中任一把锁即可读取
+	closed bool
+	// Language 地名使用的语言,默认为"en";没有该语言时使用英文名称
+	Language string
+	// Logger WatchFile重新加载失败时用于记录错误,为nil时使用标准库log
+	Logger *log.Logger
+	// IPResolver LookupRequest解析客户端IP的可信代理解析器,为nil时使用RemoteAddr
+	IPResolver *IPResolver
+}
+
+// OpenGeoIP 打开一个或多个数据库文件,扩展名为.csv的文件按CSV区间格式(见parseGeoCSV)解析,其余按MMDB解析。
+func OpenGeoIP(filenames ...string) (*GeoIP, error) {
+	if len(filenames) == 0 {
+		return nil, errors.New("geoip: no database file")
+	}
+	g := &GeoIP{Language: "en"}
+	for _, filename := range filenames {
+		src, err := openGeoSource(filename)
+		if err != nil {
+			g.Close()
+			return nil, err
+		}
+		g.sources = append(g.sources, src)
+	}
+	return g, nil
+}
+
+func openGeoSource(filename string) (*geoSource, error) {
+	// 先取版本再打开,避免遗漏打开过程中发生的修改
+	stamp := fileStamp(filename)
+	var db geoDB
+	var err error
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		db, err = openGeoCSV(filename)
+	} else {
+		db, err = openGeoMMDB(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("geoip: open %s: %w", filename, err)
+	}
+	return &geoSource{filename: filename, stamp: stamp, db: db}, nil
+}
+
+// Lookup 查询ip的地理位置,所有数据库中都没有记录时返回ErrGeoIPNotFound
+func (g *GeoIP) Lookup(ip string) (GeoIPRecord, error) {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return GeoIPRecord{IP: ip}, fmt.Errorf("geoip: invalid IP address %q", ip)
+	}
+	return g.LookupIP(addr)
+}
+
+// LookupIP 查询ip的地理位置,所有数据库中都没有记录时返回ErrGeoIPNotFound
+func (g *GeoIP) LookupIP(ip net.IP) (GeoIPRecord, error) {
+	record := GeoIPRecord{IP: ip.String()}
+	lang := g.Language
+	if lang == "" {
+		lang = "en"
+	}
+	found := false
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.closed {
+		return record, ErrGeoIPClosed
+	}
+	for _, src := range g.sources {
+		r, ok, err := src.db.lookup(ip, lang)
+		if err != nil {
+			return record, err
+		}
+		if ok {
+			record.merge(r)
+			found = true
+		}
+	}
+	if !found {
+		return record, ErrGeoIPNotFound
+	}
+	return record, nil
+}
+
+// LookupRequest 查询客户端IP的地理位置。客户端IP由IPResolver解析,未设置时使用RemoteAddr,
+// 不读取X-Forwarded-For等可被伪造的请求头;部署在代理之后时必须设置IPResolver。
+func (g *GeoIP) LookupRequest(r *http.Request) (GeoIPRecord, error) {
+	return g.Lookup(requestIP(r, g.IPResolver))
+}
+
+// Reload 重新打开自上次加载以来发生变化的文件,任一文件打开失败时保留全部原有数据库。
+// 关闭后返回ErrGeoIPClosed。
+func (g *GeoIP) Reload() error {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+	if g.closed {
+		return ErrGeoIPClosed
+	}
+	// 只有持有reloadMu时才会修改sources,这里不需要读锁
+	sources := make([]*geoSource, len(g.sources))
+	copy(sources, g.sources)
+
+	var opened, old []*geoSource
+	for i, src := range sources {
+		if stamp := fileStamp(src.filename); stamp == src.stamp {
+			continue
+		}
+		next, err := openGeoSource(src.filename)
+		if err != nil {
+			for _, s := range opened {
+				s.db.close()
+			}
+			return err
+		}
+		opened = append(opened, next)
+		old = append(old, src)
+		sources[i] = next
+	}
+	if len(opened) == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	g.sources = sources
+	g.mu.Unlock()
+	// 写锁保证已没有正在进行的查询,可以安全地解除旧文件的映射
+	var err error
+	for _, s := range old {
+		if e := s.db.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// WatchFile 按interval检查数据库文件,发生变化时重新加载,返回的函数用于停止检查。
+func (g *GeoIP) WatchFile(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := g.Reload(); err != nil {
+					g.logger().Printf("geoip: reload: %v", err)
+				}
+			}
+		}
+	}()
+	var once int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&once, 0, 1) {
+			close(done)
+		}
+	}
+}
+
+// Close 关闭全部数据库,之后查询和Reload返回ErrGeoIPClosed,重复调用时直接返回nil
+func (g *GeoIP) Close() error {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	var err error
+	for _, src := range g.sources {
+		if e := src.db.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	g.sources = nil
+	return err
+}
+
+func (g *GeoIP) logger() *log.Logger {
+	if g.Logger != nil {
+		return g.Logger
+	}
+	return log.Default()
+}
+
+// geoMMDB MaxMind DB格式的数据库,如GeoLite2-City、GeoLite2-ASN
+type geoMMDB struct {
+	reader *mmdbReader
+	unmap  func() error
+}
+
+func openGeoMMDB(filename string) (*geoMMDB, error) {
+	buf, unmap, err := mmapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newMMDBReader(buf)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	return &geoMMDB{reader: r, unmap: unmap}, nil
+}
+
+func (db *geoMMDB) lookup(ip net.IP, lang string) (GeoIPRecord, bool, error) {
+	v, prefix, err := db.reader.lookup(ip)
+	if err != nil || v == nil {
+		return GeoIPRecord{}, false, err
+	}
+	m, _ := v.(map[string]interface{})
+	record := GeoIPRecord{}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	record.Network = (&net.IPNet{IP: ip.Mask(net.CIDRMask(prefix, bits)), Mask: net.CIDRMask(prefix, bits)}).String()
+
+	continent := mmdbGetMap(m, "continent")
+	record.ContinentCode = mmdbGetString(continent, "code")
+	record.Continent = mmdbGetName(continent, lang)
+	country := mmdbGetMap(m, "country")
+	if country == nil {
+		country = mmdbGetMap(m, "registered_country")
+	}
+	record.CountryCode = mmdbGetString(country, "iso_code")
+	record.Country = mmdbGetName(country, lang)
+	if subdivisions, ok := m["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		region, _ := subdivisions[0].(map[string]interface{})
+		record.RegionCode = mmdbGetString(region, "iso_code")
+		record.Region = mmdbGetName(region, lang)
+	}
+	record.City = mmdbGetName(mmdbGetMap(m, "city"), lang)
+	record.PostalCode = mmdbGetString(mmdbGetMap(m, "postal"), "code")
+	location := mmdbGetMap(m, "location")
+	record.TimeZone = mmdbGetString(location, "time_zone")
+	record.Latitude, _ = location["latitude"].(float64)
+	record.Longitude, _ = location["longitude"].(float64)
+	record.ASN = uint32(mmdbUint(m["autonomous_system_number"]))
+	record.ASOrg = mmdbGetString(m, "autonomous_system_organization")
+	return record, true, nil
+}
+
+func (db *geoMMDB) close() error {
+	return db.unmap()
+}
+
+func mmdbGetMap(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+func mmdbGetString(m map[string]interface{}, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
+
+// mmdbGetName 取names中指定语言的名称,没有时使用英文名称
+func mmdbGetName(m map[string]interface{}, lang string) string {
+	names := mmdbGetMap(m, "names")
+	if name := mmdbGetString(names, lang); name != "" {
+		return name
+	}
+	return mmdbGetString(names, "en")
+}
+
+// geoRange CSV中的一行
+type geoRange struct {
+	ipRange
+	record GeoIPRecord
+}
+
+// geoCSV CSV区间格式的数据库,区间按起始地址排序且互不重叠
+type geoCSV struct {
+	v4, v6 []geoRange
+}
+
+func openGeoCSV(filename string) (*geoCSV, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseGeoCSV(f)
+}
+
+// parseGeoCSV 解析CSV区间格式的数据库。每行的列依次为:
+//
+//	start_ip,end_ip,country_code,country,region,city,latitude,longitude,asn,as_org
+//
+// start_ip和end_ip为闭区间,同一行必须同为IPv4或IPv6,区间之间不能重叠;
+// end_ip之后的列都可以省略或留空。空行、以#开头的行以及首行的表头会被忽略。
+func parseGeoCSV(r io.Reader) (*geoCSV, error) {
+	db := &geoCSV{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.TrimLeadingSpace = true
+		fields, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("geoip csv line %d: %w", line, err)
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("geoip csv line %d: expect at least start_ip,end_ip", line)
+		}
+		start, end := parseGeoCSVIP(fields[0]), parseGeoCSVIP(fields[1])
+		if start == nil && line == 1 {
+			continue // 表头
+		}
+		if start == nil || end == nil || len(start) != len(end) || bytes.Compare(start, end) > 0 {
+			return nil, fmt.Errorf("geoip csv line %d: invalid range %q-%q", line, fields[0], fields[1])
+		}
+		g := geoRange{ipRange: ipRange{start: start, end: end}}
+		if g.record, err = parseGeoCSVRecord(fields[2:]); err != nil {
+			return nil, fmt.Errorf("geoip csv line %d: %w", line, err)
+		}
+		if len(start) == net.IPv4len {
+			db.v4 = append(db.v4, g)
+		} else {
+			db.v6 = append(db.v6, g)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, ranges := range [][]geoRange{db.v4, db.v6} {
+		sort.Slice(ranges, func(i, j int) bool {
+			return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+		})
+		for i := 1; i < len(ranges); i++ {
+			if bytes.Compare(ranges[i].start, ranges[i-1].end) <= 0 {
+				return nil, fmt.Errorf("geoip csv: range %s-%s overlaps %s-%s",
+					ranges[i].start, ranges[i].end, ranges[i-1].start, ranges[i-1].end)
+			}
+		}
+	}
+	return db, nil
+}
+
+// parseGeoCSVIP 解析地址,IPv4返回4字节
+func parseGeoCSVIP(s string) net.IP {
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+func parseGeoCSVRecord(fields []string) (GeoIPRecord, error) {
+	get := func(i int) string {
+		if i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	record := GeoIPRecord{
+		CountryCode: get(0),
+		Country:     get(1),
+		Region:      get(2),
+		City:        get(3),
+		ASOrg:       get(7),
+	}
+	var err error
+	if s := get(4); s != "" {
+		if record.Latitude, err = strconv.ParseFloat(s, 64); err != nil {
+			return record, fmt.Errorf("invalid latitude %q", s)
+		}
+	}
+	if s := get(5); s != "" {
+		if record.Longitude, err = strconv.ParseFloat(s, 64); err != nil {
+			return record, fmt.Errorf("invalid longitude %q", s)
+		}
+	}
+	if s := strings.TrimPrefix(strings.ToUpper(get(6)), "AS"); s != "" {
+		asn, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return record, fmt.Errorf("invalid asn %q", get(6))
+		}
+		record.ASN = uint32(asn)
+	}
+	return record, nil
+}
+
+func (db *geoCSV) lookup(ip net.IP, _ string) (GeoIPRecord, bool, error) {
+	ranges := db.v6
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, ranges = ip4, db.v4
+	} else if len(ip) != net.IPv6len {
+		return GeoIPRecord{}, false, nil
+	}
+	i := sort.Search(len(ranges), func(i int) bool {
+		return bytes.Compare(ranges[i].end, ip) >= 0
+	})
+	if i == len(ranges) || bytes.Compare(ranges[i].start, ip) > 0 {
+		return GeoIPRecord{}, false, nil
+	}
+	record := ranges[i].record
+	// 区间不一定是单个CIDR,取区间内包含ip的最大地址段
+	rangeToCIDRs(ranges[i].ipRange, func(n *net.IPNet) bool {
+		if n.Contains(ip) {
+			record.Network = n.String()
+			return false
+		}
+		return true
+	})
+	return record, true, nil
+}
+
+func (db *geoCSV) close() error {
+	return nil
+}
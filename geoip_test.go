@@ -0,0 +1,577 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mmdbTestPointer 写入指向数据区偏移量的指针
+type mmdbTestPointer uint
+
+// mmdbTestEncode 按MMDB格式编码测试数据
+func mmdbTestEncode(buf *bytes.Buffer, v interface{}) {
+	control := func(typ int, size int) {
+		var ext []byte
+		switch {
+		case size >= 65821:
+			s := size - 65821
+			ext = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+			size = 31
+		case size >= 285:
+			s := size - 285
+			ext = []byte{byte(s >> 8), byte(s)}
+			size = 30
+		case size >= 29:
+			ext = []byte{byte(size - 29)}
+			size = 29
+		}
+		if typ < 8 {
+			buf.WriteByte(byte(typ<<5 | size))
+		} else {
+			buf.WriteByte(byte(size))
+			buf.WriteByte(byte(typ - 7))
+		}
+		buf.Write(ext)
+	}
+	switch v := v.(type) {
+	case mmdbTestPointer:
+		switch {
+		case v < 2048:
+			buf.Write([]byte{byte(mmdbPointer<<5 | v>>8), byte(v)})
+		case v < 526336:
+			p := v - 2048
+			buf.Write([]byte{byte(mmdbPointer<<5 | 1<<3 | p>>16), byte(p >> 8), byte(p)})
+		default:
+			p := v - 526336
+			buf.Write([]byte{byte(mmdbPointer<<5 | 2<<3 | p>>24), byte(p >> 16), byte(p >> 8), byte(p)})
+		}
+	case string:
+		control(mmdbString, len(v))
+		buf.WriteString(v)
+	case float64:
+		control(mmdbDouble, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case float32:
+		control(mmdbFloat, 4)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(v))
+	case uint16:
+		control(mmdbUint16, 2)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		control(mmdbUint32, 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case int32:
+		control(mmdbInt32, 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint64:
+		control(mmdbUint64, 8)
+		binary.Write(buf, binary.BigEndian, v)
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		control(mmdbBool, n)
+	case []interface{}:
+		control(mmdbArray, len(v))
+		for _, e := range v {
+			mmdbTestEncode(buf, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		control(mmdbMap, len(keys))
+		for _, k := range keys {
+			mmdbTestEncode(buf, k)
+			mmdbTestEncode(buf, v[k])
+		}
+	default:
+		panic("unsupported mmdb test value")
+	}
+}
+
+type mmdbTestNode struct {
+	child [2]*mmdbTestNode
+	id    int
+	data  int // 叶子节点的数据偏移量,内部节点为-1
+}
+
+// writeTestMMDB 生成只包含给定地址段的IPv6数据库,IPv4地址段放在::/96之下。
+// shared中的值先写入数据区,其余数据可以通过mmdbTestPointer引用它们。
+func writeTestMMDB(t *testing.T, filename string, recordSize int, shared []interface{}, nets map[string]interface{}) {
+	var data bytes.Buffer
+	for _, v := range shared {
+		mmdbTestEncode(&data, v)
+	}
+	root := &mmdbTestNode{data: -1}
+	cidrs := make([]string, 0, len(nets))
+	for c := range nets {
+		cidrs = append(cidrs, c)
+	}
+	sort.Strings(cidrs)
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := n.Mask.Size()
+		ip := n.IP.To16()
+		if ip4 := n.IP.To4(); ip4 != nil {
+			ip = append(make(net.IP, 12), ip4...)
+			ones += 96
+		}
+		offset := data.Len()
+		mmdbTestEncode(&data, nets[c])
+		node := root
+		for i := 0; i < ones; i++ {
+			b := ip[i/8] >> (7 - uint(i%8)) & 1
+			if node.child[b] == nil {
+				node.child[b] = &mmdbTestNode{data: -1}
+			}
+			node = node.child[b]
+		}
+		node.data = offset
+	}
+	// 按广度优先为内部节点编号
+	var internal []*mmdbTestNode
+	for queue := []*mmdbTestNode{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		if n.data >= 0 {
+			continue
+		}
+		n.id = len(internal)
+		internal = append(internal, n)
+		for _, c := range n.child {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+	count := len(internal)
+	var file bytes.Buffer
+	for _, n := range internal {
+		var rec [2]uint32
+		for i, c := range n.child {
+			switch {
+			case c == nil:
+				rec[i] = uint32(count)
+			case c.data >= 0:
+				rec[i] = uint32(count + mmdbDataSeparator + c.data)
+			default:
+				rec[i] = uint32(c.id)
+			}
+		}
+		switch recordSize {
+		case 24:
+			file.Write([]byte{byte(rec[0] >> 16), byte(rec[0] >> 8), byte(rec[0]),
+				byte(rec[1] >> 16), byte(rec[1] >> 8), byte(rec[1])})
+		case 28:
+			file.Write([]byte{byte(rec[0] >> 16), byte(rec[0] >> 8), byte(rec[0]),
+				byte(rec[0]>>20&0xF0 | rec[1]>>24&0x0F),
+				byte(rec[1] >> 16), byte(rec[1] >> 8), byte(rec[1])})
+		default:
+			binary.Write(&file, binary.BigEndian, rec)
+		}
+	}
+	file.Write(make([]byte, mmdbDataSeparator))
+	file.Write(data.Bytes())
+	file.Write(mmdbMetadataMarker)
+	mmdbTestEncode(&file, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               "Test-City",
+		"description":                 map[string]interface{}{"en": "test database"},
+		"ip_version":                  uint16(6),
+		"languages":                   []interface{}{"en", "zh-CN"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(recordSize),
+	})
+	writeTestFileAtomic(t, filename, file.Bytes())
+}
+
+// writeTestFileAtomic 通过重命名替换文件,已映射的旧文件内容不受影响
+func writeTestFileAtomic(t *testing.T, filename string, data []byte) {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGeoCity(city string, shared mmdbTestPointer) map[string]interface{} {
+	return map[string]interface{}{
+		"city":      map[string]interface{}{"names": map[string]interface{}{"en": city}},
+		"continent": map[string]interface{}{"code": "NA", "names": map[string]interface{}{"en": "North America"}},
+		"country":   shared,
+		"location": map[string]interface{}{
+			"latitude":  37.751,
+			"longitude": -97.822,
+			"time_zone": "America/Chicago",
+		},
+		"postal":       map[string]interface{}{"code": "94043"},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "CA", "names": map[string]interface{}{"en": "California"}}},
+		"is_anycast":   true,
+	}
+}
+
+func TestMMDBReader(t *testing.T) {
+	dir := t.TempDir()
+	us := map[string]interface{}{
+		"iso_code": "US",
+		"names":    map[string]interface{}{"en": "United States", "zh-CN": "美国"},
+	}
+	for _, size := range []int{24, 28, 32} {
+		filename := filepath.Join(dir, "city.mmdb")
+		writeTestMMDB(t, filename, size, []interface{}{us}, map[string]interface{}{
+			"8.8.8.0/24":     testGeoCity("Mountain View", 0),
+			"2001:4860::/32": testGeoCity("Ashburn", 0),
+		})
+		g, err := OpenGeoIP(filename)
+		if err != nil {
+			t.Fatalf("OpenGeoIP(record size %d): %v", size, err)
+		}
+		meta := g.sources[0].db.(*geoMMDB).reader.meta
+		if meta.DatabaseType != "Test-City" || meta.IPVersion != 6 || meta.RecordSize != uint(size) ||
+			len(meta.Languages) != 2 || meta.Description["en"] != "test database" {
+			t.Errorf("mmdbMetadata(record size %d):\n Got => %+v\n", size, meta)
+		}
+		r, err := g.Lookup("8.8.8.8")
+		if err != nil {
+			t.Fatalf("Lookup(record size %d): %v", size, err)
+		}
+		expected := GeoIPRecord{
+			IP: "8.8.8.8", Network: "8.8.8.0/24",
+			ContinentCode: "NA", Continent: "North America",
+			CountryCode: "US", Country: "United States",
+			RegionCode: "CA", Region: "California",
+			City: "Mountain View", PostalCode: "94043", TimeZone: "America/Chicago",
+			Latitude: 37.751, Longitude: -97.822,
+		}
+		if r != expected {
+			t.Errorf("Lookup(record size %d):\n Expect => %+v\n Got => %+v\n", size, expected, r)
+		}
+		if r, err := g.Lookup("2001:4860:4860::8888"); err != nil || r.City != "Ashburn" || r.Network != "2001:4860::/32" {
+			t.Errorf("Lookup(IPv6, record size %d):\n Expect => Ashburn 2001:4860::/32\n Got => %+v %v\n", size, r, err)
+		}
+		g.Language = "zh-CN"
+		if r, _ := g.Lookup("8.8.8.8"); r.Country != "美国" || r.City != "Mountain View" {
+			t.Errorf("Lookup(zh-CN):\n Expect => 美国 Mountain View\n Got => %s %s\n", r.Country, r.City)
+		}
+		if r, err := g.Lookup("8.8.4.4"); !errors.Is(err, ErrGeoIPNotFound) || r.IP != "8.8.4.4" {
+			t.Errorf("Lookup(8.8.4.4):\n Expect => %v\n Got => %v\n", ErrGeoIPNotFound, err)
+		}
+		if _, err := g.Lookup("not an ip"); err == nil {
+			t.Errorf("Lookup(invalid):\n Expect => error\n Got => nil\n")
+		}
+		g.Close()
+	}
+}
+
+func TestMMDBDecoder(t *testing.T) {
+	long := string(bytes.Repeat([]byte("x"), 70000))
+	values := []interface{}{
+		"", "hello", string(bytes.Repeat([]byte("y"), 300)), long,
+		float64(1.5), uint16(80), uint32(1 << 31), int32(-5), uint64(1 << 40), true, false,
+		[]interface{}{"a", uint16(1)},
+	}
+	expected := []interface{}{
+		"", "hello", string(bytes.Repeat([]byte("y"), 300)), long,
+		float64(1.5), uint64(80), uint64(1 << 31), int64(-5), uint64(1 << 40), true, false,
+		[]interface{}{"a", uint64(1)},
+	}
+	for i, v := range values {
+		var buf bytes.Buffer
+		mmdbTestEncode(&buf, v)
+		d := mmdbDecoder{buf: buf.Bytes()}
+		got, next, err := d.decode(0)
+		if err != nil || next != uint(buf.Len()) {
+			t.Fatalf("decode(%d): next=%d len=%d err=%v", i, next, buf.Len(), err)
+		}
+		if s, ok := got.(string); ok && s != expected[i] || !ok && !equalMMDBValue(got, expected[i]) {
+			t.Errorf("decode(%d):\n Expect => %v\n Got => %v\n", i, expected[i], got)
+		}
+	}
+
+	// 三种长度的指针
+	for _, offset := range []uint{10, 3000, 600000} {
+		var buf bytes.Buffer
+		buf.Write(make([]byte, offset))
+		mmdbTestEncode(&buf, "target")
+		start := buf.Len()
+		mmdbTestEncode(&buf, mmdbTestPointer(offset))
+		d := mmdbDecoder{buf: buf.Bytes()}
+		if got, next, err := d.decode(uint(start)); err != nil || got != "target" || next != uint(buf.Len()) {
+			t.Errorf("decode(pointer %d):\n Expect => target\n Got => %v %v\n", offset, got, err)
+		}
+	}
+
+	// 损坏的数据只返回错误
+	corrupt := [][]byte{
+		{},
+		{0x5f},                   // 字符串长度超出数据
+		{0x20, 0x00},             // 指向自己的指针
+		{0xe1, 0xa1, 0x05, 0x40}, // map的key不是字符串
+		{0x1f, 0x04},             // 数组长度超出数据
+		{0x00},                   // 缺少扩展类型
+		{0x62, 0x00, 0x00},       // double长度错误
+	}
+	for i, b := range corrupt {
+		d := mmdbDecoder{buf: b}
+		if _, _, err := d.decode(0); !errors.Is(err, ErrMMDBInvalid) {
+			t.Errorf("decode(corrupt %d):\n Expect => %v\n Got => %v\n", i, ErrMMDBInvalid, err)
+		}
+	}
+	if _, err := newMMDBReader([]byte("not a database")); !errors.Is(err, ErrMMDBInvalid) {
+		t.Errorf("newMMDBReader:\n Expect => %v\n Got => %v\n", ErrMMDBInvalid, err)
+	}
+}
+
+func equalMMDBValue(a, b interface{}) bool {
+	if aa, ok := a.([]interface{}); ok {
+		bb, ok := b.([]interface{})
+		if !ok || len(aa) != len(bb) {
+			return false
+		}
+		for i := range aa {
+			if !equalMMDBValue(aa[i], bb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+const testGeoCSVData = `start_ip,end_ip,country_code,country,region,city,latitude,longitude,asn,as_org
+# 注释
+1.0.0.0,1.0.0.255,AU,Australia,Queensland,Brisbane,-27.47,153.02,AS13335,"Cloudflare, Inc."
+1.0.1.0,1.0.3.255,CN,China,Fujian,Fuzhou,26.06,119.30,4134,CHINANET
+
+2400:cb00::,2400:cb00:ffff:ffff:ffff:ffff:ffff:ffff,US,United States
+`
+
+func TestGeoIPCSV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ranges.csv")
+	if err := os.WriteFile(filename, []byte(testGeoCSVData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := OpenGeoIP(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	r, err := g.Lookup("1.0.0.1")
+	expected := GeoIPRecord{
+		IP: "1.0.0.1", Network: "1.0.0.0/24", CountryCode: "AU", Country: "Australia",
+		Region: "Queensland", City: "Brisbane", Latitude: -27.47, Longitude: 153.02,
+		ASN: 13335, ASOrg: "Cloudflare, Inc.",
+	}
+	if err != nil || r != expected {
+		t.Errorf("Lookup(1.0.0.1):\n Expect => %+v\n Got => %+v %v\n", expected, r, err)
+	}
+	// 1.0.1.0-1.0.3.255不是单个CIDR
+	if r, _ := g.Lookup("1.0.2.9"); r.Network != "1.0.2.0/23" || r.ASN != 4134 {
+		t.Errorf("Lookup(1.0.2.9):\n Expect => 1.0.2.0/23 AS4134\n Got => %+v\n", r)
+	}
+	if r, _ := g.Lookup("2400:cb00::1"); r.CountryCode != "US" || r.Network != "2400:cb00::/32" {
+		t.Errorf("Lookup(2400:cb00::1):\n Expect => US 2400:cb00::/32\n Got => %+v\n", r)
+	}
+	if _, err := g.Lookup("1.0.4.0"); !errors.Is(err, ErrGeoIPNotFound) {
+		t.Errorf("Lookup(1.0.4.0):\n Expect => %v\n Got => %v\n", ErrGeoIPNotFound, err)
+	}
+
+	for _, bad := range []string{
+		"1.0.0.0,1.0.0.255\n1.0.0.128,1.0.1.0\n", // 重叠
+		"1.0.0.255,1.0.0.0\n",                    // 起止颠倒
+		"1.0.0.0,::1\n",                          // 地址族不一致
+		"1.0.0.0,1.0.0.255,AU,Australia,,,north\n",
+		"start,end\nfoo,bar\n",
+	} {
+		if _, err := parseGeoCSV(bytes.NewBufferString(bad)); err == nil {
+			t.Errorf("parseGeoCSV(%q):\n Expect => error\n Got => nil\n", bad)
+		}
+	}
+}
+
+func TestGeoIPMergeAndReload(t *testing.T) {
+	dir := t.TempDir()
+	city := filepath.Join(dir, "city.mmdb")
+	asn := filepath.Join(dir, "asn.mmdb")
+	writeTestMMDB(t, city, 24, nil, map[string]interface{}{
+		"8.8.8.0/24": map[string]interface{}{"city": map[string]interface{}{"names": map[string]interface{}{"en": "Mountain View"}}},
+	})
+	writeTestMMDB(t, asn, 24, nil, map[string]interface{}{
+		"8.8.0.0/16": map[string]interface{}{
+			"autonomous_system_number":       uint32(15169),
+			"autonomous_system_organization": "GOOGLE",
+		},
+	})
+	g, err := OpenGeoIP(city, asn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	r, err := g.LookupRequest(req)
+	if err != nil || r.City != "Mountain View" || r.ASN != 15169 || r.ASOrg != "GOOGLE" || r.Network != "8.8.8.0/24" {
+		t.Errorf("LookupRequest:\n Expect => Mountain View AS15169 GOOGLE\n Got => %+v %v\n", r, err)
+	}
+	// 不读取客户端伪造的请求头
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "8.8.8.8")
+	req.Header.Set("X-Real-IP", "8.8.8.8")
+	if r, err := g.LookupRequest(req); err != ErrGeoIPNotFound || r.IP != "192.0.2.1" {
+		t.Errorf("LookupRequest(spoofed):\n Expect => 192.0.2.1 %v\n Got => %+v %v\n", ErrGeoIPNotFound, r, err)
+	}
+	g.IPResolver, _ = NewIPResolver("192.0.2.0/24")
+	if r, err := g.LookupRequest(req); err != nil || r.IP != "8.8.8.8" {
+		t.Errorf("LookupRequest(proxy):\n Expect => 8.8.8.8\n Got => %+v %v\n", r, err)
+	}
+	g.IPResolver = nil
+
+	// 并发查询的同时重新加载
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if r, err := g.Lookup("8.8.8.8"); err != nil || r.City == "" {
+					t.Errorf("Lookup during reload: %+v %v", r, err)
+					return
+				}
+			}
+		}()
+	}
+	writeTestMMDB(t, city, 28, nil, map[string]interface{}{
+		"8.8.8.0/24": map[string]interface{}{"city": map[string]interface{}{"names": map[string]interface{}{"en": "Sunnyvale"}}},
+	})
+	os.Chtimes(city, time.Now(), time.Now().Add(time.Second))
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+	if r, _ := g.Lookup("8.8.8.8"); r.City != "Sunnyvale" || r.ASN != 15169 {
+		t.Errorf("Reload:\n Expect => Sunnyvale AS15169\n Got => %+v\n", r)
+	}
+
+	// 新文件无效时保留原有数据库
+	writeTestFileAtomic(t, city, []byte("broken"))
+	if err := g.Reload(); err == nil {
+		t.Errorf("Reload(broken):\n Expect => error\n Got => nil\n")
+	}
+	if r, _ := g.Lookup("8.8.8.8"); r.City != "Sunnyvale" {
+		t.Errorf("Reload(broken):\n Expect => Sunnyvale\n Got => %+v\n", r)
+	}
+
+	// WatchFile
+	writeTestMMDB(t, city, 32, nil, map[string]interface{}{
+		"8.8.8.0/24": map[string]interface{}{"city": map[string]interface{}{"names": map[string]interface{}{"en": "Palo Alto"}}},
+	})
+	os.Chtimes(city, time.Now(), time.Now().Add(2*time.Second))
+	stopWatch := g.WatchFile(10 * time.Millisecond)
+	defer stopWatch()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if r, _ := g.Lookup("8.8.8.8"); r.City == "Palo Alto" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("WatchFile:\n Expect => Palo Alto\n Got => not reloaded\n")
+}
+
+// 使用-race运行,Reload、Close和查询并发进行时旧数据库只被关闭一次,关闭后不会再装入新数据库
+func TestGeoIPConcurrentReloadClose(t *testing.T) {
+	city := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestMMDB(t, city, 24, nil, map[string]interface{}{
+		"8.8.8.0/24": map[string]interface{}{"city": map[string]interface{}{"names": map[string]interface{}{"en": "Mountain View"}}},
+	})
+	g, err := OpenGeoIP(city)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				f(i)
+			}
+		}()
+	}
+	// 不断更换修改时间,使Reload真正重新打开文件
+	run(func(i int) {
+		os.Chtimes(city, time.Now(), time.Now().Add(time.Duration(i+1)*time.Second))
+	})
+	for i := 0; i < 4; i++ {
+		run(func(int) {
+			if err := g.Reload(); err != nil && !errors.Is(err, ErrGeoIPClosed) {
+				t.Errorf("Reload:\n Expect => nil or %v\n Got => %v\n", ErrGeoIPClosed, err)
+			}
+		})
+		run(func(int) {
+			if r, err := g.Lookup("8.8.8.8"); err != nil && !errors.Is(err, ErrGeoIPClosed) || err == nil && r.City != "Mountain View" {
+				t.Errorf("Lookup:\n Expect => Mountain View or %v\n Got => %+v %v\n", ErrGeoIPClosed, r, err)
+			}
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	closeErr := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { closeErr <- g.Close() }()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-closeErr; err != nil {
+			t.Errorf("Close:\n Expect => nil\n Got => %v\n", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if err := g.Reload(); !errors.Is(err, ErrGeoIPClosed) {
+		t.Errorf("Reload(closed):\n Expect => %v\n Got => %v\n", ErrGeoIPClosed, err)
+	}
+	if _, err := g.Lookup("8.8.8.8"); !errors.Is(err, ErrGeoIPClosed) {
+		t.Errorf("Lookup(closed):\n Expect => %v\n Got => %v\n", ErrGeoIPClosed, err)
+	}
+	g.mu.RLock()
+	n := len(g.sources)
+	g.mu.RUnlock()
+	if n != 0 {
+		t.Errorf("Close:\n Expect => no sources\n Got => %d\n", n)
+	}
+}
@@ -2,4 +2,8 @@ module github.com/aluka-7/utils
 
 go 1.16
 
-require github.com/smartystreets/goconvey v1.6.4
+require (
+	github.com/cespare/xxhash/v2 v2.2.0
+	github.com/smartystreets/goconvey v1.6.4
+	golang.org/x/crypto v0.14.0
+)
@@ -7,10 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"path"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type NotFoundError struct {
@@ -72,21 +71,25 @@ func HttpPost(client *http.Client, url string, header http.Header, body []byte)
 
 // HttpGetToFile gets the specified resource and writes to file.
 // ErrNotFound is returned if the server responds with status 404.
-func HttpGetToFile(client *http.Client, url string, header http.Header, fileName string) error {
+// The file is written atomically. If checksums are given the body is verified
+// while downloading; on mismatch the file is left untouched and an error
+// wrapping ErrChecksumMismatch is returned.
+func HttpGetToFile(client *http.Client, url string, header http.Header, fileName string, checksums ...Checksum) error {
 	rc, err := HttpGet(client, url, header)
 	if err != nil {
 		return err
 	}
 	defer rc.Close()
 
-	os.MkdirAll(path.Dir(fileName), os.ModePerm)
-	f, err := os.Create(fileName)
+	f, err := NewAtomicFile(fileName, AtomicOptions{})
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	_, err = io.Copy(f, rc)
-	return err
+	if err = copyVerify(f, rc, checksums); err != nil {
+		return err
+	}
+	return f.Commit()
 }
 
 // HttpGetBytes gets the specified resource. ErrNotFound is returned if the server
@@ -204,6 +207,7 @@ type UserAgent struct {
 	localization string
 	browser      Browser
 	bot          bool
+	botInfo      BotInfo
 	mobile       bool
 	undecided    bool
 }
@@ -214,26 +218,24 @@ type UserAgent struct {
 // 第二个参数是指向用户代理字符串当前索引的引用。
 // delimiter参数指定哪个字符是定界符，cat参数确定是否应忽略嵌套的'('。
 //
-// 返回包含已读取内容的字节数组。
-func readUntil(ua string, index *int, delimiter byte, cat bool) []byte {
-	var buffer []byte
-
-	i := *index
+// 返回已读取的内容,它是ua的子串,不会产生新的内存分配。
+func readUntil(ua string, index *int, delimiter byte, cat bool) string {
+	start := *index
+	i := start
 	catalan := 0
 	for ; i < len(ua); i = i + 1 {
 		if ua[i] == delimiter {
 			if catalan == 0 {
 				*index = i + 1
-				return buffer
+				return ua[start:i]
 			}
 			catalan--
 		} else if cat && ua[i] == '(' {
 			catalan++
 		}
-		buffer = append(buffer, ua[i])
 	}
 	*index = i + 1
-	return buffer
+	return ua[start:i]
 }
 
 // Parse the given product, that is, just a name or a string
@@ -241,31 +243,65 @@ func readUntil(ua string, index *int, delimiter byte, cat bool) []byte {
 //
 // It returns two strings. The first string is the name of the product and the
 // second string contains the version of the product.
-func parseProduct(product []byte) (string, string) {
-	prod := strings.SplitN(string(product), "/", 2)
-	if len(prod) == 2 {
-		return prod[0], prod[1]
+func parseProduct(product string) (string, string) {
+	if i := strings.IndexByte(product, '/'); i >= 0 {
+		return product[:i], product[i+1:]
 	}
-	return string(product), ""
+	return product, ""
 }
 
 // 解析部分.节的格式通常如下"名称/版本(注释)".注释和版本都是可选的。
 //
 // 第一个参数是要解析的用户代理字符串。
 // 第二个参数是指向用户代理字符串当前索引的引用。
+// 第三个参数是复用的注释缓冲区,注释会追加到其中。
 //
-// 返回一个节，其中包含我们可以从最后一个已分析节中提取的信息。
-func parseSection(ua string, index *int) (s section) {
-	buffer := readUntil(ua, index, ' ', false)
-
-	s.name, s.version = parseProduct(buffer)
+// 返回一个节，其中包含我们可以从最后一个已分析节中提取的信息,以及追加后的注释缓冲区。
+func parseSection(ua string, index *int, comments []string) (section, []string) {
+	var s section
+	s.name, s.version = parseProduct(readUntil(ua, index, ' ', false))
 	if *index < len(ua) && ua[*index] == '(' {
 		*index++
-		buffer = readUntil(ua, index, ')', true)
-		s.comment = strings.Split(string(buffer), "; ")
+		comment := readUntil(ua, index, ')', true)
+		start := len(comments)
+		for {
+			i := strings.Index(comment, "; ")
+			if i < 0 {
+				comments = append(comments, comment)
+				break
+			}
+			comments = append(comments, comment[:i])
+			comment = comment[i+2:]
+		}
+		s.comment = comments[start:len(comments):len(comments)]
 		*index++
 	}
-	return s
+	return s, comments
+}
+
+// uaScratch holds the buffers reused between parses, so that parsing a
+// User-Agent string does not allocate the sections and comments every time.
+type uaScratch struct {
+	sections []section
+	comments []string
+}
+
+var uaScratchPool = sync.Pool{
+	New: func() interface{} {
+		return &uaScratch{sections: make([]section, 0, 8), comments: make([]string, 0, 16)}
+	},
+}
+
+// Drop the references to the parsed string before the buffers are reused.
+func (s *uaScratch) reset() {
+	for i := range s.sections {
+		s.sections[i] = section{}
+	}
+	for i := range s.comments {
+		s.comments[i] = ""
+	}
+	s.sections = s.sections[:0]
+	s.comments = s.comments[:0]
 }
 
 // Initialize the parser.
@@ -280,6 +316,7 @@ func (p *UserAgent) initialize() {
 	p.browser.Name = ""
 	p.browser.Version = ""
 	p.bot = false
+	p.botInfo = BotInfo{}
 	p.mobile = false
 	p.undecided = false
 }
@@ -296,19 +333,26 @@ func New(ua string) *UserAgent {
 // Parse the given User-Agent string. After calling this function, the
 // receiver will be setted up with all the information that we've extracted.
 func (p *UserAgent) Parse(ua string) {
-	var sections []section
+	scratch := uaScratchPool.Get().(*uaScratch)
+	p.parse(ua, scratch)
+	scratch.reset()
+	uaScratchPool.Put(scratch)
+}
 
+// Parse the given User-Agent string with the given reusable buffers.
+func (p *UserAgent) parse(ua string, scratch *uaScratch) {
 	p.initialize()
 	p.ua = ua
 	for index, limit := 0, len(ua); index < limit; {
-		s := parseSection(ua, &index)
+		var s section
+		s, scratch.comments = parseSection(ua, &index, scratch.comments)
 		if !p.mobile && s.name == "Mobile" {
 			p.mobile = true
 		}
-		sections = append(sections, s)
+		scratch.sections = append(scratch.sections, s)
 	}
 
-	if len(sections) > 0 {
+	if sections := scratch.sections; len(sections) > 0 {
 		if sections[0].name == "Mozilla" {
 			p.mozilla = sections[0].version
 		}
@@ -320,6 +364,7 @@ func (p *UserAgent) Parse(ua string) {
 			p.checkBot(sections)
 		}
 	}
+	p.classifyBot()
 }
 
 // Returns the mozilla version (it's how the User Agent string begins:
@@ -333,6 +378,12 @@ func (p *UserAgent) Bot() bool {
 	return p.bot
 }
 
+// Returns the classification of the bot. The Category is BotNone when the
+// user agent is not recognized as a bot.
+func (p *UserAgent) BotInfo() BotInfo {
+	return p.botInfo
+}
+
 // Returns true if it's a mobile device, false otherwise.
 func (p *UserAgent) Mobile() bool {
 	return p.mobile
@@ -346,14 +397,14 @@ func (p *UserAgent) UA() string {
 // Represents full information on the operating system extracted from the user agent.
 type OSInfo struct {
 	// Full name of the operating system. This is identical to the output of ua.OS()
-	FullName string
+	FullName string `json:"full_name,omitempty"`
 
 	// Name of the operating system. This is sometimes a shorter version of the
 	// operating system name, e.g. "Mac OS X" instead of "Intel Mac OS X"
-	Name string
+	Name string `json:"name,omitempty"`
 
 	// Operating system version, e.g. 7 for Windows 7 or 10.8 for Max OS X Mountain Lion
-	Version string
+	Version string `json:"version,omitempty"`
 }
 
 // Normalize the name of the operating system. By now, this just
@@ -695,8 +746,6 @@ func (p *UserAgent) OSInfo() OSInfo {
 	}
 }
 
-var ie11Regexp = regexp.MustCompile("^rv:(.+)$")
-
 // A struct containing all the information that we might be
 // interested from the browser.
 type Browser struct {
@@ -754,6 +803,10 @@ func (p *UserAgent) detectBrowser(sections []section) {
 				case "OPR":
 					p.browser.Name = "Opera"
 					p.browser.Version = sections[slen-1].version
+				case "Edg", "EdgA", "EdgiOS":
+					// Chromium based Edge keeps the Blink engine.
+					p.browser.Name = "Edge"
+					p.browser.Version = sections[slen-1].version
 				default:
 					if sections[sectionIndex].name == "Chrome" {
 						p.browser.Name = "Chrome"
@@ -775,9 +828,8 @@ func (p *UserAgent) detectBrowser(sections []section) {
 				p.browser.Engine = "Trident"
 				p.browser.Name = "Internet Explorer"
 				for _, c := range sections[0].comment {
-					version := ie11Regexp.FindStringSubmatch(c)
-					if len(version) > 0 {
-						p.browser.Version = version[1]
+					if len(c) > 3 && strings.HasPrefix(c, "rv:") {
+						p.browser.Version = c[3:]
 						return
 					}
 				}
@@ -825,7 +877,7 @@ func (p *UserAgent) Browser() (string, string) {
 	return p.browser.Name, p.browser.Version
 }
 
-var botFromSiteRegexp = regexp.MustCompile("http://.+\\.\\w+")
+var botFromSiteRegexp = regexp.MustCompile("https?://.+\\.\\w+")
 
 // Get the name of the bot from the website that may be in the given comment. If
 // there is no website in the comment, then an empty string is returned.
@@ -902,7 +954,7 @@ func (p *UserAgent) checkBot(sections []section) {
 		p.mozilla = ""
 
 		// Check whether the name has some suspicious "bot" or "crawler" in his name.
-		if botRegex.Match([]byte(sections[0].name)) {
+		if botRegex.MatchString(sections[0].name) {
 			p.setSimple(sections[0].name, "", true)
 			return
 		}
@@ -937,3 +989,15 @@ func (p *UserAgent) checkBot(sections []section) {
 		p.fixOther(sections)
 	}
 }
+
+// Classify the user agent with the known bot signatures. Headless browsers,
+// HTTP libraries and monitoring agents are flagged as bots as well, but the
+// browser information extracted so far is kept untouched.
+func (p *UserAgent) classifyBot() {
+	if info, ok := DetectBot(p.ua); ok {
+		p.bot = true
+		p.botInfo = info
+	} else if p.bot {
+		p.botInfo = BotInfo{Name: p.browser.Name, Version: p.browser.Version, Category: BotGeneric}
+	}
+}
@@ -440,12 +440,12 @@ var uaStrings = []struct {
 	{
 		title:    "Python",
 		ua:       "Python-urllib/2.7",
-		expected: "Browser:Python-urllib-2.7 Bot:false Mobile:false",
+		expected: "Browser:Python-urllib-2.7 Bot:true Mobile:false",
 	},
 	{
 		title:    "Curl",
 		ua:       "curl/7.28.1",
-		expected: "Browser:curl-7.28.1 Bot:false Mobile:false",
+		expected: "Browser:curl-7.28.1 Bot:true Mobile:false",
 	},
 
 	// WebKit
@@ -1,20 +1,28 @@
 package utils
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
 )
 
 // 获取客户端请求IP
+//
+// 通过SetTrustedProxies设置可信代理后,只信任来自这些代理的请求头;
+// 未设置时依次读取X-Real-IP、X-Forwarded-For和RemoteAddr,请求头可被客户端伪造。
 func GetIP(r *http.Request) string {
-	ip := r.Header.Get("X-Real-IP")
+	if resolver := DefaultIPResolver(); resolver != nil {
+		ip, _ := resolver.Resolve(r)
+		return ip
+	}
+	ip := strings.TrimSpace(r.Header.Get("X-Real-IP"))
 	if net.ParseIP(ip) != nil {
 		return ip
 	}
 	ip = r.Header.Get("X-Forwarded-For")
 	for _, i := range strings.Split(ip, ",") {
-		if net.ParseIP(i) != nil {
+		if i = strings.TrimSpace(i); net.ParseIP(i) != nil {
 			return i
 		}
 	}
@@ -28,7 +36,7 @@ func GetIP(r *http.Request) string {
 	return ""
 }
 
-// ExternalIP获取外部IP.
+// ExternalIP获取外部IP,即全局可路由的IPv4地址,不含私有、CGNAT、文档等特殊用途地址;需要IPv6或网卡信息时使用ExternalAddrs.
 func ExternalIP() (res []string) {
 	inters, err := net.Interfaces()
 	if err != nil {
@@ -42,20 +50,8 @@ func ExternalIP() (res []string) {
 			}
 			for _, addr := range addresses {
 				if ipNet, ok := addr.(*net.IPNet); ok {
-					if ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalMulticast() || ipNet.IP.IsLinkLocalUnicast() {
-						continue
-					}
-					if ip4 := ipNet.IP.To4(); ip4 != nil {
-						switch true {
-						case ip4[0] == 10:
-							continue
-						case ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31:
-							continue
-						case ip4[0] == 192 && ip4[1] == 168:
-							continue
-						default:
-							res = append(res, ipNet.IP.String())
-						}
+					if ip4 := ipNet.IP.To4(); ip4 != nil && IsGlobal(ip4) {
+						res = append(res, ipNet.IP.String())
 					}
 				}
 			}
@@ -64,34 +60,174 @@ func ExternalIP() (res []string) {
 	return
 }
 
-// InternalIP获取内部IP.
+// InternalIP获取内部IP,仅IPv4;按路由表选择对外通信使用的地址,会跳过docker0、veth等虚拟网卡.
+// 需要IPv6、指定网卡或网段时使用PreferredIP,需要网卡信息时使用InternalAddrs或NetInterfaces.
 func InternalIP() string {
-	inters, err := net.Interfaces()
+	ip, err := PreferredIP(FamilyIPv4)
 	if err != nil {
 		return ""
 	}
+	return ip.String()
+}
+
+// isUp Interface is up
+func isUp(v net.Flags) bool {
+	return v&net.FlagUp == net.FlagUp
+}
+
+// IPFamily 地址族
+type IPFamily int
+
+const (
+	FamilyAny  IPFamily = iota // IPv4和IPv6
+	FamilyIPv4                 // 仅IPv4
+	FamilyIPv6                 // 仅IPv6
+)
+
+// match 判断ip是否属于该地址族
+func (f IPFamily) match(ip net.IP) bool {
+	switch f {
+	case FamilyIPv4:
+		return ip.To4() != nil
+	case FamilyIPv6:
+		return ip.To4() == nil && len(ip) == net.IPv6len
+	}
+	return true
+}
+
+// IPScope 地址的作用范围
+type IPScope int
+
+const (
+	ScopeUnknown   IPScope = iota
+	ScopeLoopback          // 127.0.0.0/8、::1
+	ScopeLinkLocal         // 169.254.0.0/16、fe80::/10
+	ScopePrivate           // RFC1918私有地址、ULA(fc00::/7)
+	ScopeMulticast         // 组播地址
+	ScopeGlobal            // 全局单播地址
+)
+
+func (s IPScope) String() string {
+	switch s {
+	case ScopeLoopback:
+		return "loopback"
+	case ScopeLinkLocal:
+		return "link-local"
+	case ScopePrivate:
+		return "private"
+	case ScopeMulticast:
+		return "multicast"
+	case ScopeGlobal:
+		return "global"
+	}
+	return "unknown"
+}
+
+// IPScopeOf 判断ip的作用范围
+func IPScopeOf(ip net.IP) IPScope {
+	switch {
+	case ip == nil || ip.IsUnspecified():
+		return ScopeUnknown
+	case ip.IsLoopback():
+		return ScopeLoopback
+	case ip.IsLinkLocalUnicast():
+		return ScopeLinkLocal
+	case ip.IsMulticast():
+		return ScopeMulticast
+	case IsPrivate(ip):
+		return ScopePrivate
+	case ip.IsGlobalUnicast():
+		return ScopeGlobal
+	}
+	return ScopeUnknown
+}
+
+// InterfaceAddr 网卡上的一个地址
+type InterfaceAddr struct {
+	Interface string
+	IP        net.IP
+	PrefixLen int
+	Flags     net.Flags
+	Scope     IPScope
+}
+
+// IsIPv6 是否为IPv6地址
+func (a InterfaceAddr) IsIPv6() bool {
+	return a.IP.To4() == nil
+}
+
+func (a InterfaceAddr) String() string {
+	return fmt.Sprintf("%s %s/%d %s", a.Interface, a.IP, a.PrefixLen, a.Scope)
+}
+
+// InterfaceAddrs 返回已启用网卡上属于给定地址族的全部地址
+func InterfaceAddrs(family IPFamily) ([]InterfaceAddr, error) {
+	inters, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var res []InterfaceAddr
 	for _, inter := range inters {
 		if !isUp(inter.Flags) {
 			continue
 		}
-		if !strings.HasPrefix(inter.Name, "lo") {
-			addresses, err := inter.Addrs()
-			if err != nil {
-				continue
-			}
-			for _, addr := range addresses {
-				if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
-					if ipNet.IP.To4() != nil {
-						return ipNet.IP.String()
-					}
-				}
+		addresses, err := inter.Addrs()
+		if err != nil {
+			continue
+		}
+		res = append(res, interfaceAddrs(inter.Name, inter.Flags, addresses, family)...)
+	}
+	return res, nil
+}
+
+// interfaceAddrs 将网卡地址转换为InterfaceAddr
+func interfaceAddrs(name string, flags net.Flags, addresses []net.Addr, family IPFamily) []InterfaceAddr {
+	var res []InterfaceAddr
+	for _, addr := range addresses {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !family.match(ipNet.IP) {
+			continue
+		}
+		ones, _ := ipNet.Mask.Size()
+		res = append(res, InterfaceAddr{
+			Interface: name,
+			IP:        ipNet.IP,
+			PrefixLen: ones,
+			Flags:     flags,
+			Scope:     IPScopeOf(ipNet.IP),
+		})
+	}
+	return res
+}
+
+// filterScope 按作用范围过滤
+func filterScope(addrs []InterfaceAddr, scopes ...IPScope) []InterfaceAddr {
+	var res []InterfaceAddr
+	for _, a := range addrs {
+		for _, s := range scopes {
+			if a.Scope == s {
+				res = append(res, a)
+				break
 			}
 		}
 	}
-	return ""
+	return res
 }
 
-// isUp Interface is up
-func isUp(v net.Flags) bool {
-	return v&net.FlagUp == net.FlagUp
+// ExternalAddrs 获取外部地址,即全局单播地址,支持IPv4和IPv6。
+func ExternalAddrs(family IPFamily) ([]InterfaceAddr, error) {
+	addrs, err := InterfaceAddrs(family)
+	if err != nil {
+		return nil, err
+	}
+	return filterScope(addrs, ScopeGlobal), nil
+}
+
+// InternalAddrs 获取内部地址,即私有地址和ULA,支持IPv4和IPv6。
+func InternalAddrs(family IPFamily) ([]InterfaceAddr, error) {
+	addrs, err := InterfaceAddrs(family)
+	if err != nil {
+		return nil, err
+	}
+	return filterScope(addrs, ScopePrivate), nil
 }
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPScopeOf(t *testing.T) {
+	cases := map[string]IPScope{
+		"127.0.0.1":       ScopeLoopback,
+		"::1":             ScopeLoopback,
+		"169.254.10.1":    ScopeLinkLocal,
+		"fe80::1":         ScopeLinkLocal,
+		"10.1.2.3":        ScopePrivate,
+		"172.20.0.1":      ScopePrivate,
+		"172.32.0.1":      ScopeGlobal,
+		"192.168.1.1":     ScopePrivate,
+		"fd12:3456::1":    ScopePrivate,
+		"fc00::1":         ScopePrivate,
+		"2001:4860::8888": ScopeGlobal,
+		"8.8.8.8":         ScopeGlobal,
+		"ff02::1":         ScopeMulticast,
+		"224.0.0.1":       ScopeMulticast,
+		"0.0.0.0":         ScopeUnknown,
+	}
+	for ip, expected := range cases {
+		if got := IPScopeOf(net.ParseIP(ip)); got != expected {
+			t.Errorf("IPScopeOf(%s):\n Expect => %s\n Got => %s\n", ip, expected, got)
+		}
+	}
+}
+
+func TestInterfaceAddrs(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("192.168.1.10").To4(), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::10"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPAddr{IP: net.ParseIP("1.2.3.4")},
+	}
+	flags := net.FlagUp | net.FlagMulticast
+	if got := interfaceAddrs("eth0", flags, addrs, FamilyAny); len(got) != 3 {
+		t.Fatalf("interfaceAddrs:\n Expect => %d\n Got => %d\n", 3, len(got))
+	}
+	v4 := interfaceAddrs("eth0", flags, addrs, FamilyIPv4)
+	if len(v4) != 1 || v4[0].PrefixLen != 24 || v4[0].Scope != ScopePrivate || v4[0].IsIPv6() {
+		t.Errorf("interfaceAddrs(v4):\n Expect => 192.168.1.10/24 private\n Got => %v\n", v4)
+	}
+	v6 := interfaceAddrs("eth0", flags, addrs, FamilyIPv6)
+	if len(v6) != 2 || v6[0].PrefixLen != 64 || v6[0].Interface != "eth0" || v6[0].Flags != flags || !v6[0].IsIPv6() {
+		t.Errorf("interfaceAddrs(v6):\n Expect => 2001:db8::10/64, fe80::1/64\n Got => %v\n", v6)
+	}
+	if ll := filterScope(v6, ScopeLinkLocal); len(ll) != 1 || !ll[0].IP.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("filterScope:\n Expect => fe80::1\n Got => %v\n", ll)
+	}
+}
+
+func TestLocalInterfaceAddrs(t *testing.T) {
+	addrs, err := InterfaceAddrs(FamilyAny)
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, a := range addrs {
+		if a.Scope != IPScopeOf(a.IP) || a.Flags&net.FlagUp == 0 {
+			t.Errorf("InterfaceAddrs:\n Expect => up interface\n Got => %v\n", a)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"net"
+)
+
+// IPClass 按IANA特殊用途地址注册表对地址的分类
+type IPClass int
+
+const (
+	ClassUnknown       IPClass = iota // 无效地址
+	ClassGlobal                       // 全局可路由地址
+	ClassUnspecified                  // 0.0.0.0、::
+	ClassLoopback                     // 127.0.0.0/8、::1
+	ClassPrivate                      // RFC1918、ULA fc00::/7
+	ClassCGNAT                        // 运营商级NAT共享地址 100.64.0.0/10
+	ClassLinkLocal                    // 169.254.0.0/16、fe80::/10
+	ClassDocumentation                // 文档示例地址
+	ClassBenchmarking                 // 网络设备基准测试地址
+	ClassMulticast                    // 组播地址
+	ClassReserved                     // 其他保留地址,如240.0.0.0/4、广播地址、协议专用地址
+)
+
+func (c IPClass) String() string {
+	switch c {
+	case ClassGlobal:
+		return "global"
+	case ClassUnspecified:
+		return "unspecified"
+	case ClassLoopback:
+		return "loopback"
+	case ClassPrivate:
+		return "private"
+	case ClassCGNAT:
+		return "cgnat"
+	case ClassLinkLocal:
+		return "link-local"
+	case ClassDocumentation:
+		return "documentation"
+	case ClassBenchmarking:
+		return "benchmarking"
+	case ClassMulticast:
+		return "multicast"
+	case ClassReserved:
+		return "reserved"
+	}
+	return "unknown"
+}
+
+// specialNet 特殊用途地址段
+type specialNet struct {
+	net   *net.IPNet
+	class IPClass
+}
+
+func mustSpecialNets(class IPClass, cidrs ...string) []specialNet {
+	res := make([]specialNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, specialNet{net: n, class: class})
+	}
+	return res
+}
+
+// specialNets4 IPv4特殊用途地址,参见RFC 6890及IANA注册表
+var specialNets4 = concatSpecialNets(
+	mustSpecialNets(ClassUnspecified, "0.0.0.0/32"),
+	mustSpecialNets(ClassReserved, "0.0.0.0/8", "192.0.0.0/24", "192.88.99.0/24", "240.0.0.0/4"),
+	mustSpecialNets(ClassPrivate, "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"),
+	mustSpecialNets(ClassCGNAT, "100.64.0.0/10"),
+	mustSpecialNets(ClassLoopback, "127.0.0.0/8"),
+	mustSpecialNets(ClassLinkLocal, "169.254.0.0/16"),
+	mustSpecialNets(ClassDocumentation, "192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"),
+	mustSpecialNets(ClassBenchmarking, "198.18.0.0/15"),
+	mustSpecialNets(ClassMulticast, "224.0.0.0/4"),
+)
+
+// specialNets6 IPv6特殊用途地址,靠前的更具体的地址段优先匹配
+var specialNets6 = concatSpecialNets(
+	mustSpecialNets(ClassUnspecified, "::/128"),
+	mustSpecialNets(ClassLoopback, "::1/128"),
+	mustSpecialNets(ClassBenchmarking, "2001:2::/48"),
+	mustSpecialNets(ClassDocumentation, "2001:db8::/32", "3fff::/20"),
+	// IANA注册表中2001::/23内全局可达的条目:PCP、TURN和DNS-SD SRP任播,AMT,AS112-v6,ORCHIDv2,DET
+	mustSpecialNets(ClassGlobal, "64:ff9b::/96", "2001:1::1/128", "2001:1::2/128", "2001:1::3/128",
+		"2001:3::/32", "2001:4:112::/48", "2001:20::/28", "2001:30::/28"),
+	// 2001::/23中Teredo、已废弃的ORCHID以及尚未分配的部分不是全局可达的
+	mustSpecialNets(ClassReserved, "64:ff9b:1::/48", "100::/64", "2001::/32", "2001:10::/28", "2001::/23"),
+	mustSpecialNets(ClassPrivate, "fc00::/7"),
+	mustSpecialNets(ClassLinkLocal, "fe80::/10"),
+	mustSpecialNets(ClassMulticast, "ff00::/8"),
+)
+
+func concatSpecialNets(groups ...[]specialNet) []specialNet {
+	var res []specialNet
+	for _, g := range groups {
+		res = append(res, g...)
+	}
+	return res
+}
+
+// ClassifyIP 对地址分类,IPv4映射的IPv6地址按IPv4处理
+func ClassifyIP(ip net.IP) IPClass {
+	nets := specialNets6
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, nets = ip4, specialNets4
+	} else if len(ip) != net.IPv6len {
+		return ClassUnknown
+	}
+	for _, s := range nets {
+		if s.net.Contains(ip) {
+			return s.class
+		}
+	}
+	if len(ip) == net.IPv6len && ip[0]&0xe0 != 0x20 {
+		// 2000::/3之外的单播地址尚未分配
+		return ClassReserved
+	}
+	return ClassGlobal
+}
+
+// IsPrivate 是否为私有地址(RFC1918或IPv6 ULA)
+func IsPrivate(ip net.IP) bool {
+	return ClassifyIP(ip) == ClassPrivate
+}
+
+// IsCGNAT 是否为运营商级NAT共享地址100.64.0.0/10
+func IsCGNAT(ip net.IP) bool {
+	return ClassifyIP(ip) == ClassCGNAT
+}
+
+// IsDocumentation 是否为文档示例地址
+func IsDocumentation(ip net.IP) bool {
+	return ClassifyIP(ip) == ClassDocumentation
+}
+
+// IsBenchmarking 是否为基准测试地址
+func IsBenchmarking(ip net.IP) bool {
+	return ClassifyIP(ip) == ClassBenchmarking
+}
+
+// IsReserved 是否为保留地址,不包含私有、回环等有专门分类的地址
+func IsReserved(ip net.IP) bool {
+	return ClassifyIP(ip) == ClassReserved
+}
+
+// IsGlobal 是否为全局可路由地址
+func IsGlobal(ip net.IP) bool {
+	return ClassifyIP(ip) == ClassGlobal
+}
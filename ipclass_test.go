@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClassifyIP(t *testing.T) {
+	cases := map[string]IPClass{
+		"8.8.8.8":          ClassGlobal,
+		"0.0.0.0":          ClassUnspecified,
+		"0.1.2.3":          ClassReserved,
+		"10.0.0.1":         ClassPrivate,
+		"172.31.255.255":   ClassPrivate,
+		"192.168.0.1":      ClassPrivate,
+		"100.64.0.1":       ClassCGNAT,
+		"100.127.255.255":  ClassCGNAT,
+		"100.128.0.1":      ClassGlobal,
+		"127.0.0.1":        ClassLoopback,
+		"169.254.1.1":      ClassLinkLocal,
+		"192.0.2.1":        ClassDocumentation,
+		"198.51.100.1":     ClassDocumentation,
+		"203.0.113.1":      ClassDocumentation,
+		"198.18.0.1":       ClassBenchmarking,
+		"198.19.255.255":   ClassBenchmarking,
+		"224.0.0.1":        ClassMulticast,
+		"240.0.0.1":        ClassReserved,
+		"255.255.255.255":  ClassReserved,
+		"::ffff:10.0.0.1":  ClassPrivate,
+		"::":               ClassUnspecified,
+		"::1":              ClassLoopback,
+		"2001:4860::8888":  ClassGlobal,
+		"2001:db8::1":      ClassDocumentation,
+		"3fff::1":          ClassDocumentation,
+		"2001:2::1":        ClassBenchmarking,
+		"2001::1":          ClassReserved,
+		"2001:1::1":        ClassGlobal,
+		"2001:1::4":        ClassReserved,
+		"2001:3::1":        ClassGlobal,
+		"2001:4:112::1":    ClassGlobal,
+		"2001:4:113::1":    ClassReserved,
+		"2001:10::1":       ClassReserved,
+		"2001:20::1":       ClassGlobal,
+		"2001:2f:ffff::1":  ClassGlobal,
+		"2001:30::1":       ClassGlobal,
+		"2001:100::1":      ClassReserved,
+		"2001:200::1":      ClassGlobal,
+		"64:ff9b::808:808": ClassGlobal,
+		"fd00::1":          ClassPrivate,
+		"fe80::1":          ClassLinkLocal,
+		"ff02::1":          ClassMulticast,
+		"4000::1":          ClassReserved,
+	}
+	for ip, expected := range cases {
+		if got := ClassifyIP(net.ParseIP(ip)); got != expected {
+			t.Errorf("ClassifyIP(%s):\n Expect => %s\n Got => %s\n", ip, expected, got)
+		}
+	}
+	if ClassifyIP(nil) != ClassUnknown {
+		t.Errorf("ClassifyIP(nil):\n Expect => %s\n Got => %s\n", ClassUnknown, ClassifyIP(nil))
+	}
+	if !IsCGNAT(net.ParseIP("100.64.1.1")) || !IsDocumentation(net.ParseIP("2001:db8::")) || !IsGlobal(net.ParseIP("1.1.1.1")) ||
+		!IsPrivate(net.ParseIP("fc00::1")) || !IsReserved(net.ParseIP("240.0.0.0")) || !IsBenchmarking(net.ParseIP("198.18.0.0")) {
+		t.Errorf("Is*:\n Expect => true\n Got => false\n")
+	}
+}
+
+func TestCIDRSet(t *testing.T) {
+	s, err := NewCIDRSet("10.0.0.0/24", "10.0.1.0/24", "10.0.0.128/25", "192.0.2.7", "2001:db8::/33", "2001:db8:8000::/33")
+	if err != nil {
+		t.Fatalf("NewCIDRSet:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	if got := s.String(); got != "10.0.0.0/23,192.0.2.7/32,2001:db8::/32" {
+		t.Errorf("CIDRSet.String:\n Expect => %s\n Got => %s\n", "10.0.0.0/23,192.0.2.7/32,2001:db8::/32", got)
+	}
+	for ip, expected := range map[string]bool{
+		"10.0.0.1":        true,
+		"10.0.1.255":      true,
+		"10.0.2.0":        false,
+		"192.0.2.7":       true,
+		"192.0.2.8":       false,
+		"2001:db8:ffff::": true,
+		"2001:db9::":      false,
+		"::ffff:10.0.0.9": true,
+	} {
+		if got := s.Contains(net.ParseIP(ip)); got != expected {
+			t.Errorf("CIDRSet.Contains(%s):\n Expect => %v\n Got => %v\n", ip, expected, got)
+		}
+	}
+
+	deny, _ := NewCIDRSet("10.0.0.64/26", "10.0.1.255", "2001:db8::/32")
+	s.Subtract(deny)
+	expected := "10.0.0.0/26,10.0.0.128/25,10.0.1.0/25,10.0.1.128/26,10.0.1.192/27,10.0.1.224/28,10.0.1.240/29,10.0.1.248/30,10.0.1.252/31,10.0.1.254/32,192.0.2.7/32"
+	if got := s.String(); got != expected {
+		t.Errorf("CIDRSet.Subtract:\n Expect => %s\n Got => %s\n", expected, got)
+	}
+	if s.Contains(net.ParseIP("10.0.0.100")) || !s.Contains(net.ParseIP("10.0.0.200")) {
+		t.Errorf("CIDRSet.Contains after Subtract:\n Expect => excluded\n Got => included\n")
+	}
+
+	s.Merge(deny)
+	if got := s.String(); got != "10.0.0.0/23,192.0.2.7/32,2001:db8::/32" {
+		t.Errorf("CIDRSet.Merge:\n Expect => %s\n Got => %s\n", "10.0.0.0/23,192.0.2.7/32,2001:db8::/32", got)
+	}
+
+	all, _ := NewCIDRSet("0.0.0.0/0", "::/0")
+	all.Subtract(all)
+	if !all.IsEmpty() {
+		t.Errorf("CIDRSet.Subtract(self):\n Expect => empty\n Got => %s\n", all)
+	}
+	full, _ := NewCIDRSet("0.0.0.0/1", "128.0.0.0/1")
+	if got := full.String(); got != "0.0.0.0/0" {
+		t.Errorf("CIDRSet.Merge:\n Expect => %s\n Got => %s\n", "0.0.0.0/0", got)
+	}
+
+	n := 0
+	s.Range(func(*net.IPNet) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("CIDRSet.Range:\n Expect => %d\n Got => %d\n", 1, n)
+	}
+	if _, err = NewCIDRSet("10.0.0.0/33"); err == nil {
+		t.Errorf("NewCIDRSet:\n Expect => error\n Got => %v\n", err)
+	}
+}
+
+func BenchmarkCIDRSetContains(b *testing.B) {
+	s := &CIDRSet{}
+	for i := 0; i < 4096; i++ {
+		s.AddNet(&net.IPNet{IP: net.IPv4(10, byte(i>>4), byte(i<<4), 0).To4(), Mask: net.CIDRMask(28, 32)})
+	}
+	ip := net.ParseIP("10.8.128.1")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Contains(ip)
+	}
+}
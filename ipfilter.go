@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// IPDecision IP过滤的判定结果
+type IPDecision struct {
+	IP      string
+	Allowed bool
+	// Rule 命中的规则,如"deny 10.0.0.0/8";未命中任何规则时为"default"
+	Rule string
+}
+
+// trieNode 前缀树节点,按比特展开
+type trieNode struct {
+	child [2]*trieNode
+	set   bool
+	deny  bool
+	rule  string
+}
+
+// prefixTrie 最长前缀匹配树
+type prefixTrie struct {
+	root trieNode
+}
+
+func (t *prefixTrie) insert(ip net.IP, prefix int, deny bool, rule string) {
+	n := &t.root
+	for i := 0; i < prefix; i++ {
+		b := ip[i/8] >> (7 - uint(i%8)) & 1
+		if n.child[b] == nil {
+			n.child[b] = &trieNode{}
+		}
+		n = n.child[b]
+	}
+	// 同一前缀同时出现在允许和拒绝列表时,拒绝优先。
+	if !n.set || deny {
+		n.set, n.deny, n.rule = true, deny, rule
+	}
+}
+
+// lookup 返回与ip匹配的最长前缀节点,未匹配时返回nil
+func (t *prefixTrie) lookup(ip net.IP) *trieNode {
+	var found *trieNode
+	n := &t.root
+	for i := 0; n != nil; i++ {
+		if n.set {
+			found = n
+		}
+		if i == len(ip)*8 {
+			break
+		}
+		n = n.child[ip[i/8]>>(7-uint(i%8))&1]
+	}
+	return found
+}
+
+// ipRules 一份不可变的规则,重新加载时整体替换
+type ipRules struct {
+	v4, v6   prefixTrie
+	hasAllow bool
+}
+
+func newIPRules(allow, deny []string) (*ipRules, error) {
+	rules := &ipRules{}
+	for _, list := range []struct {
+		cidrs []string
+		deny  bool
+	}{{allow, false}, {deny, true}} {
+		for _, c := range list.cidrs {
+			n, err := parseCIDROrIP(c)
+			if err != nil {
+				return nil, err
+			}
+			ones, _ := n.Mask.Size()
+			rule := "allow " + n.String()
+			if list.deny {
+				rule = "deny " + n.String()
+			} else {
+				rules.hasAllow = true
+			}
+			if ip4 := n.IP.To4(); ip4 != nil {
+				rules.v4.insert(ip4, ones, list.deny, rule)
+			} else {
+				rules.v6.insert(n.IP, ones, list.deny, rule)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// decide 最长前缀匹配:命中的最具体规则决定结果;未命中时,
+// 配置了允许列表则拒绝,否则放行。
+func (r *ipRules) decide(ip net.IP) (allowed bool, rule string) {
+	var n *trieNode
+	if ip4 := ip.To4(); ip4 != nil {
+		n = r.v4.lookup(ip4)
+	} else if len(ip) == net.IPv6len {
+		n = r.v6.lookup(ip)
+	}
+	if n != nil {
+		return !n.deny, n.rule
+	}
+	return !r.hasAllow, "default"
+}
+
+// IPFilter 基于允许/拒绝CIDR列表的IP过滤器,规则可以在运行时从文件重新加载。
+// 需要通过NewIPFilter或NewIPFilterFromFile创建,没有加载规则的零值拒绝所有请求。
+//
+// Handler默认只使用连接的对端地址RemoteAddr,不读取任何可以被客户端伪造的请求头;
+// 部署在代理之后时请设置Resolver。
+type IPFilter struct {
+	rules atomic.Value // *ipRules
+	// Resolver 解析客户端IP的可信代理解析器,为nil时使用RemoteAddr
+	Resolver *IPResolver
+	// Status 拒绝请求时返回的状态码,默认为403
+	Status int
+	// Logger 用于记录判定结果,为nil时使用标准库log
+	Logger *log.Logger
+	// LogAllowed 是否同时记录放行的请求,默认只记录拒绝的请求
+	LogAllowed bool
+}
+
+// NewIPFilter 使用允许和拒绝列表创建过滤器,列表元素为CIDR或单个IP。
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	rules, err := newIPRules(allow, deny)
+	if err != nil {
+		return nil, err
+	}
+	f := &IPFilter{Status: http.StatusForbidden}
+	f.rules.Store(rules)
+	return f, nil
+}
+
+// NewIPFilterFromFile 从规则文件创建过滤器,文件格式见ParseIPFilterRules。
+func NewIPFilterFromFile(filename string) (*IPFilter, error) {
+	f := &IPFilter{Status: http.StatusForbidden}
+	if err := f.ReloadFile(filename); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseIPFilterRules 解析规则,每行一条"allow <CIDR>"或"deny <CIDR>",
+// 空行和以#开头的行会被忽略。
+func ParseIPFilterRules(r io.Reader) (allow, deny []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("ip filter rule line %d: %q", line, text)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return nil, nil, fmt.Errorf("ip filter rule line %d: unknown action %q", line, fields[0])
+		}
+	}
+	return allow, deny, scanner.Err()
+}
+
+// Reload 替换全部规则,正在处理的请求不受影响
+func (f *IPFilter) Reload(allow, deny []string) error {
+	rules, err := newIPRules(allow, deny)
+	if err != nil {
+		return err
+	}
+	f.rules.Store(rules)
+	return nil
+}
+
+// ReloadFile 从文件重新加载规则,文件有误时保留原有规则并返回错误。
+func (f *IPFilter) ReloadFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	allow, deny, err := ParseIPFilterRules(file)
+	if err != nil {
+		return err
+	}
+	return f.Reload(allow, deny)
+}
+
+// WatchFile 按interval检查规则文件的修改时间,发生变化时重新加载,返回的函数用于停止检查。
+func (f *IPFilter) WatchFile(filename string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	last := fileStamp(filename)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				stamp := fileStamp(filename)
+				if stamp == "" || stamp == last {
+					continue
+				}
+				if err := f.ReloadFile(filename); err != nil {
+					f.logger().Printf("ip filter: reload %s: %v", filename, err)
+					continue
+				}
+				last = stamp
+			}
+		}
+	}()
+	var once int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&once, 0, 1) {
+			close(done)
+		}
+	}
+}
+
+// Decide 判断ip是否放行
+func (f *IPFilter) Decide(ip string) IPDecision {
+	d := IPDecision{IP: ip, Rule: "invalid"}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return d
+	}
+	rules, _ := f.rules.Load().(*ipRules)
+	if rules == nil {
+		d.Rule = "no rules"
+		return d
+	}
+	d.Allowed, d.Rule = rules.decide(addr)
+	return d
+}
+
+// Allowed 判断ip是否放行
+func (f *IPFilter) Allowed(ip string) bool {
+	return f.Decide(ip).Allowed
+}
+
+// Handler 返回过滤请求的http.Handler,被拒绝的请求返回Status
+func (f *IPFilter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := f.Decide(f.clientIP(r))
+		if !d.Allowed || f.LogAllowed {
+			f.logger().Printf("ip filter: %s %s %s allowed=%v rule=%q", d.IP, r.Method, r.URL.Path, d.Allowed, d.Rule)
+		}
+		if !d.Allowed {
+			status := f.Status
+			if status == 0 {
+				status = http.StatusForbidden
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IPFilterMiddleware 以中间件的形式使用IPFilter
+func IPFilterMiddleware(f *IPFilter) func(http.Handler) http.Handler {
+	return f.Handler
+}
+
+// clientIP 使用Resolver解析客户端IP,未设置时只使用RemoteAddr
+func (f *IPFilter) clientIP(r *http.Request) string {
+	return requestIP(r, f.Resolver)
+}
+
+func (f *IPFilter) logger() *log.Logger {
+	if f.Logger != nil {
+		return f.Logger
+	}
+	return log.Default()
+}
+
+// fileStamp 由修改时间和大小组成的文件版本标识,文件不存在时返回空串
+func fileStamp(filename string) string {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size())
+}
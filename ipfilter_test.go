@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIPFilterDecide(t *testing.T) {
+	f, err := NewIPFilter(
+		[]string{"10.0.0.0/8", "10.1.2.0/24", "192.168.1.0/24", "2001:db8::/32"},
+		[]string{"10.1.0.0/16", "192.168.1.0/24", "2001:db8:bad::/48"},
+	)
+	if err != nil {
+		t.Fatalf("NewIPFilter:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	cases := map[string]IPDecision{
+		"10.2.3.4":        {Allowed: true, Rule: "allow 10.0.0.0/8"},
+		"10.1.3.4":        {Allowed: false, Rule: "deny 10.1.0.0/16"},
+		"10.1.2.3":        {Allowed: true, Rule: "allow 10.1.2.0/24"},
+		"192.168.1.5":     {Allowed: false, Rule: "deny 192.168.1.0/24"},
+		"8.8.8.8":         {Allowed: false, Rule: "default"},
+		"2001:db8::1":     {Allowed: true, Rule: "allow 2001:db8::/32"},
+		"2001:db8:bad::1": {Allowed: false, Rule: "deny 2001:db8:bad::/48"},
+		"not-an-ip":       {Allowed: false, Rule: "invalid"},
+	}
+	for ip, expected := range cases {
+		expected.IP = ip
+		if got := f.Decide(ip); got != expected {
+			t.Errorf("Decide(%s):\n Expect => %+v\n Got => %+v\n", ip, expected, got)
+		}
+	}
+
+	denyOnly, _ := NewIPFilter(nil, []string{"203.0.113.7"})
+	if !denyOnly.Allowed("203.0.113.8") || denyOnly.Allowed("203.0.113.7") {
+		t.Errorf("Allowed:\n Expect => deny only 203.0.113.7\n Got => wrong decision\n")
+	}
+	if _, err = NewIPFilter([]string{"bad"}, nil); err == nil {
+		t.Errorf("NewIPFilter:\n Expect => error\n Got => %v\n", err)
+	}
+}
+
+func TestIPFilterHandler(t *testing.T) {
+	var buf bytes.Buffer
+	f, _ := NewIPFilter(nil, []string{"198.51.100.0/24"})
+	f.Status = http.StatusTeapot
+	f.Logger = log.New(&buf, "", 0)
+	h := f.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for ip, status := range map[string]int{"198.51.100.9": http.StatusTeapot, "192.0.2.1": http.StatusNoContent} {
+		r := httptest.NewRequest("GET", "/admin", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("Handler(%s):\n Expect => %d\n Got => %d\n", ip, status, w.Code)
+		}
+	}
+	if !strings.Contains(buf.String(), "198.51.100.9 GET /admin allowed=false") || strings.Contains(buf.String(), "192.0.2.1") {
+		t.Errorf("Handler log:\n Expect => denied request only\n Got => %s\n", buf.String())
+	}
+}
+
+func TestIPFilterSpoofedHeader(t *testing.T) {
+	allow, _ := NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	deny, _ := NewIPFilter(nil, []string{"198.51.100.0/24"})
+	for _, f := range []*IPFilter{allow, deny} {
+		f.Logger = log.New(ioutil.Discard, "", 0)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	serve := func(f *IPFilter, remote string, header map[string]string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		f.Handler(h).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// 客户端伪造的请求头既不能绕过允许列表,也不能绕过拒绝列表
+	spoof := map[string]string{"X-Real-IP": "10.0.0.1", "X-Forwarded-For": "10.0.0.1"}
+	if code := serve(allow, "203.0.113.5:1234", spoof); code != http.StatusForbidden {
+		t.Errorf("Handler(spoofed allow):\n Expect => %d\n Got => %d\n", http.StatusForbidden, code)
+	}
+	if code := serve(deny, "198.51.100.9:1234", map[string]string{"X-Real-IP": "192.0.2.1"}); code != http.StatusForbidden {
+		t.Errorf("Handler(spoofed deny):\n Expect => %d\n Got => %d\n", http.StatusForbidden, code)
+	}
+
+	// 设置Resolver后只信任来自可信代理的X-Forwarded-For
+	allow.Resolver, _ = NewIPResolver("192.0.2.0/24")
+	if code := serve(allow, "192.0.2.10:1234", spoof); code != http.StatusNoContent {
+		t.Errorf("Handler(trusted proxy):\n Expect => %d\n Got => %d\n", http.StatusNoContent, code)
+	}
+	if code := serve(allow, "203.0.113.5:1234", spoof); code != http.StatusForbidden {
+		t.Errorf("Handler(untrusted peer):\n Expect => %d\n Got => %d\n", http.StatusForbidden, code)
+	}
+
+	// 零值没有规则,拒绝所有请求而不是panic
+	var zero IPFilter
+	if d := zero.Decide("192.0.2.1"); d.Allowed || d.Rule != "no rules" {
+		t.Errorf("Decide(zero value):\n Expect => denied\n Got => %+v\n", d)
+	}
+}
+
+func TestIPFilterFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "rules.txt")
+	ioutil.WriteFile(name, []byte("# office\nallow 10.0.0.0/8\ndeny 10.9.0.0/16 # lab\n\n"), 0644)
+
+	f, err := NewIPFilterFromFile(name)
+	if err != nil {
+		t.Fatalf("NewIPFilterFromFile:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	if !f.Allowed("10.1.1.1") || f.Allowed("10.9.1.1") || f.Allowed("8.8.8.8") {
+		t.Errorf("NewIPFilterFromFile:\n Expect => rules applied\n Got => wrong decision\n")
+	}
+
+	f.Logger = log.New(ioutil.Discard, "", 0)
+	stop := f.WatchFile(name, 10*time.Millisecond)
+	defer stop()
+	ioutil.WriteFile(name, []byte("allow 8.8.8.0/24\n"), 0644)
+	os.Chtimes(name, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	deadline := time.Now().Add(2 * time.Second)
+	for !f.Allowed("8.8.8.8") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !f.Allowed("8.8.8.8") || f.Allowed("10.1.1.1") {
+		t.Errorf("WatchFile:\n Expect => reloaded rules\n Got => old rules\n")
+	}
+
+	if _, _, err = ParseIPFilterRules(strings.NewReader("permit 10.0.0.0/8")); err == nil {
+		t.Errorf("ParseIPFilterRules:\n Expect => error\n Got => %v\n", err)
+	}
+}
+
+func BenchmarkIPFilterDecide(b *testing.B) {
+	var deny []string
+	for i := 0; i < 100000; i++ {
+		deny = append(deny, fmt.Sprintf("100.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+	}
+	f, _ := NewIPFilter(nil, deny)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Decide("100.1.2.3")
+	}
+}
@@ -0,0 +1,391 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// HKDF 使用HKDF-SHA256(RFC 5869)从secret派生length字节的密钥,length最大为255*32字节。
+// 同一secret用不同的info可以派生出互不相关的子密钥。
+func HKDF(secret, salt, info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*sha256.Size {
+		return nil, fmt.Errorf("%w: hkdf output must be 1 to %d bytes, got %d", ErrKeySize, 255*sha256.Size, length)
+	}
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// 信封加密的格式:
+//
+//	version(1字节) | len(wrapped key)(2字节,大端) | wrapped key | ciphertext
+//
+// 每条记录使用随机的32字节数据密钥以AES-256-GCM加密,wrapped key是用主密钥加密后的数据密钥,
+// ciphertext是用数据密钥加密的数据,两者都是AEADEnvelope格式,wrapped key中记录了主密钥ID。
+// aad同时参与数据密钥和数据的认证,用于把密文绑定到表名、字段名和主键等上下文。
+const (
+	keyringVersion     = 1
+	keyringDataKeySize = 32
+)
+
+// Keyring 按ID管理的主密钥集合,用于信封加密和派生子密钥,可以并发使用。
+//
+// 新数据总是使用当前主密钥加密,解密时按密文中记录的主密钥ID选择密钥,
+// 因此轮换主密钥后旧数据仍然可以解密,可以再用Rewrap逐步迁移到新密钥。
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]*keyringKey
+	ids     []string
+	current string
+}
+
+type keyringKey struct {
+	secret []byte
+	aead   *AEAD
+}
+
+// NewKeyring 创建空的Keyring,第一个加入的主密钥成为当前密钥
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]*keyringKey)}
+}
+
+// ParseKeyring 解析 "id:base64密钥" 格式的主密钥列表,以逗号、空白或换行分隔,#开头的行为注释。
+// 第一个主密钥为当前密钥,轮换时把新密钥加在最前面即可,例如 "2024:xxx,2023:yyy"。
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := NewKeyring()
+	var lines []string
+	for _, line := range strings.Split(spec, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	fields := strings.FieldsFunc(strings.Join(lines, ","), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r'
+	})
+	for _, field := range fields {
+		i := strings.IndexByte(field, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("keyring: entry must be id:base64key, got %d bytes without id", len(field))
+		}
+		id := field[:i]
+		// 不在错误信息中包含密钥内容
+		key, err := base64.StdEncoding.DecodeString(field[i+1:])
+		if err != nil {
+			if key, err = base64.RawStdEncoding.DecodeString(field[i+1:]); err != nil {
+				return nil, fmt.Errorf("keyring: key %q is not valid base64", id)
+			}
+		}
+		if err = k.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if len(k.ids) == 0 {
+		return nil, errors.New("keyring: no keys")
+	}
+	return k, nil
+}
+
+// LoadKeyringEnv 从环境变量加载主密钥,格式见ParseKeyring
+func LoadKeyringEnv(name string) (*Keyring, error) {
+	spec, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("keyring: environment variable %s is not set", name)
+	}
+	k, err := ParseKeyring(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return k, nil
+}
+
+// LoadKeyringFile 从文件加载主密钥,每行一个,格式见ParseKeyring;文件权限应为0600
+func LoadKeyringFile(file string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	k, err := ParseKeyring(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return k, nil
+}
+
+// Add 加入主密钥,key为16、24或32字节(推荐32字节),id最长255字节且不能包含冒号、逗号或空白
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 || strings.ContainsAny(id, ":, \t\r\n") {
+		return fmt.Errorf("keyring: invalid key id %q", id)
+	}
+	if err := checkAESKey(key); err != nil {
+		return fmt.Errorf("keyring: master key %q: %w", id, err)
+	}
+	secret := append([]byte(nil), key...)
+	aead, err := NewAEAD(AEADAESGCM, id, secret)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("keyring: duplicate key id %q", id)
+	}
+	k.keys[id] = &keyringKey{secret: secret, aead: aead}
+	k.ids = append(k.ids, id)
+	if k.current == "" {
+		k.current = id
+	}
+	return nil
+}
+
+// Rotate 加入新的主密钥并设为当前密钥,旧密钥保留用于解密
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+	return k.SetCurrent(id)
+}
+
+// SetCurrent 设置加密新数据使用的主密钥
+func (k *Keyring) SetCurrent(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: keyring key %q", ErrAEADKeyNotFound, id)
+	}
+	k.current = id
+	return nil
+}
+
+// Current 当前主密钥的ID
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// IDs 按加入顺序返回所有主密钥的ID
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([]string(nil), k.ids...)
+}
+
+// Remove 移除不再使用的主密钥,不能移除当前密钥;移除后用它加密的数据无法再解密
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: keyring key %q", ErrAEADKeyNotFound, id)
+	}
+	if id == k.current {
+		return fmt.Errorf("keyring: cannot remove current key %q", id)
+	}
+	delete(k.keys, id)
+	for i, v := range k.ids {
+		if v == id {
+			k.ids = append(k.ids[:i], k.ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// key 按ID查找主密钥,id为空时返回当前密钥
+func (k *Keyring) key(id string) (string, *keyringKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if id == "" {
+		id = k.current
+	}
+	m, ok := k.keys[id]
+	if !ok {
+		return id, nil, fmt.Errorf("%w: keyring key %q", ErrAEADKeyNotFound, id)
+	}
+	return id, m, nil
+}
+
+// Subkey 使用HKDF-SHA256从主密钥id派生用途为purpose的子密钥,例如HMAC盲索引或Cookie签名的密钥
+func (k *Keyring) Subkey(id, purpose string, length int) ([]byte, error) {
+	_, m, err := k.key(id)
+	if err != nil {
+		return nil, err
+	}
+	return HKDF(m.secret, nil, []byte(purpose), length)
+}
+
+// CurrentSubkey 从当前主密钥派生子密钥,同时返回主密钥ID以便保存
+func (k *Keyring) CurrentSubkey(purpose string, length int) (string, []byte, error) {
+	id, m, err := k.key("")
+	if err != nil {
+		return id, nil, err
+	}
+	key, err := HKDF(m.secret, nil, []byte(purpose), length)
+	return id, key, err
+}
+
+// Encrypt 使用随机数据密钥加密plaintext,数据密钥由当前主密钥加密后保存在密文中
+func (k *Keyring) Encrypt(plaintext, aad []byte) ([]byte, error) {
+	_, m, err := k.key("")
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := RandomBytes(keyringDataKeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(dataKey)
+	wrapped, err := m.aead.Seal(dataKey, aad)
+	if err != nil {
+		return nil, err
+	}
+	data, err := NewAEAD(AEADAESGCM, "", dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := data.Seal(plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	return keyringEnvelope(wrapped, ciphertext), nil
+}
+
+// Decrypt 解密Encrypt生成的密文,主密钥不存在时返回ErrAEADKeyNotFound,认证失败时返回ErrAEADAuth
+func (k *Keyring) Decrypt(data, aad []byte) ([]byte, error) {
+	wrapped, ciphertext, err := parseKeyringEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := k.unwrap(wrapped, aad)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(dataKey)
+	a, err := NewAEAD(AEADAESGCM, "", dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return a.Open(ciphertext, aad)
+}
+
+// EncryptString 加密字符串并以base64输出,适合保存到数据库的文本字段
+func (k *Keyring) EncryptString(plaintext string, aad []byte) (string, error) {
+	data, err := k.Encrypt([]byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecryptString 解密EncryptString的结果
+func (k *Keyring) DecryptString(s string, aad []byte) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrAEADEnvelope, err)
+	}
+	plaintext, err := k.Decrypt(data, aad)
+	return string(plaintext), err
+}
+
+// KeyID 返回加密data所用的主密钥ID,不解密,用于找出需要Rewrap的数据
+func (k *Keyring) KeyID(data []byte) (string, error) {
+	wrapped, _, err := parseKeyringEnvelope(data)
+	if err != nil {
+		return "", err
+	}
+	e, err := ParseAEADEnvelope(wrapped)
+	if err != nil {
+		return "", err
+	}
+	return e.KeyID, nil
+}
+
+// Rewrap 用当前主密钥重新加密data中的数据密钥,数据部分不变;已经使用当前密钥时原样返回。
+// 轮换主密钥后对旧数据执行Rewrap,完成后即可Remove旧密钥。
+func (k *Keyring) Rewrap(data, aad []byte) ([]byte, error) {
+	wrapped, ciphertext, err := parseKeyringEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	e, err := ParseAEADEnvelope(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	_, m, err := k.key("")
+	if err != nil {
+		return nil, err
+	}
+	if e.KeyID == m.aead.KeyID() {
+		return data, nil
+	}
+	dataKey, err := k.unwrap(wrapped, aad)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(dataKey)
+	// 确认数据密钥能解密数据,避免把损坏的记录迁移到新密钥下
+	a, err := NewAEAD(AEADAESGCM, "", dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = a.Open(ciphertext, aad); err != nil {
+		return nil, err
+	}
+	if wrapped, err = m.aead.Seal(dataKey, aad); err != nil {
+		return nil, err
+	}
+	return keyringEnvelope(wrapped, ciphertext), nil
+}
+
+// unwrap 使用密文中记录的主密钥解密数据密钥
+func (k *Keyring) unwrap(wrapped, aad []byte) ([]byte, error) {
+	e, err := ParseAEADEnvelope(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	_, m, err := k.key(e.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := m.aead.open(e, aad)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataKey) != keyringDataKeySize {
+		return nil, fmt.Errorf("%w: bad data key", ErrAEADEnvelope)
+	}
+	return dataKey, nil
+}
+
+func keyringEnvelope(wrapped, ciphertext []byte) []byte {
+	res := make([]byte, 3, 3+len(wrapped)+len(ciphertext))
+	res[0] = keyringVersion
+	binary.BigEndian.PutUint16(res[1:], uint16(len(wrapped)))
+	res = append(res, wrapped...)
+	return append(res, ciphertext...)
+}
+
+func parseKeyringEnvelope(data []byte) (wrapped, ciphertext []byte, err error) {
+	if len(data) < 3 {
+		return nil, nil, ErrAEADEnvelope
+	}
+	if data[0] != keyringVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported keyring version %d", ErrAEADEnvelope, data[0])
+	}
+	n := int(binary.BigEndian.Uint16(data[1:]))
+	if len(data) < 3+n {
+		return nil, nil, fmt.Errorf("%w: too short", ErrAEADEnvelope)
+	}
+	return data[3 : 3+n], data[3+n:], nil
+}
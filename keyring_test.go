@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHKDF(t *testing.T) {
+	// RFC 5869 A.1
+	okm, err := HKDF(bytes.Repeat([]byte{0x0b}, 22), mustHex("000102030405060708090a0b0c"), mustHex("f0f1f2f3f4f5f6f7f8f9"), 42)
+	expected := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+	if err != nil || Digest(okm).Hex() != expected {
+		t.Errorf("HKDF:\n Expect => %s\n Got => %x %v\n", expected, okm, err)
+	}
+	for _, n := range []int{0, 255*32 + 1} {
+		if _, err := HKDF(okm, nil, nil, n); !errors.Is(err, ErrKeySize) {
+			t.Errorf("HKDF(%d):\n Expect => %v\n Got => %v\n", n, ErrKeySize, err)
+		}
+	}
+}
+
+func TestKeySize(t *testing.T) {
+	if _, err := AesEncrypt([]byte("data"), []byte("short")); !errors.Is(err, ErrKeySize) {
+		t.Errorf("AesEncrypt:\n Expect => %v\n Got => %v\n", ErrKeySize, err)
+	}
+	if _, err := NewAEAD(AEADChaCha20Poly1305, "", make([]byte, 16)); !errors.Is(err, ErrKeySize) {
+		t.Errorf("NewAEAD:\n Expect => %v\n Got => %v\n", ErrKeySize, err)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	old, cur := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	spec := "# 当前密钥在前\nv2:" + base64.StdEncoding.EncodeToString(cur) + "\n" +
+		"v1:" + base64.RawStdEncoding.EncodeToString(old) + "\n"
+	k, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Current() != "v2" || len(k.IDs()) != 2 {
+		t.Errorf("ParseKeyring:\n Expect => v2 [v2 v1]\n Got => %s %v\n", k.Current(), k.IDs())
+	}
+
+	// 轮换前用v1加密的旧数据
+	prev := NewKeyring()
+	prev.Add("v1", old)
+	aad := []byte("users.phone:42")
+	legacy, err := prev.Encrypt([]byte("13800138000"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := k.Encrypt([]byte("13800138000"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{legacy, fresh} {
+		if got, err := k.Decrypt(data, aad); err != nil || string(got) != "13800138000" {
+			t.Errorf("Decrypt:\n Expect => 13800138000\n Got => %s %v\n", got, err)
+		}
+	}
+	if id, _ := k.KeyID(legacy); id != "v1" {
+		t.Errorf("KeyID:\n Expect => v1\n Got => %s\n", id)
+	}
+	if _, err := k.Decrypt(legacy, []byte("users.phone:43")); !errors.Is(err, ErrAEADAuth) {
+		t.Errorf("Decrypt(aad):\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+	}
+	tampered := append([]byte(nil), fresh...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := k.Decrypt(tampered, aad); !errors.Is(err, ErrAEADAuth) {
+		t.Errorf("Decrypt(tampered):\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+	}
+
+	// Rewrap只替换数据密钥的封装,之后可以移除旧密钥
+	rewrapped, err := k.Rewrap(legacy, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := k.KeyID(rewrapped); id != "v2" {
+		t.Errorf("Rewrap:\n Expect => v2\n Got => %s\n", id)
+	}
+	if same, _ := k.Rewrap(fresh, aad); !bytes.Equal(same, fresh) {
+		t.Errorf("Rewrap(current):\n Expect => unchanged\n")
+	}
+	if err := k.Remove("v2"); err == nil {
+		t.Errorf("Remove(current):\n Expect => error\n Got => nil\n")
+	}
+	if err := k.Remove("v1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := k.Decrypt(rewrapped, aad); err != nil || string(got) != "13800138000" {
+		t.Errorf("Decrypt(rewrapped):\n Expect => 13800138000\n Got => %s %v\n", got, err)
+	}
+	if _, err := k.Decrypt(legacy, aad); !errors.Is(err, ErrAEADKeyNotFound) {
+		t.Errorf("Decrypt(removed):\n Expect => %v\n Got => %v\n", ErrAEADKeyNotFound, err)
+	}
+
+	if err := k.Rotate("v3", make([]byte, 32)); err != nil || k.Current() != "v3" {
+		t.Errorf("Rotate:\n Expect => v3\n Got => %s %v\n", k.Current(), err)
+	}
+	s, err := k.EncryptString("secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := k.DecryptString(s, nil); err != nil || got != "secret" {
+		t.Errorf("DecryptString:\n Expect => secret\n Got => %s %v\n", got, err)
+	}
+}
+
+func TestKeyringSubkey(t *testing.T) {
+	k := NewKeyring()
+	k.Add("v1", bytes.Repeat([]byte{1}, 32))
+	a, _ := k.Subkey("v1", "search index", 32)
+	b, _ := k.Subkey("v1", "cookie", 32)
+	id, c, err := k.CurrentSubkey("search index", 32)
+	if err != nil || id != "v1" || !bytes.Equal(a, c) || bytes.Equal(a, b) {
+		t.Errorf("Subkey:\n Expect => same purpose same key\n Got => %s %x %x %x %v\n", id, a, b, c, err)
+	}
+	expected, _ := HKDF(bytes.Repeat([]byte{1}, 32), nil, []byte("search index"), 32)
+	if !bytes.Equal(a, expected) {
+		t.Errorf("Subkey:\n Expect => %x\n Got => %x\n", expected, a)
+	}
+	if _, err := k.Subkey("v9", "x", 32); !errors.Is(err, ErrAEADKeyNotFound) {
+		t.Errorf("Subkey(unknown):\n Expect => %v\n Got => %v\n", ErrAEADKeyNotFound, err)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	os.Setenv("UTILS_TEST_KEYRING", "a:"+key+", b:"+key)
+	defer os.Unsetenv("UTILS_TEST_KEYRING")
+	if k, err := LoadKeyringEnv("UTILS_TEST_KEYRING"); err != nil || k.Current() != "a" {
+		t.Errorf("LoadKeyringEnv:\n Expect => a\n Got => %v\n", err)
+	}
+	if _, err := LoadKeyringEnv("UTILS_TEST_KEYRING_UNSET"); err == nil {
+		t.Errorf("LoadKeyringEnv(unset):\n Expect => error\n Got => nil\n")
+	}
+	file := filepath.Join(t.TempDir(), "keys")
+	ioutil.WriteFile(file, []byte("b:"+key+"\n"), 0600)
+	if k, err := LoadKeyringFile(file); err != nil || k.Current() != "b" {
+		t.Errorf("LoadKeyringFile:\n Expect => b\n Got => %v\n", err)
+	}
+
+	for _, spec := range []string{
+		"",
+		"# only comment",
+		key,
+		"a:not base64!",
+		"a:" + base64.StdEncoding.EncodeToString(make([]byte, 20)),
+		"a:" + key + ",a:" + key,
+	} {
+		if _, err := ParseKeyring(spec); err == nil {
+			t.Errorf("ParseKeyring(%q):\n Expect => error\n Got => nil\n", spec)
+		}
+	}
+	if _, err := ParseKeyring("a:" + base64.StdEncoding.EncodeToString(make([]byte, 20))); !errors.Is(err, ErrKeySize) {
+		t.Errorf("ParseKeyring(short key):\n Expect => %v\n Got => %v\n", ErrKeySize, err)
+	}
+}
@@ -2,11 +2,10 @@ package utils
 
 import (
 	"bytes"
-	"fmt"
 	"math"
 	"math/rand"
+	"sync"
 	"time"
-	"unsafe"
 )
 
 // 浮点数除法
@@ -28,33 +27,27 @@ func PowInt(x int, y int) int {
 	}
 }
 
+// RandInt 返回[start, end)中的随机数,end不大于start时返回start。
+// 使用math/rand,不能用于安全相关的场景,需要时使用RandomInt。
 func RandInt(start int, end int) int {
-	// 范围检查
-	if end < start {
-		return 0
+	if end <= start {
+		return start
 	}
-	// 随机数生成器，加入时间戳保证每次生成的随机数不一样
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	// 生成随机数
-	return r.Intn(end-start) + start
+	return int(mathRand.Int63n(int64(end-start))) + start
 }
 
+// RandInt64 返回[start, end)中的随机数,end不大于start时返回start。
+// 使用math/rand,不能用于安全相关的场景,需要时使用RandomInt。
 func RandInt64(start int64, end int64) int64 {
-	// 范围检查
-	if end < start {
-		return 0
+	if end <= start {
+		return start
 	}
-	// 随机数生成器，加入时间戳保证每次生成的随机数不一样
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	// 生成随机数
-	return r.Int63n(end-start) + start
+	return mathRand.Int63n(end-start) + start
 }
 
-// 生成六位随机码
+// GenerateRandomCode 使用crypto/rand生成六位数字验证码
 func GenerateRandomCode() string {
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
-	code := fmt.Sprintf("%06v", rnd.Int31n(1000000))
-	return code
+	return mustRandom(RandomDigits(6))
 }
 
 // 指定长度随机中文字符(包含复杂字符)
@@ -72,45 +65,38 @@ func GenRandomLengthChineseChars(start, end int) string {
 	return GenFixedLengthChineseChars(length)
 }
 
-// 随机英文小写字母
+// RandStr 使用crypto/rand生成随机英文小写字母
 func RandStr(len int) string {
-	rand.Seed(time.Now().UnixNano())
-	data := make([]byte, len)
-	for i := 0; i < len; i++ {
-		data[i] = byte(rand.Intn(26) + 97)
-	}
-	return string(data)
+	return mustRandom(RandomString(len, AlphabetLower))
 }
 
-/**
- * 生成指定长度的随机字母和数字字符串，包括0-9、a-z、A-Z的所有字符。
- *
- * @param length
- * @return
- */
+// RandString 使用crypto/rand生成指定长度的随机字母和数字字符串，包括0-9、a-z、A-Z的所有字符。
 func RandString(n int) string {
-	b := make([]byte, n)
-	// A src.Int63() generates 63 random bits, enough for letterIdxMax characters!
-	for i, cache, remain := n-1, src.Int63(), letterIdxMax; i >= 0; {
-		if remain == 0 {
-			cache, remain = src.Int63(), letterIdxMax
-		}
-		if idx := int(cache & letterIdxMask); idx < len(letterBytes) {
-			b[i] = letterBytes[idx]
-			i--
-		}
-		cache >>= letterIdxBits
-		remain--
-	}
+	return mustRandom(RandomString(n, AlphabetAlphanumeric))
+}
+
+// mathRand 非加密安全的随机数生成器,只初始化一次,可以并发使用
+var mathRand = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano()).(rand.Source64)})
 
-	return *(*string)(unsafe.Pointer(&b))
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
 }
 
-var src = rand.NewSource(time.Now().UnixNano())
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
 
-const letterBytes = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-const (
-	letterIdxBits = 6                    // 6 bits to represent a letter index
-	letterIdxMask = 1<<letterIdxBits - 1 // All 1-bits, as many as letterIdxBits
-	letterIdxMax  = 63 / letterIdxBits   // # of letter indices fitting in 63 bits
-)
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
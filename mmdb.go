@@ -0,0 +1,378 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// MaxMind DB(MMDB)格式的只读解析,格式说明见
+// https://maxmind.github.io/MaxMind-DB/
+
+var (
+	// ErrMMDBInvalid 文件不是合法的MMDB数据库
+	ErrMMDBInvalid = errors.New("invalid MaxMind DB file")
+
+	mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+)
+
+// mmdbDataSeparator 搜索树和数据区之间的16字节分隔
+const mmdbDataSeparator = 16
+
+// mmdbMetadata 数据库元数据
+type mmdbMetadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	Languages    []string
+	BuildEpoch   uint64
+	Description  map[string]string
+}
+
+// mmdbReader 在一段只读内存上解析MMDB,可并发使用
+type mmdbReader struct {
+	buf       []byte
+	data      []byte
+	meta      mmdbMetadata
+	nodeBytes uint
+	ipv4Start uint
+}
+
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	start := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if start < 0 {
+		return nil, ErrMMDBInvalid
+	}
+	start += len(mmdbMetadataMarker)
+	d := mmdbDecoder{buf: buf[start:]}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrMMDBInvalid
+	}
+	r := &mmdbReader{buf: buf}
+	r.meta.NodeCount = uint(mmdbUint(m["node_count"]))
+	r.meta.RecordSize = uint(mmdbUint(m["record_size"]))
+	r.meta.IPVersion = uint(mmdbUint(m["ip_version"]))
+	r.meta.BuildEpoch = mmdbUint(m["build_epoch"])
+	r.meta.DatabaseType, _ = m["database_type"].(string)
+	if langs, ok := m["languages"].([]interface{}); ok {
+		for _, l := range langs {
+			if s, ok := l.(string); ok {
+				r.meta.Languages = append(r.meta.Languages, s)
+			}
+		}
+	}
+	if desc, ok := m["description"].(map[string]interface{}); ok {
+		r.meta.Description = make(map[string]string, len(desc))
+		for k, v := range desc {
+			r.meta.Description[k], _ = v.(string)
+		}
+	}
+	switch r.meta.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrMMDBInvalid, r.meta.RecordSize)
+	}
+	r.nodeBytes = r.meta.RecordSize / 4
+	treeSize := r.meta.NodeCount * r.nodeBytes
+	dataStart := treeSize + mmdbDataSeparator
+	dataEnd := uint(start - len(mmdbMetadataMarker))
+	if dataStart > dataEnd {
+		return nil, fmt.Errorf("%w: search tree exceeds file size", ErrMMDBInvalid)
+	}
+	r.data = buf[dataStart:dataEnd]
+
+	// IPv6数据库中IPv4地址位于::/96之下,预先走完前96位。
+	if r.meta.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.meta.NodeCount; i++ {
+			node, err = r.readNode(node, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// readNode 读取节点的左(bit=0)或右(bit=1)记录
+func (r *mmdbReader) readNode(node uint, bit uint) (uint, error) {
+	off := node * r.nodeBytes
+	if off+r.nodeBytes > uint(len(r.buf)) {
+		return 0, fmt.Errorf("%w: node %d out of range", ErrMMDBInvalid, node)
+	}
+	b := r.buf[off : off+r.nodeBytes]
+	switch r.meta.RecordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return (uint(b[3])&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return (uint(b[3])&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:])), nil
+	}
+}
+
+// lookup 查找ip,返回解码后的数据和匹配的前缀长度;未找到时数据为nil
+func (r *mmdbReader) lookup(ip net.IP) (interface{}, int, error) {
+	node, prefix := uint(0), 0
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if r.meta.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.meta.IPVersion == 4 {
+		return nil, 0, fmt.Errorf("cannot look up IPv6 address %s in an IPv4-only database", ip)
+	} else if len(ip) != net.IPv6len {
+		return nil, 0, fmt.Errorf("invalid IP address %v", ip)
+	}
+	var err error
+	for bits := len(ip) * 8; prefix < bits && node < r.meta.NodeCount; prefix++ {
+		node, err = r.readNode(node, uint(ip[prefix>>3]>>(7-uint(prefix&7))&1))
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	switch {
+	case node == r.meta.NodeCount:
+		return nil, prefix, nil
+	case node < r.meta.NodeCount:
+		return nil, prefix, fmt.Errorf("%w: invalid node in search tree", ErrMMDBInvalid)
+	}
+	pointer := node - r.meta.NodeCount - mmdbDataSeparator
+	if pointer >= uint(len(r.data)) {
+		return nil, prefix, fmt.Errorf("%w: data pointer out of range", ErrMMDBInvalid)
+	}
+	d := mmdbDecoder{buf: r.data}
+	v, _, err := d.decode(pointer)
+	return v, prefix, err
+}
+
+// mmdbDecoder 解码数据区
+type mmdbDecoder struct {
+	buf   []byte
+	depth int
+}
+
+// mmdbMaxDepth 嵌套容器的最大深度,防止损坏的文件造成无限递归
+const mmdbMaxDepth = 512
+
+// MMDB数据类型
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// decode 解码offset处的值,返回值和下一个值的位置
+func (d *mmdbDecoder) decode(offset uint) (interface{}, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == mmdbPointer {
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// 指针不能指向另一个指针
+		typ, size, offset, err = d.control(pointer)
+		if err != nil {
+			return nil, 0, err
+		}
+		if typ == mmdbPointer {
+			return nil, 0, fmt.Errorf("%w: pointer to pointer", ErrMMDBInvalid)
+		}
+		v, _, err := d.value(typ, size, offset)
+		return v, next, err
+	}
+	return d.value(typ, size, offset)
+}
+
+// control 解析控制字节,返回类型、长度和数据起始位置
+func (d *mmdbDecoder) control(offset uint) (typ int, size uint, next uint, err error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, fmt.Errorf("%w: unexpected end of data", ErrMMDBInvalid)
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ = int(ctrl >> 5)
+	if typ == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("%w: unexpected end of data", ErrMMDBInvalid)
+		}
+		typ = int(d.buf[offset]) + 7
+		offset++
+	}
+	size = uint(ctrl & 0x1f)
+	if typ == mmdbPointer || size < 29 {
+		return typ, size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, 0, fmt.Errorf("%w: unexpected end of data", ErrMMDBInvalid)
+	}
+	v := uint(0)
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(b)
+	}
+	switch size {
+	case 29:
+		size = 29 + v
+	case 30:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return typ, size, offset + n, nil
+}
+
+// pointer 解析指针,size为控制字节的低5位
+func (d *mmdbDecoder) pointer(size uint, offset uint) (uint, uint, error) {
+	n := (size>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("%w: unexpected end of data", ErrMMDBInvalid)
+	}
+	v := uint(0)
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(b)
+	}
+	switch n {
+	case 1:
+		v = (size&0x7)<<8 | v
+	case 2:
+		v = ((size&0x7)<<16 | v) + 2048
+	case 3:
+		v = ((size&0x7)<<24 | v) + 526336
+	}
+	return v, offset + n, nil
+}
+
+func (d *mmdbDecoder) value(typ int, size uint, offset uint) (interface{}, uint, error) {
+	if typ == mmdbMap || typ == mmdbArray {
+		if d.depth >= mmdbMaxDepth {
+			return nil, 0, fmt.Errorf("%w: data nested too deeply", ErrMMDBInvalid)
+		}
+		// 每个元素至少占用一个字节
+		if size > uint(len(d.buf))-offset {
+			return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrMMDBInvalid)
+		}
+		d.depth++
+		defer func() { d.depth-- }()
+	}
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrMMDBInvalid)
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrMMDBInvalid)
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case mmdbString:
+		return string(b), next, nil
+	case mmdbBytes:
+		return append([]byte(nil), b...), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: invalid double size %d", ErrMMDBInvalid, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: invalid float size %d", ErrMMDBInvalid, size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrMMDBInvalid, size)
+		}
+		v := uint64(0)
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid integer size %d", ErrMMDBInvalid, size)
+		}
+		v := uint32(0)
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	case mmdbUint128:
+		// 128位整数以大端字节返回
+		return append([]byte(nil), b...), next, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown data type %d", ErrMMDBInvalid, typ)
+}
+
+// mmdbUint 将解码出的整数转换为uint64
+func mmdbUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		if n > 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
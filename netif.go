@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoPreferredIP 没有符合条件的本机地址
+var ErrNoPreferredIP = errors.New("no suitable local IP address")
+
+// NetInterface 网卡信息
+type NetInterface struct {
+	Index int
+	Name  string
+	MTU   int
+	// MAC 硬件地址,回环和部分隧道网卡为空
+	MAC   string
+	Flags net.Flags
+	// Virtual 是否为虚拟网卡或网桥,如docker0、veth*、br-*、virbr*、tun/tap等;
+	// bond、VLAN和容器内的eth0不算虚拟网卡
+	Virtual bool
+	Addrs   []InterfaceAddr
+}
+
+// IsUp 网卡是否已启用
+func (i NetInterface) IsUp() bool {
+	return isUp(i.Flags)
+}
+
+// IsLoopback 是否为回环网卡
+func (i NetInterface) IsLoopback() bool {
+	return i.Flags&net.FlagLoopback != 0
+}
+
+func (i NetInterface) String() string {
+	return fmt.Sprintf("%d: %s mtu %d mac %s flags %s virtual=%v addrs %v", i.Index, i.Name, i.MTU, i.MAC, i.Flags, i.Virtual, i.Addrs)
+}
+
+// NetInterfaces 列出全部网卡,包括未启用的网卡
+func NetInterfaces() ([]NetInterface, error) {
+	inters, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]NetInterface, 0, len(inters))
+	for _, inter := range inters {
+		ni := NetInterface{
+			Index:   inter.Index,
+			Name:    inter.Name,
+			MTU:     inter.MTU,
+			MAC:     inter.HardwareAddr.String(),
+			Flags:   inter.Flags,
+			Virtual: IsVirtualInterface(inter.Name),
+		}
+		if addresses, err := inter.Addrs(); err == nil {
+			ni.Addrs = interfaceAddrs(inter.Name, inter.Flags, addresses, FamilyAny)
+		}
+		res = append(res, ni)
+	}
+	return res, nil
+}
+
+// PhysicalInterfaces 列出已启用的物理网卡,不含回环、虚拟网卡和网桥
+func PhysicalInterfaces() ([]NetInterface, error) {
+	inters, err := NetInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	var res []NetInterface
+	for _, i := range inters {
+		if i.IsUp() && !i.IsLoopback() && !i.Virtual {
+			res = append(res, i)
+		}
+	}
+	return res, nil
+}
+
+// virtualInterfacePrefixes 常见虚拟网卡和网桥的名称前缀
+var virtualInterfacePrefixes = []string{
+	"docker", "br-", "veth", "virbr", "vnet", "vmnet", "vboxnet", "vEthernet",
+	"cni", "flannel", "cali", "weave", "kube-", "cilium", "lxc", "lxd", "podman",
+	"tun", "tap", "utun", "wg", "tailscale", "zt", "ifb", "dummy", "bridge",
+	"awdl", "llw", "anpi", "gif", "stf",
+}
+
+// IsVirtualInterface 判断网卡是否为虚拟网卡或网桥。
+// Linux上对应物理设备的网卡不是虚拟网卡,网桥和tun/tap是虚拟网卡,其余按名称前缀判断;
+// bond、VLAN以及容器内的eth0虽然没有物理设备,却是主机或容器的主网卡,不算虚拟网卡。
+// 其他平台只按名称前缀判断。
+func IsVirtualInterface(name string) bool {
+	if virtual, ok := sysVirtualInterface(name); ok {
+		return virtual
+	}
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sysfsVirtualInterface 根据sysfs(root通常为/sys/class/net)判断网卡是否为虚拟网卡,ok为false时由调用方按名称判断。
+// 物理网卡链接到具体的总线设备;/sys/devices/virtual下的网卡中只有网桥(bridge目录)和tun/tap(tun_flags文件)
+// 能从sysfs确定,veth、bond、VLAN都在该目录下且没有区分的标志。
+func sysfsVirtualInterface(root, name string) (virtual bool, ok bool) {
+	dir := filepath.Join(root, name)
+	target, err := os.Readlink(dir)
+	if err != nil {
+		return false, false
+	}
+	if !strings.Contains(filepath.ToSlash(target), "/devices/virtual/") {
+		return false, true
+	}
+	for _, f := range []string{"bridge", "tun_flags"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// PreferredIP 选择本机对外通信时使用的源地址。
+//
+// prefer为网卡名或CIDR,依次尝试,第一个有匹配地址的生效,都不匹配时返回ErrNoPreferredIP;
+// 不传prefer时,Linux上使用路由表中默认路由所在网卡的地址(优先与网关同网段的地址),
+// 其他平台或没有默认路由时,依次使用系统为外部地址选择的源地址和第一块物理网卡的地址。
+// 回环、链路本地和组播地址不会被选中。
+func PreferredIP(family IPFamily, prefer ...string) (net.IP, error) {
+	inters, err := NetInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	if len(prefer) > 0 {
+		for _, p := range prefer {
+			if ip := selectPreferredIP(inters, family, p); ip != nil {
+				return ip, nil
+			}
+		}
+		return nil, fmt.Errorf("%w matches %s", ErrNoPreferredIP, strings.Join(prefer, ","))
+	}
+	for _, route := range defaultRoutes(family) {
+		for _, i := range inters {
+			if i.Name == route.iface && i.IsUp() {
+				if ip := chooseSourceIP(i.Addrs, family, route.gateway); ip != nil {
+					return ip, nil
+				}
+			}
+		}
+	}
+	if ip := dialSourceIP(family); ip != nil {
+		return ip, nil
+	}
+	for _, i := range inters {
+		if i.IsUp() && !i.IsLoopback() && !i.Virtual {
+			if ip := chooseSourceIP(i.Addrs, family, nil); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+	return nil, ErrNoPreferredIP
+}
+
+// selectPreferredIP 在名称为prefer的网卡或属于prefer网段的地址中选择
+func selectPreferredIP(inters []NetInterface, family IPFamily, prefer string) net.IP {
+	if strings.Contains(prefer, "/") {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(prefer))
+		if err != nil {
+			return nil
+		}
+		for _, i := range inters {
+			if !i.IsUp() {
+				continue
+			}
+			for _, a := range i.Addrs {
+				if n.Contains(a.IP) && family.match(a.IP) && (a.Scope == ScopeGlobal || a.Scope == ScopePrivate) {
+					return a.IP
+				}
+			}
+		}
+		return nil
+	}
+	for _, i := range inters {
+		if i.Name == prefer && i.IsUp() {
+			return chooseSourceIP(i.Addrs, family, nil)
+		}
+	}
+	return nil
+}
+
+// chooseSourceIP 从网卡的全局地址和私有地址中选择源地址,优先选择与gateway同网段的地址。
+func chooseSourceIP(addrs []InterfaceAddr, family IPFamily, gateway net.IP) net.IP {
+	var candidates []InterfaceAddr
+	for _, a := range addrs {
+		if family.match(a.IP) && (a.Scope == ScopeGlobal || a.Scope == ScopePrivate) {
+			candidates = append(candidates, a)
+		}
+	}
+	if gateway != nil {
+		for _, a := range candidates {
+			bits := len(a.IP) * 8
+			if a.IP.To4() != nil {
+				bits = 32
+			}
+			n := net.IPNet{IP: a.IP, Mask: net.CIDRMask(a.PrefixLen, bits)}
+			if n.Contains(gateway) {
+				return a.IP
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		// 与内核一致,使用网卡的主地址
+		return candidates[0].IP
+	}
+	return nil
+}
+
+// dialSourceIP 通过UDP"连接"外部地址获取系统选择的源地址,不会发送任何数据
+func dialSourceIP(family IPFamily) net.IP {
+	// 文档地址只用于让系统查路由表
+	targets := [][2]string{{"udp4", "192.0.2.1:9"}, {"udp6", "[2001:db8::1]:9"}}
+	switch family {
+	case FamilyIPv4:
+		targets = targets[:1]
+	case FamilyIPv6:
+		targets = targets[1:]
+	}
+	for _, t := range targets {
+		conn, err := net.Dial(t[0], t[1])
+		if err != nil {
+			continue
+		}
+		ip := conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+		if s := IPScopeOf(ip); s == ScopeGlobal || s == ScopePrivate {
+			return ip
+		}
+	}
+	return nil
+}
+
+// route 路由表中的一条默认路由
+type route struct {
+	iface   string
+	gateway net.IP
+	metric  int
+}
+
+// 路由标志,见linux/route.h
+const (
+	rtfUp     = 0x0001
+	rtfReject = 0x0200
+)
+
+// parseRouteTable 解析/proc/net/route,按metric返回IPv4默认路由
+func parseRouteTable(r io.Reader) []route {
+	var res []route
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		var gateway net.IP
+		if gw, err := hex.DecodeString(fields[2]); err == nil && len(gw) == net.IPv4len {
+			// 小端序
+			gateway = net.IPv4(gw[3], gw[2], gw[1], gw[0]).To4()
+		}
+		res = append(res, route{iface: fields[0], gateway: gateway, metric: metric})
+	}
+	sortRoutes(res)
+	return res
+}
+
+// parseIPv6RouteTable 解析/proc/net/ipv6_route,按metric返回IPv6默认路由
+func parseIPv6RouteTable(r io.Reader) []route {
+	var res []route
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// dest prefix src src_prefix nexthop metric refcnt use flags iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[1] != "00" || strings.Trim(fields[0], "0") != "" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		metric, _ := strconv.ParseUint(fields[5], 16, 32)
+		var gateway net.IP
+		if gw, err := hex.DecodeString(fields[4]); err == nil && len(gw) == net.IPv6len && !net.IP(gw).IsUnspecified() {
+			gateway = gw
+		}
+		res = append(res, route{iface: fields[9], gateway: gateway, metric: int(metric)})
+	}
+	sortRoutes(res)
+	return res
+}
+
+func sortRoutes(routes []route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].metric < routes[j].metric
+	})
+}
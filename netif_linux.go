@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"os"
+)
+
+// sysVirtualInterface 根据/sys/class/net判断网卡是否为虚拟网卡,见sysfsVirtualInterface
+func sysVirtualInterface(name string) (virtual bool, ok bool) {
+	return sysfsVirtualInterface("/sys/class/net", name)
+}
+
+// defaultRoutes 从路由表读取默认路由,IPv4在前
+func defaultRoutes(family IPFamily) []route {
+	var res []route
+	if family != FamilyIPv6 {
+		if f, err := os.Open("/proc/net/route"); err == nil {
+			res = append(res, parseRouteTable(f)...)
+			f.Close()
+		}
+	}
+	if family != FamilyIPv4 {
+		if f, err := os.Open("/proc/net/ipv6_route"); err == nil {
+			res = append(res, parseIPv6RouteTable(f)...)
+			f.Close()
+		}
+	}
+	return res
+}
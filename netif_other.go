@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package utils
+
+// sysVirtualInterface 非Linux平台无法从系统读取,由调用方按名称判断
+func sysVirtualInterface(name string) (virtual bool, ok bool) {
+	return false, false
+}
+
+// defaultRoutes 非Linux平台不读取路由表,由PreferredIP使用系统选择的源地址
+func defaultRoutes(family IPFamily) []route {
+	return nil
+}
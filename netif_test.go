@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRouteTable = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+docker0	000011AC	00000000	0001	0	0	0	0000FFFF	0	0	0
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	010200C0	0003	0	0	100	00000000	0	0	0
+eth1	00000000	01010A0A	0002	0	0	50	00000000	0	0	0
+eth0	000200C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+`
+
+const testIPv6RouteTable = `fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+`
+
+func TestParseRouteTable(t *testing.T) {
+	routes := parseRouteTable(strings.NewReader(testRouteTable))
+	if len(routes) != 2 || routes[0].iface != "eth0" || routes[1].iface != "wlan0" {
+		t.Fatalf("parseRouteTable:\n Expect => [eth0 wlan0]\n Got => %+v\n", routes)
+	}
+	if !routes[0].gateway.Equal(net.ParseIP("192.0.2.1")) || routes[0].metric != 100 {
+		t.Errorf("parseRouteTable:\n Expect => gateway 192.0.2.1 metric 100\n Got => %v %d\n", routes[0].gateway, routes[0].metric)
+	}
+	routes6 := parseIPv6RouteTable(strings.NewReader(testIPv6RouteTable))
+	if len(routes6) != 1 || routes6[0].iface != "eth0" || !routes6[0].gateway.Equal(net.ParseIP("fe80::1")) || routes6[0].metric != 0x400 {
+		t.Errorf("parseIPv6RouteTable:\n Expect => eth0 via fe80::1\n Got => %+v\n", routes6)
+	}
+}
+
+func testNetInterfaces() []NetInterface {
+	up := net.FlagUp | net.FlagBroadcast
+	addr := func(name, cidr string) InterfaceAddr {
+		ip, n, _ := net.ParseCIDR(cidr)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		ones, _ := n.Mask.Size()
+		return InterfaceAddr{Interface: name, IP: ip, PrefixLen: ones, Flags: up, Scope: IPScopeOf(ip)}
+	}
+	return []NetInterface{
+		{Name: "lo", Flags: net.FlagUp | net.FlagLoopback, Addrs: []InterfaceAddr{addr("lo", "127.0.0.1/8")}},
+		{Name: "docker0", Flags: up, Virtual: true, Addrs: []InterfaceAddr{addr("docker0", "172.17.0.1/16")}},
+		{Name: "eth0", Flags: up, Addrs: []InterfaceAddr{
+			addr("eth0", "fe80::1/64"),
+			addr("eth0", "10.0.0.5/24"),
+			addr("eth0", "192.0.2.10/24"),
+			addr("eth0", "2001:db8::10/64"),
+		}},
+		{Name: "eth1", Flags: net.FlagBroadcast, Addrs: []InterfaceAddr{addr("eth1", "10.10.1.2/24")}},
+	}
+}
+
+func TestSelectPreferredIP(t *testing.T) {
+	inters := testNetInterfaces()
+	cases := []struct {
+		family   IPFamily
+		prefer   string
+		expected string
+	}{
+		{FamilyIPv4, "eth0", "10.0.0.5"},
+		{FamilyIPv6, "eth0", "2001:db8::10"},
+		{FamilyIPv4, "docker0", "172.17.0.1"},
+		{FamilyIPv4, "192.0.2.0/24", "192.0.2.10"},
+		{FamilyAny, "172.16.0.0/12", "172.17.0.1"},
+		{FamilyIPv4, "eth1", ""}, // 未启用
+		{FamilyIPv4, "lo", ""},   // 回环地址不会被选中
+		{FamilyIPv4, "wlan0", ""},
+		{FamilyIPv6, "10.0.0.0/8", ""},
+		// 按网段选择时同样跳过回环和链路本地地址
+		{FamilyIPv4, "127.0.0.0/8", ""},
+		{FamilyIPv4, "0.0.0.0/0", "172.17.0.1"},
+		{FamilyIPv6, "fe80::/10", ""},
+	}
+	for _, c := range cases {
+		got := selectPreferredIP(inters, c.family, c.prefer)
+		if (c.expected == "" && got != nil) || (c.expected != "" && !got.Equal(net.ParseIP(c.expected))) {
+			t.Errorf("selectPreferredIP(%s):\n Expect => %s\n Got => %v\n", c.prefer, c.expected, got)
+		}
+	}
+	// 与网关同网段的地址优先
+	if got := chooseSourceIP(inters[2].Addrs, FamilyIPv4, net.ParseIP("192.0.2.1")); !got.Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("chooseSourceIP:\n Expect => 192.0.2.10\n Got => %v\n", got)
+	}
+}
+
+func TestIsVirtualInterface(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"docker0":         true,
+		"veth1a2b3c":      true,
+		"br-0123456789ab": true,
+		"virbr0":          true,
+		"tun0":            true,
+		"enp0s31f6-test":  false,
+		"wlp2s0-test":     false,
+		"bond9-test":      false,
+		"eth9.100-test":   false,
+	} {
+		if got := IsVirtualInterface(name); got != expected {
+			t.Errorf("IsVirtualInterface(%s):\n Expect => %v\n Got => %v\n", name, expected, got)
+		}
+	}
+}
+
+func TestSysfsVirtualInterface(t *testing.T) {
+	root := t.TempDir()
+	class := filepath.Join(root, "class", "net")
+	link := func(name, device string, files ...string) {
+		dir := filepath.Join(root, "devices", device, "net", name)
+		for _, f := range files {
+			if err := os.MkdirAll(filepath.Join(dir, f), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		os.MkdirAll(dir, 0755)
+		os.MkdirAll(class, 0755)
+		if err := os.Symlink(filepath.Join("..", "..", "devices", device, "net", name), filepath.Join(class, name)); err != nil {
+			t.Skip(err)
+		}
+	}
+	link("enp1s0", "pci0000:00/0000:00:1c.0/0000:01:00.0")
+	link("docker0", "virtual", "bridge")
+	link("tap0", "virtual", "tun_flags")
+	// bond、VLAN和容器内的eth0(veth)同样在devices/virtual下,交给名称判断
+	link("bond0", "virtual", "bonding")
+	link("bond0.100", "virtual")
+	link("eth0", "virtual")
+	cases := []struct {
+		name        string
+		virtual, ok bool
+	}{
+		{"enp1s0", false, true},
+		{"docker0", true, true},
+		{"tap0", true, true},
+		{"bond0", false, false},
+		{"bond0.100", false, false},
+		{"eth0", false, false},
+		{"missing", false, false},
+	}
+	for _, c := range cases {
+		if virtual, ok := sysfsVirtualInterface(class, c.name); virtual != c.virtual || ok != c.ok {
+			t.Errorf("sysfsVirtualInterface(%s):\n Expect => %v %v\n Got => %v %v\n", c.name, c.virtual, c.ok, virtual, ok)
+		}
+	}
+}
+
+func TestPreferredIP(t *testing.T) {
+	inters, err := NetInterfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, i := range inters {
+		for _, a := range i.Addrs {
+			if a.Interface != i.Name {
+				t.Errorf("NetInterfaces:\n Expect => %s\n Got => %v\n", i.Name, a)
+			}
+		}
+	}
+	ip, err := PreferredIP(FamilyIPv4)
+	if errors.Is(err, ErrNoPreferredIP) {
+		t.Skip(err)
+	}
+	if err != nil || ip.To4() == nil || ip.IsLoopback() {
+		t.Errorf("PreferredIP:\n Expect => IPv4 address\n Got => %v %v\n", ip, err)
+	}
+	if got := InternalIP(); got != ip.String() {
+		t.Errorf("InternalIP:\n Expect => %s\n Got => %s\n", ip, got)
+	}
+	if _, err := PreferredIP(FamilyIPv4, "no-such-interface0"); !errors.Is(err, ErrNoPreferredIP) {
+		t.Errorf("PreferredIP(no-such-interface0):\n Expect => %v\n Got => %v\n", ErrNoPreferredIP, err)
+	}
+}
@@ -0,0 +1,336 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrOTPInvalid 一次性密码不正确或已过期
+	ErrOTPInvalid = errors.New("otp: invalid code")
+	// ErrOTPReplay 一次性密码已经使用过
+	ErrOTPReplay = errors.New("otp: code already used")
+)
+
+// otpBase32 认证器应用使用的无填充base32
+var otpBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// OTPReplayGuard 防止同一个一次性密码被再次使用(RFC 6238 5.2),
+// 密码校验通过后调用UseOTP,step为TOTP的时间步或HOTP的计数器,已经使用过时返回false。
+// 多实例部署时应基于Redis或数据库实现,保证同一账号的检查和记录是原子的。
+type OTPReplayGuard interface {
+	UseOTP(account string, step uint64) (bool, error)
+}
+
+// OTPReplayGuardFunc 函数形式的OTPReplayGuard
+type OTPReplayGuardFunc func(account string, step uint64) (bool, error)
+
+func (f OTPReplayGuardFunc) UseOTP(account string, step uint64) (bool, error) {
+	return f(account, step)
+}
+
+// MemoryOTPReplayGuard 进程内的OTPReplayGuard,记录每个账号最后使用的step,
+// 不大于该值的step都被拒绝,因此同一时间窗口内的旧密码也不能再使用。
+type MemoryOTPReplayGuard struct {
+	mu   sync.Mutex
+	last map[string]uint64
+}
+
+// NewMemoryOTPReplayGuard 创建进程内的OTPReplayGuard,仅适用于单实例部署
+func NewMemoryOTPReplayGuard() *MemoryOTPReplayGuard {
+	return &MemoryOTPReplayGuard{last: make(map[string]uint64)}
+}
+
+func (g *MemoryOTPReplayGuard) UseOTP(account string, step uint64) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if last, ok := g.last[account]; ok && step <= last {
+		return false, nil
+	}
+	g.last[account] = step
+	return true, nil
+}
+
+// OTP HOTP(RFC 4226)和TOTP(RFC 6238)一次性密码,零值字段使用认证器应用通用的默认值。
+// TOTP的时间取自Now(),测试时可以替换TimeFunc。
+type OTP struct {
+	// Secret 共享密钥,推荐使用GenerateOTPSecret生成的20字节密钥
+	Secret []byte
+	// Algorithm HashSHA1(默认)、HashSHA256或HashSHA512
+	Algorithm HashAlgorithm
+	// Digits 密码位数,6(默认)到8位
+	Digits int
+	// Period TOTP的时间步长,默认30秒
+	Period time.Duration
+	// Skew 校验时允许的偏移:TOTP前后各Skew个时间步,HOTP向后Skew个计数器
+	Skew int
+	// Issuer 和 Account 用于生成otpauth URI,Account同时作为防重放的键
+	Issuer  string
+	Account string
+	// Replay 非空时校验通过的密码会交给它检查是否已经使用过,此时Account不能为空,
+	// 否则所有用户共用同一个键,一个用户登录后其他用户的密码都会被判为重放
+	Replay OTPReplayGuard
+}
+
+// GenerateOTPSecret 生成20字节的随机密钥,返回认证器应用使用的无填充base32字符串
+func GenerateOTPSecret() (string, error) {
+	b, err := RandomBytes(20)
+	if err != nil {
+		return "", err
+	}
+	return otpBase32.EncodeToString(b), nil
+}
+
+// DecodeOTPSecret 解码base32密钥,忽略大小写、空格和填充
+func DecodeOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(strings.TrimRight(secret, "="), " ", "", -1))
+	b, err := otpBase32.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("otp: invalid base32 secret: %v", err)
+	}
+	return b, nil
+}
+
+// NewOTP 使用base32密钥创建OTP,允许前后1个时间步的偏差
+func NewOTP(secret string) (*OTP, error) {
+	b, err := DecodeOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &OTP{Secret: b, Skew: 1}, nil
+}
+
+func (o *OTP) algorithm() HashAlgorithm {
+	if o.Algorithm == 0 {
+		return HashSHA1
+	}
+	return o.Algorithm
+}
+
+func (o *OTP) digits() int {
+	if o.Digits == 0 {
+		return 6
+	}
+	return o.Digits
+}
+
+func (o *OTP) period() time.Duration {
+	if o.Period == 0 {
+		return 30 * time.Second
+	}
+	return o.Period
+}
+
+// check 检查参数,避免生成认证器应用无法识别的密码
+func (o *OTP) check() error {
+	if len(o.Secret) == 0 {
+		return errors.New("otp: empty secret")
+	}
+	switch o.algorithm() {
+	case HashSHA1, HashSHA256, HashSHA512:
+	default:
+		return fmt.Errorf("otp: unsupported algorithm %s", o.algorithm())
+	}
+	if d := o.digits(); d < 6 || d > 8 {
+		return fmt.Errorf("otp: digits must be 6 to 8, got %d", d)
+	}
+	if p := o.period(); p < time.Second || p%time.Second != 0 {
+		return fmt.Errorf("otp: period must be a whole number of seconds, got %s", p)
+	}
+	if o.Skew < 0 {
+		return fmt.Errorf("otp: negative skew %d", o.Skew)
+	}
+	if o.Replay != nil && o.Account == "" {
+		return errors.New("otp: replay guard requires an account")
+	}
+	return nil
+}
+
+// code 按RFC 4226第5.3节计算计数器对应的密码
+func (o *OTP) code(counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := HMAC(o.algorithm(), o.Secret, msg[:])
+	offset := mac[len(mac)-1] & 0x0f
+	value := binary.BigEndian.Uint32(mac[offset:]) & 0x7fffffff
+	digits := o.digits()
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	s := strconv.FormatUint(uint64(value%mod), 10)
+	return strings.Repeat("0", digits-len(s)) + s
+}
+
+// HOTP 计算计数器counter对应的密码
+func (o *OTP) HOTP(counter uint64) (string, error) {
+	if err := o.check(); err != nil {
+		return "", err
+	}
+	return o.code(counter), nil
+}
+
+// TOTP 计算当前时间的密码
+func (o *OTP) TOTP() (string, error) {
+	return o.TOTPAt(Now())
+}
+
+// TOTPAt 计算时间t的密码
+func (o *OTP) TOTPAt(t time.Time) (string, error) {
+	if err := o.check(); err != nil {
+		return "", err
+	}
+	return o.code(o.step(t)), nil
+}
+
+// step 时间t所在的时间步,从unix时间0开始计算
+func (o *OTP) step(t time.Time) uint64 {
+	sec := t.Unix()
+	if sec < 0 {
+		return 0
+	}
+	return uint64(sec) / uint64(o.period()/time.Second)
+}
+
+// match 以常量时间比较密码
+func (o *OTP) match(code string, counter uint64) bool {
+	return subtle.ConstantTimeCompare([]byte(code), []byte(o.code(counter))) == 1
+}
+
+// use 交给Replay检查是否已经使用过
+func (o *OTP) use(step uint64) error {
+	if o.Replay == nil {
+		return nil
+	}
+	ok, err := o.Replay.UseOTP(o.Account, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrOTPReplay
+	}
+	return nil
+}
+
+// VerifyTOTP 校验当前时间的密码,允许前后Skew个时间步;
+// 密码错误返回ErrOTPInvalid,已经使用过返回ErrOTPReplay。
+func (o *OTP) VerifyTOTP(code string) error {
+	if err := o.check(); err != nil {
+		return err
+	}
+	now := o.step(Now())
+	for i := -o.Skew; i <= o.Skew; i++ {
+		if i < 0 && uint64(-i) > now {
+			continue
+		}
+		step := now + uint64(i)
+		if o.match(code, step) {
+			return o.use(step)
+		}
+	}
+	return ErrOTPInvalid
+}
+
+// VerifyHOTP 从counter开始向后查找Skew个计数器校验密码,成功时返回下一次应使用的计数器,调用方需要保存它。
+func (o *OTP) VerifyHOTP(code string, counter uint64) (uint64, error) {
+	if err := o.check(); err != nil {
+		return counter, err
+	}
+	for i := 0; i <= o.Skew; i++ {
+		if c := counter + uint64(i); o.match(code, c) {
+			if err := o.use(c); err != nil {
+				return counter, err
+			}
+			return c + 1, nil
+		}
+	}
+	return counter, ErrOTPInvalid
+}
+
+// URI 生成TOTP的otpauth URI,通常显示为二维码供认证器应用扫描
+func (o *OTP) URI() string {
+	return o.uri("totp", 0)
+}
+
+// HOTPURI 生成HOTP的otpauth URI,counter为初始计数器
+func (o *OTP) HOTPURI(counter uint64) string {
+	return o.uri("hotp", counter)
+}
+
+func (o *OTP) uri(typ string, counter uint64) string {
+	label := url.PathEscape(o.Account)
+	q := url.Values{}
+	q.Set("secret", otpBase32.EncodeToString(o.Secret))
+	if o.Issuer != "" {
+		label = url.PathEscape(o.Issuer) + ":" + label
+		q.Set("issuer", o.Issuer)
+	}
+	q.Set("algorithm", strings.ToUpper(strings.Replace(o.algorithm().String(), "-", "", -1)))
+	q.Set("digits", strconv.Itoa(o.digits()))
+	if typ == "totp" {
+		q.Set("period", strconv.Itoa(int(o.period()/time.Second)))
+	} else {
+		q.Set("counter", strconv.FormatUint(counter, 10))
+	}
+	return "otpauth://" + typ + "/" + label + "?" + strings.Replace(q.Encode(), "+", "%20", -1)
+}
+
+// ParseOTPURI 解析otpauth URI,返回OTP、类型(totp或hotp)和HOTP的初始计数器
+func ParseOTPURI(uri string) (*OTP, string, uint64, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if u.Scheme != "otpauth" || (u.Host != "totp" && u.Host != "hotp") {
+		return nil, "", 0, fmt.Errorf("otp: not an otpauth uri")
+	}
+	q := u.Query()
+	secret, err := DecodeOTPSecret(q.Get("secret"))
+	if err != nil {
+		return nil, "", 0, err
+	}
+	o := &OTP{Secret: secret, Skew: 1, Issuer: q.Get("issuer")}
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.IndexByte(label, ':'); i >= 0 {
+		if o.Issuer == "" {
+			o.Issuer = strings.TrimSpace(label[:i])
+		}
+		label = label[i+1:]
+	}
+	o.Account = strings.TrimSpace(label)
+	if s := q.Get("algorithm"); s != "" {
+		if o.Algorithm, err = ParseHashAlgorithm(s); err != nil {
+			return nil, "", 0, err
+		}
+	}
+	if s := q.Get("digits"); s != "" {
+		if o.Digits, err = strconv.Atoi(s); err != nil {
+			return nil, "", 0, fmt.Errorf("otp: invalid digits %q", s)
+		}
+	}
+	if s := q.Get("period"); s != "" {
+		p, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("otp: invalid period %q", s)
+		}
+		o.Period = time.Duration(p) * time.Second
+	}
+	var counter uint64
+	if s := q.Get("counter"); s != "" {
+		if counter, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, "", 0, fmt.Errorf("otp: invalid counter %q", s)
+		}
+	}
+	if err = o.check(); err != nil {
+		return nil, "", 0, err
+	}
+	return o, u.Host, counter, nil
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 附录D
+	o := &OTP{Secret: []byte("12345678901234567890")}
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for i, code := range expected {
+		if got, err := o.HOTP(uint64(i)); err != nil || got != code {
+			t.Errorf("HOTP(%d):\n Expect => %s\n Got => %s %v\n", i, code, got, err)
+		}
+	}
+
+	o.Skew = 2
+	next, err := o.VerifyHOTP("359152", 0)
+	if err != nil || next != 3 {
+		t.Errorf("VerifyHOTP:\n Expect => 3\n Got => %d %v\n", next, err)
+	}
+	if _, err := o.VerifyHOTP("969429", 0); !errors.Is(err, ErrOTPInvalid) {
+		t.Errorf("VerifyHOTP(out of window):\n Expect => %v\n Got => %v\n", ErrOTPInvalid, err)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 附录B
+	secrets := map[HashAlgorithm]string{
+		HashSHA1:   "12345678901234567890",
+		HashSHA256: "12345678901234567890123456789012",
+		HashSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	cases := []struct {
+		time int64
+		alg  HashAlgorithm
+		code string
+	}{
+		{59, HashSHA1, "94287082"},
+		{59, HashSHA256, "46119246"},
+		{59, HashSHA512, "90693936"},
+		{1111111109, HashSHA1, "07081804"},
+		{1111111111, HashSHA256, "67062674"},
+		{1234567890, HashSHA512, "93441116"},
+		{2000000000, HashSHA1, "69279037"},
+		{20000000000, HashSHA256, "77737706"},
+		{20000000000, HashSHA512, "47863826"},
+	}
+	for _, c := range cases {
+		o := &OTP{Secret: []byte(secrets[c.alg]), Algorithm: c.alg, Digits: 8}
+		if got, err := o.TOTPAt(time.Unix(c.time, 0)); err != nil || got != c.code {
+			t.Errorf("TOTPAt(%d, %s):\n Expect => %s\n Got => %s %v\n", c.time, c.alg, c.code, got, err)
+		}
+		restore := freezeTime(c.time)
+		if got, _ := o.TOTP(); got != c.code {
+			t.Errorf("TOTP(%d, %s):\n Expect => %s\n Got => %s\n", c.time, c.alg, c.code, got)
+		}
+		restore()
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	defer freezeTime(1111111111)()
+	o := &OTP{Secret: []byte("12345678901234567890"), Skew: 1, Account: "alice"}
+	prev, _ := o.TOTPAt(time.Unix(1111111111-30, 0))
+	cur, _ := o.TOTP()
+	late, _ := o.TOTPAt(time.Unix(1111111111-60, 0))
+	if err := o.VerifyTOTP(prev); err != nil {
+		t.Errorf("VerifyTOTP(prev):\n Expect => nil\n Got => %v\n", err)
+	}
+	for _, code := range []string{late, "000000", cur[:5], ""} {
+		if err := o.VerifyTOTP(code); !errors.Is(err, ErrOTPInvalid) {
+			t.Errorf("VerifyTOTP(%q):\n Expect => %v\n Got => %v\n", code, ErrOTPInvalid, err)
+		}
+	}
+
+	// 防重放:同一个密码和更早的密码都不能再使用
+	o.Replay = NewMemoryOTPReplayGuard()
+	if err := o.VerifyTOTP(cur); err != nil {
+		t.Errorf("VerifyTOTP(cur):\n Expect => nil\n Got => %v\n", err)
+	}
+	for _, code := range []string{cur, prev} {
+		if err := o.VerifyTOTP(code); !errors.Is(err, ErrOTPReplay) {
+			t.Errorf("VerifyTOTP(replay):\n Expect => %v\n Got => %v\n", ErrOTPReplay, err)
+		}
+	}
+	other := *o
+	other.Account = "bob"
+	if err := other.VerifyTOTP(cur); err != nil {
+		t.Errorf("VerifyTOTP(other account):\n Expect => nil\n Got => %v\n", err)
+	}
+	// 没有Account时所有用户共用防重放的键,直接拒绝
+	anonymous := *o
+	anonymous.Account = ""
+	if err := anonymous.VerifyTOTP(cur); err == nil || errors.Is(err, ErrOTPInvalid) || errors.Is(err, ErrOTPReplay) {
+		t.Errorf("VerifyTOTP(no account):\n Expect => configuration error\n Got => %v\n", err)
+	}
+	if _, err := anonymous.VerifyHOTP(cur, 0); err == nil || errors.Is(err, ErrOTPInvalid) {
+		t.Errorf("VerifyHOTP(no account):\n Expect => configuration error\n Got => %v\n", err)
+	}
+	hookErr := errors.New("store unavailable")
+	o.Replay = OTPReplayGuardFunc(func(string, uint64) (bool, error) { return false, hookErr })
+	if err := o.VerifyTOTP(cur); err != hookErr {
+		t.Errorf("VerifyTOTP(hook error):\n Expect => %v\n Got => %v\n", hookErr, err)
+	}
+}
+
+func TestOTPURI(t *testing.T) {
+	secret, err := GenerateOTPSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("GenerateOTPSecret:\n Expect => 32 chars\n Got => %q %v\n", secret, err)
+	}
+	o, err := NewOTP(strings.ToLower(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Issuer, o.Account, o.Algorithm, o.Period = "ACME Co", "alice@example.com", HashSHA256, time.Minute
+	uri := o.URI()
+	expected := "otpauth://totp/ACME%20Co:alice@example.com?algorithm=SHA256&digits=6&issuer=ACME%20Co&period=60&secret=" + secret
+	if uri != expected {
+		t.Errorf("URI:\n Expect => %s\n Got => %s\n", expected, uri)
+	}
+	p, typ, _, err := ParseOTPURI(uri)
+	if err != nil || typ != "totp" || p.Issuer != o.Issuer || p.Account != o.Account || p.Algorithm != HashSHA256 || p.Period != time.Minute || string(p.Secret) != string(o.Secret) {
+		t.Errorf("ParseOTPURI:\n Expect => %+v\n Got => %+v %s %v\n", o, p, typ, err)
+	}
+
+	h := &OTP{Secret: []byte("12345678901234567890"), Account: "bob"}
+	if got := h.HOTPURI(7); got != "otpauth://hotp/bob?algorithm=SHA1&counter=7&digits=6&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("HOTPURI:\n Got => %s\n", got)
+	}
+	if _, typ, counter, err := ParseOTPURI(h.HOTPURI(7)); err != nil || typ != "hotp" || counter != 7 {
+		t.Errorf("ParseOTPURI(hotp):\n Expect => hotp 7\n Got => %s %d %v\n", typ, counter, err)
+	}
+
+	for _, s := range []string{"https://example.com", "otpauth://totp/x?secret=!!", "otpauth://totp/x?secret=GEZDGNBV&digits=9", "otpauth://totp/x?secret=GEZDGNBV&algorithm=MD5"} {
+		if _, _, _, err := ParseOTPURI(s); err == nil {
+			t.Errorf("ParseOTPURI(%q):\n Expect => error\n Got => nil\n", s)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希算法
+const (
+	PasswordArgon2id     = "argon2id"
+	PasswordBcrypt       = "bcrypt"
+	PasswordPBKDF2SHA256 = "pbkdf2-sha256"
+	PasswordPBKDF2SHA512 = "pbkdf2-sha512"
+	PasswordPBKDF2SHA1   = "pbkdf2-sha1"
+)
+
+var (
+	// ErrPasswordHashFormat 无法识别的密码哈希格式
+	ErrPasswordHashFormat = errors.New("password: unrecognized hash format")
+	// ErrPasswordParams PasswordHasher的参数无效或超过上限,生成的哈希将无法通过校验
+	ErrPasswordParams = errors.New("password: invalid hasher parameters")
+)
+
+// 参数上限,防止被篡改或损坏的哈希耗尽内存或CPU。
+// 校验时超过上限返回ErrPasswordHashFormat,生成时返回ErrPasswordParams,避免生成自己无法校验的哈希
+const (
+	maxArgon2Memory     = 1024 * 1024 // KiB,即1 GiB
+	maxArgon2Iterations = 64
+	maxPBKDF2Iterations = 10000000
+	maxBcryptCost       = 18
+	maxPasswordKeyLen   = 1024
+)
+
+// Argon2Params argon2id参数
+type Argon2Params struct {
+	Memory      uint32 // 内存,单位KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PBKDF2Params PBKDF2参数
+type PBKDF2Params struct {
+	Iterations int
+	SaltLength int
+	KeyLength  int
+}
+
+// PasswordHasher 生成和校验PHC格式的密码哈希,如
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+//	$pbkdf2-sha256$i=600000,l=32$<salt>$<hash>
+//	$2a$12$<salt+hash>(bcrypt自身的格式)
+//
+// salt和hash使用不带填充的标准base64编码。Verify可以校验任意支持的算法,
+// 新哈希只使用Algorithm指定的算法,参数低于当前配置的哈希需要重新生成,见NeedsRehash。
+//
+// 为零的参数使用NewPasswordHasher的默认值,零值的PasswordHasher等同于NewPasswordHasher。
+type PasswordHasher struct {
+	// Algorithm 生成新哈希使用的算法,默认为argon2id
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+	PBKDF2     PBKDF2Params
+}
+
+// OWASP推荐的默认参数
+var (
+	defaultArgon2Params = Argon2Params{Memory: 19456, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	defaultBcryptCost   = 12
+	defaultPBKDF2Params = PBKDF2Params{Iterations: 600000, SaltLength: 16, KeyLength: 32}
+)
+
+// NewPasswordHasher 使用OWASP推荐的参数创建哈希器:
+// argon2id m=19456 KiB,t=2,p=1;bcrypt cost 12;PBKDF2-SHA256 600000次。
+func NewPasswordHasher() *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:  PasswordArgon2id,
+		Argon2:     defaultArgon2Params,
+		BcryptCost: defaultBcryptCost,
+		PBKDF2:     defaultPBKDF2Params,
+	}
+}
+
+// defaultPasswordHasher HashPassword和VerifyPassword使用的哈希器
+var defaultPasswordHasher = NewPasswordHasher()
+
+// HashPassword 使用默认参数的argon2id生成密码哈希
+func HashPassword(password string) (string, error) {
+	return defaultPasswordHasher.Hash(password)
+}
+
+// VerifyPassword 使用默认参数校验密码,返回密码是否正确以及是否需要重新生成哈希
+func VerifyPassword(password, encoded string) (ok bool, rehash bool, err error) {
+	return defaultPasswordHasher.Verify(password, encoded)
+}
+
+// Hash 生成密码哈希,参数无效或超过上限时返回ErrPasswordParams
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	switch alg := h.algorithm(); alg {
+	case PasswordArgon2id:
+		p := h.argon2Params()
+		if p.Iterations > maxArgon2Iterations || p.Memory > maxArgon2Memory || p.Memory < 8*uint32(p.Parallelism) ||
+			p.KeyLength > maxPasswordKeyLen {
+			return "", fmt.Errorf("%w: argon2 m=%d,t=%d,p=%d,l=%d", ErrPasswordParams, p.Memory, p.Iterations, p.Parallelism, p.KeyLength)
+		}
+		salt, err := RandomBytes(int(p.SaltLength))
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			phcEncode(salt), phcEncode(key)), nil
+	case PasswordBcrypt:
+		cost := h.bcryptCost()
+		if cost < bcrypt.MinCost || cost > maxBcryptCost {
+			return "", fmt.Errorf("%w: bcrypt cost %d", ErrPasswordParams, cost)
+		}
+		b, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+		return string(b), err
+	case PasswordPBKDF2SHA256, PasswordPBKDF2SHA512, PasswordPBKDF2SHA1:
+		p := h.pbkdf2Params()
+		if p.Iterations < 0 || p.Iterations > maxPBKDF2Iterations || p.SaltLength < 0 ||
+			p.KeyLength < 0 || p.KeyLength > maxPasswordKeyLen {
+			return "", fmt.Errorf("%w: pbkdf2 i=%d,l=%d,salt=%d", ErrPasswordParams, p.Iterations, p.KeyLength, p.SaltLength)
+		}
+		salt, err := RandomBytes(p.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key := PBKDF2([]byte(password), salt, p.Iterations, p.KeyLength, pbkdf2Hash(alg))
+		return fmt.Sprintf("$%s$i=%d,l=%d$%s$%s", alg, p.Iterations, p.KeyLength, phcEncode(salt), phcEncode(key)), nil
+	default:
+		return "", fmt.Errorf("password: unknown algorithm %q", alg)
+	}
+}
+
+// Verify 以常量时间校验密码。密码不正确时ok为false且err为nil;
+// rehash表示密码正确但哈希使用的算法或参数与当前配置不同,应在登录成功后重新生成并保存。
+func (h *PasswordHasher) Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	p, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	switch p.alg {
+	case PasswordBcrypt:
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+	case PasswordArgon2id:
+		key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+		if subtle.ConstantTimeCompare(key, p.key) != 1 {
+			return false, false, nil
+		}
+	default:
+		key := PBKDF2([]byte(password), p.salt, int(p.iterations), len(p.key), pbkdf2Hash(p.alg))
+		if subtle.ConstantTimeCompare(key, p.key) != 1 {
+			return false, false, nil
+		}
+	}
+	return true, h.needsRehash(p), nil
+}
+
+// NeedsRehash 判断哈希的算法或参数是否与当前配置不同,无法识别的格式也需要重新生成
+func (h *PasswordHasher) NeedsRehash(encoded string) bool {
+	p, err := parsePasswordHash(encoded)
+	if err != nil {
+		return true
+	}
+	return h.needsRehash(p)
+}
+
+func (h *PasswordHasher) needsRehash(p *passwordHash) bool {
+	if p.alg != h.algorithm() {
+		return true
+	}
+	switch p.alg {
+	case PasswordArgon2id:
+		a := h.argon2Params()
+		return p.memory < a.Memory || p.iterations < a.Iterations || p.parallelism != a.Parallelism ||
+			uint32(len(p.salt)) < a.SaltLength || uint32(len(p.key)) < a.KeyLength
+	case PasswordBcrypt:
+		return int(p.iterations) < h.bcryptCost()
+	default:
+		b := h.pbkdf2Params()
+		return int(p.iterations) < b.Iterations || len(p.salt) < b.SaltLength || len(p.key) < b.KeyLength
+	}
+}
+
+func (h *PasswordHasher) algorithm() string {
+	if h.Algorithm == "" {
+		return PasswordArgon2id
+	}
+	return h.Algorithm
+}
+
+// argon2Params 为零的参数使用默认值
+func (h *PasswordHasher) argon2Params() Argon2Params {
+	p, d := h.Argon2, defaultArgon2Params
+	if p.Memory == 0 {
+		p.Memory = d.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = d.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = d.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = d.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = d.KeyLength
+	}
+	return p
+}
+
+func (h *PasswordHasher) bcryptCost() int {
+	if h.BcryptCost == 0 {
+		return defaultBcryptCost
+	}
+	return h.BcryptCost
+}
+
+// pbkdf2Params 为零的参数使用默认值
+func (h *PasswordHasher) pbkdf2Params() PBKDF2Params {
+	p, d := h.PBKDF2, defaultPBKDF2Params
+	if p.Iterations == 0 {
+		p.Iterations = d.Iterations
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = d.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = d.KeyLength
+	}
+	return p
+}
+
+// passwordHash 解析后的密码哈希;bcrypt只解析cost,存放在iterations中
+type passwordHash struct {
+	alg         string
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	keyLen      int // PBKDF2参数中的l
+	salt, key   []byte
+}
+
+func parsePasswordHash(encoded string) (*passwordHash, error) {
+	if strings.HasPrefix(encoded, "$2") {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPasswordHashFormat, err)
+		}
+		if cost > maxBcryptCost {
+			return nil, fmt.Errorf("%w: bcrypt cost %d exceeds %d", ErrPasswordHashFormat, cost, maxBcryptCost)
+		}
+		return &passwordHash{alg: PasswordBcrypt, iterations: uint32(cost)}, nil
+	}
+	// "$id$params$salt$hash",argon2另有"v=19"一段
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return nil, ErrPasswordHashFormat
+	}
+	p := &passwordHash{alg: parts[1]}
+	switch p.alg {
+	case PasswordArgon2id:
+		if len(parts) != 6 || parts[2] != "v="+strconv.Itoa(argon2.Version) {
+			return nil, fmt.Errorf("%w: unsupported argon2 version", ErrPasswordHashFormat)
+		}
+		params, err := phcParams(parts[3], "m", "t", "p")
+		if err != nil {
+			return nil, err
+		}
+		if params["p"] == 0 || params["p"] > 255 || params["t"] == 0 || params["t"] > maxArgon2Iterations ||
+			params["m"] < 8*params["p"] || params["m"] > maxArgon2Memory {
+			return nil, fmt.Errorf("%w: invalid argon2 parameters", ErrPasswordHashFormat)
+		}
+		p.memory, p.iterations, p.parallelism = uint32(params["m"]), uint32(params["t"]), uint8(params["p"])
+		parts = parts[1:]
+	case PasswordPBKDF2SHA256, PasswordPBKDF2SHA512, PasswordPBKDF2SHA1:
+		if len(parts) != 5 {
+			return nil, ErrPasswordHashFormat
+		}
+		params, err := phcParams(parts[2], "i", "l")
+		if err != nil {
+			return nil, err
+		}
+		if params["i"] == 0 || params["i"] > maxPBKDF2Iterations {
+			return nil, fmt.Errorf("%w: invalid pbkdf2 iterations", ErrPasswordHashFormat)
+		}
+		p.iterations = uint32(params["i"])
+		p.keyLen = int(params["l"])
+	default:
+		return nil, fmt.Errorf("%w: unknown algorithm %q", ErrPasswordHashFormat, p.alg)
+	}
+	var err error
+	if p.salt, err = phcDecode(parts[3]); err != nil {
+		return nil, err
+	}
+	if p.key, err = phcDecode(parts[4]); err != nil {
+		return nil, err
+	}
+	if len(p.key) == 0 || len(p.key) > maxPasswordKeyLen || p.keyLen != 0 && p.keyLen != len(p.key) {
+		return nil, fmt.Errorf("%w: invalid hash length", ErrPasswordHashFormat)
+	}
+	return p, nil
+}
+
+// phcParams 解析"k1=v1,k2=v2"形式的参数,keys中的参数都必须出现
+func phcParams(s string, keys ...string) (map[string]uint64, error) {
+	res := make(map[string]uint64, len(keys))
+	for _, kv := range strings.Split(s, ",") {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("%w: invalid parameter %q", ErrPasswordHashFormat, kv)
+		}
+		v, err := strconv.ParseUint(kv[i+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid parameter %q", ErrPasswordHashFormat, kv)
+		}
+		res[kv[:i]] = v
+	}
+	for _, k := range keys {
+		if _, ok := res[k]; !ok {
+			return nil, fmt.Errorf("%w: missing parameter %q", ErrPasswordHashFormat, k)
+		}
+	}
+	return res, nil
+}
+
+func phcEncode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func phcDecode(s string) ([]byte, error) {
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasswordHashFormat, err)
+	}
+	return b, nil
+}
+
+func pbkdf2Hash(alg string) func() hash.Hash {
+	switch alg {
+	case PasswordPBKDF2SHA512:
+		return sha512.New
+	case PasswordPBKDF2SHA1:
+		return sha1.New
+	}
+	return sha256.New
+}
+
+// MigrateEncryptedPassword 将EncryptedPassword生成的密文和盐值转换为PHC格式,
+// 转换后可以直接用Verify校验,并在用户下次登录成功时按NeedsRehash升级为新算法。
+func MigrateEncryptedPassword(encryptedPassword, salt string) (string, error) {
+	key, err := hex.DecodeString(encryptedPassword)
+	if err != nil || len(key) == 0 {
+		return "", fmt.Errorf("%w: invalid legacy password hash", ErrPasswordHashFormat)
+	}
+	// EncryptedPassword把十六进制盐值字符串本身作为盐
+	return fmt.Sprintf("$%s$i=%d,l=%d$%s$%s", PasswordPBKDF2SHA256, legacyPasswordIterations, len(key),
+		phcEncode([]byte(salt)), phcEncode(key)), nil
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fastPasswordHasher 使用较小的参数以加快测试
+func fastPasswordHasher(alg string) *PasswordHasher {
+	h := NewPasswordHasher()
+	h.Algorithm = alg
+	h.Argon2.Memory = 64
+	h.BcryptCost = 4
+	h.PBKDF2.Iterations = 1000
+	return h
+}
+
+func TestPasswordVectors(t *testing.T) {
+	cases := []struct {
+		password string
+		encoded  string
+	}{
+		// argon2参考实现的测试向量
+		{"password", "$argon2id$v=19$m=64,t=2,p=1$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3"},
+		{"allmine", "$2a$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga"},
+		// 与Python hashlib.pbkdf2_hmac的结果一致
+		{"password", "$pbkdf2-sha256$i=1000,l=32$c2FsdHNhbHRzYWx0c2FsdA$8nX7hwFEzIB8aPajJTYK8weHQc5Ngz0pFVAKvSu4jQA"},
+		{"password", "$pbkdf2-sha512$i=1000,l=64$c2FsdHNhbHRzYWx0c2FsdA$715rqIr5dXOVPpBhqqsugl037zT5bWJTWYmZtIcK8hBnisKpwfY7kokvwjDrNHqHhF50Pb7MD6HvkJwiDQw4ww"},
+	}
+	h := NewPasswordHasher()
+	for _, c := range cases {
+		ok, rehash, err := h.Verify(c.password, c.encoded)
+		if err != nil || !ok || !rehash {
+			t.Errorf("Verify(%s):\n Expect => true true <nil>\n Got => %v %v %v\n", c.encoded, ok, rehash, err)
+		}
+		if ok, _, err := h.Verify(c.password+"x", c.encoded); err != nil || ok {
+			t.Errorf("Verify(wrong, %s):\n Expect => false <nil>\n Got => %v %v\n", c.encoded, ok, err)
+		}
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	for _, alg := range []string{PasswordArgon2id, PasswordBcrypt, PasswordPBKDF2SHA256, PasswordPBKDF2SHA512, PasswordPBKDF2SHA1} {
+		h := fastPasswordHasher(alg)
+		e1, err := h.Hash("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		e2, _ := h.Hash("secret")
+		if e1 == e2 {
+			t.Errorf("Hash(%s):\n Expect => random salt\n Got => %s\n", alg, e1)
+		}
+		if alg != PasswordBcrypt && !strings.HasPrefix(e1, "$"+alg+"$") {
+			t.Errorf("Hash(%s):\n Expect => PHC string\n Got => %s\n", alg, e1)
+		}
+		if ok, rehash, err := h.Verify("secret", e1); !ok || rehash || err != nil {
+			t.Errorf("Verify(%s):\n Expect => true false <nil>\n Got => %v %v %v\n", e1, ok, rehash, err)
+		}
+		if ok, _, err := h.Verify("Secret", e1); ok || err != nil {
+			t.Errorf("Verify(%s, wrong):\n Expect => false <nil>\n Got => %v %v\n", e1, ok, err)
+		}
+		if h.NeedsRehash(e1) {
+			t.Errorf("NeedsRehash(%s):\n Expect => false\n Got => true\n", e1)
+		}
+		// 提高参数或更换算法后需要重新生成
+		stronger := fastPasswordHasher(alg)
+		stronger.Argon2.Iterations++
+		stronger.BcryptCost++
+		stronger.PBKDF2.Iterations++
+		if !stronger.NeedsRehash(e1) {
+			t.Errorf("NeedsRehash(%s, stronger):\n Expect => true\n Got => false\n", e1)
+		}
+		other := PasswordBcrypt
+		if alg == PasswordBcrypt {
+			other = PasswordArgon2id
+		}
+		if ok, rehash, _ := fastPasswordHasher(other).Verify("secret", e1); !ok || !rehash {
+			t.Errorf("Verify(%s, other algorithm):\n Expect => true true\n Got => %v %v\n", e1, ok, rehash)
+		}
+	}
+	if _, err := fastPasswordHasher("md5").Hash("secret"); err == nil {
+		t.Errorf("Hash(md5):\n Expect => error\n Got => nil\n")
+	}
+
+	// 零值使用默认参数,不会panic
+	var zero PasswordHasher
+	e, err := zero.Hash("secret")
+	if err != nil || !strings.HasPrefix(e, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("PasswordHasher{}.Hash:\n Expect => default argon2id\n Got => %s %v\n", e, err)
+	}
+	if ok, rehash, err := zero.Verify("secret", e); !ok || rehash || err != nil {
+		t.Errorf("PasswordHasher{}.Verify:\n Expect => true false <nil>\n Got => %v %v %v\n", ok, rehash, err)
+	}
+	for _, alg := range []string{PasswordBcrypt, PasswordPBKDF2SHA256} {
+		partial := &PasswordHasher{Algorithm: alg, BcryptCost: 4, PBKDF2: PBKDF2Params{Iterations: 1000}}
+		if e, err := partial.Hash("secret"); err != nil || partial.NeedsRehash(e) {
+			t.Errorf("PasswordHasher{%s}.Hash:\n Expect => default parameters\n Got => %s %v\n", alg, e, err)
+		}
+	}
+
+	// 超过校验上限的参数会生成自己无法校验的哈希
+	for _, h := range []*PasswordHasher{
+		{Argon2: Argon2Params{Iterations: maxArgon2Iterations + 1}},
+		{Argon2: Argon2Params{Memory: maxArgon2Memory + 1}},
+		{Argon2: Argon2Params{Memory: 8, Parallelism: 4}},
+		{Argon2: Argon2Params{KeyLength: maxPasswordKeyLen + 1}},
+		{Algorithm: PasswordBcrypt, BcryptCost: maxBcryptCost + 2},
+		{Algorithm: PasswordBcrypt, BcryptCost: -1},
+		{Algorithm: PasswordPBKDF2SHA256, PBKDF2: PBKDF2Params{Iterations: maxPBKDF2Iterations + 1}},
+		{Algorithm: PasswordPBKDF2SHA256, PBKDF2: PBKDF2Params{Iterations: 1000, SaltLength: -1}},
+		{Algorithm: PasswordPBKDF2SHA256, PBKDF2: PBKDF2Params{Iterations: 1000, KeyLength: maxPasswordKeyLen + 1}},
+	} {
+		if e, err := h.Hash("secret"); !errors.Is(err, ErrPasswordParams) {
+			t.Errorf("Hash(%+v):\n Expect => %v\n Got => %s %v\n", *h, ErrPasswordParams, e, err)
+		}
+	}
+
+	e, err = HashPassword("secret")
+	if err != nil || !strings.HasPrefix(e, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("HashPassword:\n Expect => default argon2id\n Got => %s %v\n", e, err)
+	}
+	if ok, rehash, err := VerifyPassword("secret", e); !ok || rehash || err != nil {
+		t.Errorf("VerifyPassword:\n Expect => true false <nil>\n Got => %v %v %v\n", ok, rehash, err)
+	}
+}
+
+func TestPasswordMalformed(t *testing.T) {
+	h := NewPasswordHasher()
+	for _, encoded := range []string{
+		"",
+		"secret",
+		"$md5$abc$def",
+		"$argon2id$v=16$m=64,t=2,p=1$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3",
+		"$argon2id$v=19$m=64,t=2$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3",
+		"$argon2id$v=19$m=64,t=2,p=0$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3",
+		"$argon2id$v=19$m=64,t=2,p=1$c29tZXNhbHQ$",
+		"$argon2id$v=19$m=64,t=2,p=1$!!$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3",
+		"$pbkdf2-sha256$i=0,l=32$c2FsdHNhbHRzYWx0c2FsdA$8nX7hwFEzIB8aPajJTYK8weHQc5Ngz0pFVAKvSu4jQA",
+		"$pbkdf2-sha256$i=1000,l=16$c2FsdHNhbHRzYWx0c2FsdA$8nX7hwFEzIB8aPajJTYK8weHQc5Ngz0pFVAKvSu4jQA",
+		"$pbkdf2-sha256$i=1000$c2FsdHNhbHRzYWx0c2FsdA$8nX7hwFEzIB8aPajJTYK8weHQc5Ngz0pFVAKvSu4jQA",
+		"$2a$99$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga",
+		// 超过上限的参数会耗尽内存或CPU,不进行计算
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3",
+		"$argon2id$v=19$m=64,t=4294967295,p=1$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3",
+		"$pbkdf2-sha256$i=4294967295,l=32$c2FsdHNhbHRzYWx0c2FsdA$8nX7hwFEzIB8aPajJTYK8weHQc5Ngz0pFVAKvSu4jQA",
+		"$2a$31$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga",
+	} {
+		if ok, _, err := h.Verify("password", encoded); ok || !errors.Is(err, ErrPasswordHashFormat) {
+			t.Errorf("Verify(%q):\n Expect => %v\n Got => %v %v\n", encoded, ErrPasswordHashFormat, ok, err)
+		}
+		if !h.NeedsRehash(encoded) {
+			t.Errorf("NeedsRehash(%q):\n Expect => true\n Got => false\n", encoded)
+		}
+	}
+}
+
+func TestMigrateEncryptedPassword(t *testing.T) {
+	salt := "4322f9a718b6b117c842bf6d52ca4eda"
+	legacy := "d31016aa4230cc95bf653b5266ca1dff5a35ebce09a1dd659c704fcb162ef0432137a8ed0d225e8bf67c49fbba93b6e34a6c"
+	encoded, err := MigrateEncryptedPassword(legacy, salt)
+	if err != nil || !strings.HasPrefix(encoded, "$pbkdf2-sha256$i=10000,l=50$") {
+		t.Fatalf("MigrateEncryptedPassword:\n Expect => pbkdf2-sha256\n Got => %s %v\n", encoded, err)
+	}
+	if ok, rehash, err := VerifyPassword("12345678", encoded); !ok || !rehash || err != nil {
+		t.Errorf("VerifyPassword(migrated):\n Expect => true true <nil>\n Got => %v %v %v\n", ok, rehash, err)
+	}
+	if ok, _, _ := VerifyPassword("87654321", encoded); ok {
+		t.Errorf("VerifyPassword(migrated, wrong):\n Expect => false\n Got => true\n")
+	}
+	if _, err := MigrateEncryptedPassword("xyz", salt); !errors.Is(err, ErrPasswordHashFormat) {
+		t.Errorf("MigrateEncryptedPassword(xyz):\n Expect => %v\n Got => %v\n", ErrPasswordHashFormat, err)
+	}
+}
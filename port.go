@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ListenAddr 监听地址,Host为空表示所有地址
+type ListenAddr struct {
+	Host string
+	Port int
+}
+
+// String 返回host:port,IPv6地址带方括号
+func (a ListenAddr) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// ParseListenAddr 解析监听地址,支持"host:port"、":port"、"port"、"host"、"[::1]:port"、"[::1]"和不带方括号的IPv6地址,
+// 端口也可以是服务名(如"http")。只有端口或地址为空时使用defaultHost,没有端口时使用defaultPort;
+// 与net.Listen一致,":port"和"*:port"表示所有地址。
+func ParseListenAddr(addr string, defaultHost string, defaultPort int) (ListenAddr, error) {
+	res := ListenAddr{Host: defaultHost, Port: defaultPort}
+	addr = strings.TrimSpace(addr)
+	host, port, hasHost := "", "", true
+	switch {
+	case addr == "":
+		hasHost = false
+	case strings.HasPrefix(addr, "["):
+		end := strings.IndexByte(addr, ']')
+		if end < 0 {
+			return res, fmt.Errorf("invalid listen address %q: missing ']'", addr)
+		}
+		host = addr[1:end]
+		if rest := addr[end+1:]; rest != "" {
+			if rest[0] != ':' {
+				return res, fmt.Errorf("invalid listen address %q", addr)
+			}
+			port = rest[1:]
+		}
+		if parseZonedIP(host) == nil {
+			return res, fmt.Errorf("invalid listen address %q: %q is not an IP address", addr, host)
+		}
+	case strings.Count(addr, ":") > 1:
+		// 不带方括号的IPv6地址,不含端口
+		if parseZonedIP(addr) == nil {
+			return res, fmt.Errorf("invalid listen address %q", addr)
+		}
+		host = addr
+	case strings.Contains(addr, ":"):
+		i := strings.IndexByte(addr, ':')
+		host, port = addr[:i], addr[i+1:]
+	case isDigits(addr):
+		port, hasHost = addr, false
+	default:
+		host = addr
+	}
+	if hasHost {
+		if host == "*" {
+			host = ""
+		}
+		res.Host = host
+	}
+	if port != "" {
+		p, err := parsePort(port)
+		if err != nil {
+			return res, fmt.Errorf("invalid listen address %q: %w", addr, err)
+		}
+		res.Port = p
+	}
+	if res.Port < 0 || res.Port > 65535 {
+		return res, fmt.Errorf("invalid listen address %q: port %d out of range", addr, res.Port)
+	}
+	return res, nil
+}
+
+// NormalizeListenAddr 将监听地址规范化为host:port,规则见ParseListenAddr
+func NormalizeListenAddr(addr string, defaultHost string, defaultPort int) (string, error) {
+	a, err := ParseListenAddr(addr, defaultHost, defaultPort)
+	if err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+// parseZonedIP 解析可能带有区域标识(如"fe80::1%eth0")的地址
+func parseZonedIP(s string) net.IP {
+	if i := strings.IndexByte(s, '%'); i > 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
+
+func parsePort(s string) (int, error) {
+	if isDigits(s) {
+		p, err := strconv.Atoi(s)
+		if err != nil || p > 65535 {
+			return 0, fmt.Errorf("port %s out of range", s)
+		}
+		return p, nil
+	}
+	return net.LookupPort("tcp", s)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ListenerPort 返回TCP或UDP地址的端口,其他地址返回0
+func ListenerPort(addr net.Addr) int {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.Port
+	case *net.UDPAddr:
+		return a.Port
+	}
+	return 0
+}
+
+// ListenFreeTCP 在host上监听一个空闲的TCP端口,minPort和maxPort都为0时由系统分配,否则在[minPort, maxPort]中选择。
+//
+// 返回的监听器保持打开,直接交给服务使用(如http.Serve),端口通过ListenerPort(l.Addr())获取;
+// 不要关闭后再按端口号重新绑定,期间端口可能被其他进程占用。
+func ListenFreeTCP(host string, minPort, maxPort int) (net.Listener, error) {
+	var l net.Listener
+	err := tryPorts(host, minPort, maxPort, func(addr string) (err error) {
+		l, err = net.Listen("tcp", addr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ListenFreeUDP 在host上监听一个空闲的UDP端口,端口选择规则同ListenFreeTCP
+func ListenFreeUDP(host string, minPort, maxPort int) (net.PacketConn, error) {
+	var c net.PacketConn
+	err := tryPorts(host, minPort, maxPort, func(addr string) (err error) {
+		c, err = net.ListenPacket("udp", addr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListenFreeTCPUDP 在host上同时监听TCP和UDP的同一个空闲端口,适用于DNS等同时使用两种协议的服务
+func ListenFreeTCPUDP(host string, minPort, maxPort int) (net.Listener, net.PacketConn, error) {
+	var l net.Listener
+	var c net.PacketConn
+	listen := func(addr string) (err error) {
+		if l, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+		// 系统分配端口时需要使用TCP实际得到的端口和地址
+		tcpAddr := l.Addr().(*net.TCPAddr)
+		udpHost := host
+		if host != "" {
+			udpHost = tcpAddr.IP.String()
+		}
+		if c, err = net.ListenPacket("udp", net.JoinHostPort(udpHost, strconv.Itoa(tcpAddr.Port))); err != nil {
+			l.Close()
+		}
+		return err
+	}
+	var err error
+	if minPort == 0 && maxPort == 0 {
+		// 系统分配的TCP端口对应的UDP端口可能已被占用,重试几次
+		for i := 0; i < 16; i++ {
+			if err = listen(net.JoinHostPort(host, "0")); err == nil || !isPortUnavailable(err) {
+				break
+			}
+		}
+	} else {
+		err = tryPorts(host, minPort, maxPort, listen)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, c, nil
+}
+
+// tryPorts 从范围内随机的位置开始依次尝试listen,直到成功或所有端口都已尝试
+func tryPorts(host string, minPort, maxPort int, listen func(addr string) error) error {
+	if minPort == 0 && maxPort == 0 {
+		return listen(net.JoinHostPort(host, "0"))
+	}
+	if minPort < 1 || maxPort > 65535 || minPort > maxPort {
+		return fmt.Errorf("invalid port range %d-%d", minPort, maxPort)
+	}
+	n := maxPort - minPort + 1
+	// 随机选择起始端口,减少并行测试之间的冲突
+	start := int(uint64(time.Now().UnixNano()) % uint64(n))
+	var err error
+	for i := 0; i < n; i++ {
+		port := minPort + (start+i)%n
+		if err = listen(net.JoinHostPort(host, strconv.Itoa(port))); err == nil {
+			return nil
+		}
+		if !isPortUnavailable(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("no free port in range %d-%d: %w", minPort, maxPort, err)
+}
+
+// isPortUnavailable 端口被占用或无权限等只与端口本身有关的错误,其他端口可能可用
+func isPortUnavailable(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	// 地址解析错误或地址不属于本机时换端口也无法解决
+	switch opErr.Err.(type) {
+	case *net.AddrError, *net.DNSError:
+		return false
+	}
+	return !errors.Is(err, syscall.EADDRNOTAVAIL)
+}
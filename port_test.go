@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseListenAddr(t *testing.T) {
+	cases := []struct {
+		addr     string
+		expected string
+	}{
+		{"", "127.0.0.1:8080"},
+		{"9090", "127.0.0.1:9090"},
+		{":9090", ":9090"},
+		{"*:9090", ":9090"},
+		{"0.0.0.0:80", "0.0.0.0:80"},
+		{"localhost", "localhost:8080"},
+		{"example.com:443", "example.com:443"},
+		{"[::1]:9090", "[::1]:9090"},
+		{"[::1]", "[::1]:8080"},
+		{"::1", "[::1]:8080"},
+		{"fe80::1%eth0", "[fe80::1%eth0]:8080"},
+		{"[::]:0", "[::]:0"},
+		{" 10.0.0.1:443 ", "10.0.0.1:443"},
+	}
+	for _, c := range cases {
+		got, err := NormalizeListenAddr(c.addr, "127.0.0.1", 8080)
+		if err != nil || got != c.expected {
+			t.Errorf("NormalizeListenAddr(%q):\n Expect => %s\n Got => %s %v\n", c.addr, c.expected, got, err)
+		}
+	}
+	for _, bad := range []string{"[::1", "[::1]x", "[nothost]:80", "host:65536", "host:-1", "1:2:3", "host:nosuchservice-xyz"} {
+		if got, err := ParseListenAddr(bad, "", 80); err == nil {
+			t.Errorf("ParseListenAddr(%q):\n Expect => error\n Got => %v\n", bad, got)
+		}
+	}
+	if a, err := ParseListenAddr("localhost:http", "", 0); err == nil && a.Port != 80 {
+		t.Errorf("ParseListenAddr(localhost:http):\n Expect => 80\n Got => %d\n", a.Port)
+	}
+}
+
+func TestListenFree(t *testing.T) {
+	l, err := ListenFreeTCP("127.0.0.1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := ListenerPort(l.Addr())
+	if port == 0 {
+		t.Fatalf("ListenFreeTCP:\n Expect => port\n Got => %v\n", l.Addr())
+	}
+
+	// 范围内只有一个端口且已被占用
+	if _, err := ListenFreeTCP("127.0.0.1", port, port); err == nil {
+		t.Errorf("ListenFreeTCP(%d-%d):\n Expect => error\n Got => nil\n", port, port)
+	}
+	if _, err := ListenFreeTCP("127.0.0.1", 2, 1); err == nil {
+		t.Errorf("ListenFreeTCP(2-1):\n Expect => error\n Got => nil\n")
+	}
+
+	// 在范围内分配多个端口,互不相同
+	min, max := 20000+port%20000, 20000+port%20000+50
+	seen := map[int]bool{}
+	for i := 0; i < 5; i++ {
+		l, err := ListenFreeTCP("127.0.0.1", min, max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		p := ListenerPort(l.Addr())
+		if p < min || p > max || seen[p] {
+			t.Errorf("ListenFreeTCP(%d-%d):\n Expect => unused port in range\n Got => %d\n", min, max, p)
+		}
+		seen[p] = true
+	}
+
+	c, err := ListenFreeUDP("127.0.0.1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if ListenerPort(c.LocalAddr()) == 0 {
+		t.Errorf("ListenFreeUDP:\n Expect => port\n Got => %v\n", c.LocalAddr())
+	}
+
+	tl, uc, err := ListenFreeTCPUDP("127.0.0.1", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	defer uc.Close()
+	if ListenerPort(tl.Addr()) != ListenerPort(uc.LocalAddr()) {
+		t.Errorf("ListenFreeTCPUDP:\n Expect => same port\n Got => %v %v\n", tl.Addr(), uc.LocalAddr())
+	}
+
+	// 地址不属于本机时立即失败
+	if _, err := ListenFreeTCP("192.0.2.1", 20000, 60000); err == nil {
+		t.Errorf("ListenFreeTCP(192.0.2.1):\n Expect => error\n Got => nil\n")
+	}
+	if ListenerPort(&net.UnixAddr{Name: "/tmp/x", Net: "unix"}) != 0 {
+		t.Errorf("ListenerPort(unix):\n Expect => 0\n")
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"unicode/utf8"
+)
+
+// 常用的随机字符串字母表
+const (
+	AlphabetDigits       = "0123456789"
+	AlphabetLower        = "abcdefghijklmnopqrstuvwxyz"
+	AlphabetUpper        = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	AlphabetAlphanumeric = AlphabetDigits + AlphabetLower + AlphabetUpper
+	// AlphabetReadable 去掉了容易混淆的0/O、1/l/I等字符,适合人工输入的邀请码等
+	AlphabetReadable = "23456789abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// ErrRandomAlphabet 字母表为空或包含重复字符
+var ErrRandomAlphabet = errors.New("random: alphabet must contain distinct characters")
+
+// randReader 随机数来源,测试时可以替换
+var randReader io.Reader = rand.Reader
+
+// RandomBytes 使用crypto/rand生成n个随机字节,可用作盐值和密钥
+func RandomBytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("random: negative length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RandomHex 生成n个随机字节并返回十六进制字符串(长度为2n)
+func RandomHex(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RandomToken 生成n个随机字节并返回不带填充的URL安全base64字符串,适合作为会话ID、重置密码链接等令牌,
+// n建议不少于16(128位)
+func RandomToken(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RandomDigits 生成n位数字验证码,可以以0开头
+func RandomDigits(n int) (string, error) {
+	return RandomString(n, AlphabetDigits)
+}
+
+// RandomString 从alphabet中均匀地选择n个字符,alphabet可以包含多字节字符。
+// 使用拒绝采样,不存在取模偏差。
+func RandomString(n int, alphabet string) (string, error) {
+	chars := []rune(alphabet)
+	if len(chars) == 0 || !utf8.ValidString(alphabet) || hasDuplicateRune(chars) {
+		return "", ErrRandomAlphabet
+	}
+	if n < 0 {
+		return "", fmt.Errorf("random: negative length %d", n)
+	}
+	res := make([]rune, n)
+	if len(chars) == 1 {
+		for i := range res {
+			res[i] = chars[0]
+		}
+		return string(res), nil
+	}
+	if len(chars) > 256 {
+		for i := range res {
+			idx, err := RandomInt(0, int64(len(chars)))
+			if err != nil {
+				return "", err
+			}
+			res[i] = chars[idx]
+		}
+		return string(res), nil
+	}
+	// 每个字节取低位作为下标,超出字母表的丢弃
+	mask := byte(1)
+	for int(mask) < len(chars)-1 {
+		mask = mask<<1 | 1
+	}
+	buf := make([]byte, n+n/2+8)
+	for i := 0; i < n; {
+		if _, err := io.ReadFull(randReader, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if idx := int(b & mask); idx < len(chars) {
+				res[i] = chars[idx]
+				if i++; i == n {
+					break
+				}
+			}
+		}
+	}
+	return string(res), nil
+}
+
+func hasDuplicateRune(chars []rune) bool {
+	seen := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		if seen[c] {
+			return true
+		}
+		seen[c] = true
+	}
+	return false
+}
+
+// RandomInt 返回[min, max)中均匀分布的随机整数,没有取模偏差
+func RandomInt(min, max int64) (int64, error) {
+	if max <= min {
+		return 0, fmt.Errorf("random: invalid range [%d, %d)", min, max)
+	}
+	n := new(big.Int).Sub(big.NewInt(max), big.NewInt(min))
+	v, err := rand.Int(randReader, n)
+	if err != nil {
+		return 0, err
+	}
+	return v.Add(v, big.NewInt(min)).Int64(), nil
+}
+
+// mustRandom 用于没有错误返回值的旧函数,系统随机数不可用时无法安全地继续
+func mustRandom(s string, err error) string {
+	if err != nil {
+		panic("random: " + err.Error())
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRandomString(t *testing.T) {
+	for _, alphabet := range []string{AlphabetDigits, AlphabetAlphanumeric, AlphabetReadable, "ab", "x", "甲乙丙丁"} {
+		s, err := RandomString(64, alphabet)
+		if err != nil || utf8.RuneCountInString(s) != 64 {
+			t.Fatalf("RandomString(%q):\n Expect => 64 chars\n Got => %q %v\n", alphabet, s, err)
+		}
+		for _, c := range s {
+			if !strings.ContainsRune(alphabet, c) {
+				t.Errorf("RandomString(%q):\n Expect => chars in alphabet\n Got => %q\n", alphabet, s)
+			}
+		}
+	}
+	for _, bad := range []string{"", "aab", "\xff"} {
+		if _, err := RandomString(8, bad); !errors.Is(err, ErrRandomAlphabet) {
+			t.Errorf("RandomString(%q):\n Expect => %v\n Got => %v\n", bad, ErrRandomAlphabet, err)
+		}
+	}
+	if _, err := RandomString(-1, AlphabetDigits); err == nil {
+		t.Errorf("RandomString(-1):\n Expect => error\n Got => nil\n")
+	}
+
+	// 超出字母表范围的字节被丢弃而不是取模:10个数字使用低4位,10-15被拒绝
+	old := randReader
+	defer func() { randReader = old }()
+	randReader = bytes.NewReader(append([]byte{0x0a, 0x1f, 0x03, 0xf9, 0x0c}, make([]byte, 64)...))
+	if s, err := RandomDigits(2); err != nil || s != "39" {
+		t.Errorf("RandomDigits:\n Expect => 39\n Got => %s %v\n", s, err)
+	}
+
+	// 大字母表使用RandomInt
+	randReader = old
+	large := make([]rune, 300)
+	for i := range large {
+		large[i] = rune(0x4e00 + i)
+	}
+	if s, err := RandomString(10, string(large)); err != nil || utf8.RuneCountInString(s) != 10 {
+		t.Errorf("RandomString(300 chars):\n Expect => 10 chars\n Got => %q %v\n", s, err)
+	}
+}
+
+func TestRandomDistribution(t *testing.T) {
+	// 3个字符使用低2位,若取模则第一个字符的概率是其他的两倍
+	counts := map[rune]int{}
+	s, err := RandomString(30000, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range s {
+		counts[c]++
+	}
+	for c, n := range counts {
+		if n < 9000 || n > 11000 {
+			t.Errorf("RandomString distribution:\n Expect => ~10000 each\n Got => %c: %d\n", c, n)
+		}
+	}
+
+	seen := map[int64]bool{}
+	for i := 0; i < 1000; i++ {
+		v, err := RandomInt(-3, 3)
+		if err != nil || v < -3 || v >= 3 {
+			t.Fatalf("RandomInt(-3, 3):\n Expect => [-3, 3)\n Got => %d %v\n", v, err)
+		}
+		seen[v] = true
+	}
+	if len(seen) != 6 {
+		t.Errorf("RandomInt(-3, 3):\n Expect => 6 values\n Got => %v\n", seen)
+	}
+	if _, err := RandomInt(1, 1); err == nil {
+		t.Errorf("RandomInt(1, 1):\n Expect => error\n Got => nil\n")
+	}
+	if v, err := RandomInt(-1<<63, 1<<63-1); err != nil || v == 1<<63-1 {
+		t.Errorf("RandomInt(full range):\n Got => %d %v\n", v, err)
+	}
+}
+
+func TestRandomTokens(t *testing.T) {
+	b, err := RandomBytes(16)
+	if err != nil || len(b) != 16 {
+		t.Fatalf("RandomBytes:\n Expect => 16 bytes\n Got => %x %v\n", b, err)
+	}
+	h, _ := RandomHex(16)
+	if _, err := hex.DecodeString(h); err != nil || len(h) != 32 {
+		t.Errorf("RandomHex:\n Expect => 32 hex chars\n Got => %s\n", h)
+	}
+	tok, _ := RandomToken(32)
+	if d, err := base64.RawURLEncoding.DecodeString(tok); err != nil || len(d) != 32 {
+		t.Errorf("RandomToken:\n Expect => url-safe base64 of 32 bytes\n Got => %s\n", tok)
+	}
+	if tok2, _ := RandomToken(32); tok == tok2 {
+		t.Errorf("RandomToken:\n Expect => different tokens\n Got => %s\n", tok)
+	}
+
+	// 旧函数使用crypto/rand,连续调用结果不同
+	if GenerateSalt() == GenerateSalt() || len(GenerateSalt()) != 32 {
+		t.Errorf("GenerateSalt:\n Expect => random 32 hex chars\n")
+	}
+	if code := GenerateRandomCode(); len(code) != 6 || strings.Trim(code, AlphabetDigits) != "" {
+		t.Errorf("GenerateRandomCode:\n Expect => 6 digits\n Got => %s\n", code)
+	}
+	if s := RandStr(8); len(s) != 8 || strings.Trim(s, AlphabetLower) != "" || s == RandStr(8) {
+		t.Errorf("RandStr:\n Expect => 8 random lowercase letters\n Got => %s\n", s)
+	}
+	if s := RandString(16); len(s) != 16 || strings.Trim(s, AlphabetAlphanumeric) != "" || s == RandString(16) {
+		t.Errorf("RandString:\n Expect => 16 random alphanumerics\n Got => %s\n", s)
+	}
+	if v := RandInt(5, 5); v != 5 {
+		t.Errorf("RandInt(5, 5):\n Expect => 5\n Got => %d\n", v)
+	}
+}
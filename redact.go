@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskString 保留前keepPrefix个和后keepSuffix个字符,其余替换为*,按字符而不是字节计算;
+// 保留部分不少于全部字符时整体替换为*
+func MaskString(s string, keepPrefix, keepSuffix int) string {
+	n := utf8.RuneCountInString(s)
+	if keepPrefix < 0 {
+		keepPrefix = 0
+	}
+	if keepSuffix < 0 {
+		keepSuffix = 0
+	}
+	if keepPrefix+keepSuffix >= n {
+		keepPrefix, keepSuffix = 0, 0
+	}
+	var b strings.Builder
+	i := 0
+	for _, r := range s {
+		if i < keepPrefix || i >= n-keepSuffix {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('*')
+		}
+		i++
+	}
+	return b.String()
+}
+
+// MaskPhone 手机号脱敏,保留前3位和后4位,如138****8000
+func MaskPhone(phone string) string {
+	return maskDigits(phone, 3, 4)
+}
+
+// MaskIDCard 身份证号脱敏,保留前3位和后4位,如110***********002X
+func MaskIDCard(id string) string {
+	return MaskString(id, 3, 4)
+}
+
+// MaskBankCard 银行卡号脱敏,只保留后4位,保留原有的空格和连字符
+func MaskBankCard(card string) string {
+	return maskDigits(card, 0, 4)
+}
+
+// MaskEmail 邮箱脱敏,用户名只保留第一个字符,如a***@example.com;不是邮箱时整体替换为*
+func MaskEmail(email string) string {
+	i := strings.LastIndexByte(email, '@')
+	if i <= 0 {
+		return MaskString(email, 0, 0)
+	}
+	r, _ := utf8.DecodeRuneInString(email)
+	return string(r) + "***" + email[i:]
+}
+
+// maskDigits 只替换数字,分隔符原样保留
+func maskDigits(s string, keepPrefix, keepSuffix int) string {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if isDigit(s[i]) {
+			n++
+		}
+	}
+	if keepPrefix+keepSuffix >= n {
+		keepPrefix, keepSuffix = 0, 0
+	}
+	b := []byte(s)
+	d := 0
+	for i := range b {
+		if !isDigit(b[i]) {
+			continue
+		}
+		if d >= keepPrefix && d < n-keepSuffix {
+			b[i] = '*'
+		}
+		d++
+	}
+	return string(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+var (
+	redactEmailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	// 数字串,允许单个空格或连字符分隔,身份证号末位可以是X
+	redactNumberRegexp = regexp.MustCompile(`\d(?:[ \-]?\d)+[Xx]?`)
+)
+
+// Redact 在任意文本中查找并脱敏银行卡号、手机号、邮箱和身份证号,用于写日志前处理请求参数、错误信息等。
+//
+// 银行卡号为14到19位且通过Luhn校验的数字,手机号为1[3-9]开头的11位数字(可以带86前缀),
+// 身份证号为18位且出生日期合法的号码。其他数字,例如订单号和时间戳,保持不变。
+func Redact(text string) string {
+	text = redactEmailRegexp.ReplaceAllStringFunc(text, MaskEmail)
+	locs := redactNumberRegexp.FindAllStringIndex(text, -1)
+	if len(locs) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		start, end := loc[0], loc[1]
+		// 前后紧邻字母或数字时是更长标识符的一部分,例如十六进制串
+		if (start > 0 && isWordByte(text[start-1])) || (end < len(text) && isWordByte(text[end])) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(redactSpan(text[start:end]))
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return isDigit(c) || c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// redactMaxGroups 一个号码最多的分组数,例如19位卡号按4-4-4-4-3书写
+const redactMaxGroups = 5
+
+// redactSpan 正则会把相邻的数字合并成一段,例如"2024-01-15 13800138000"。
+// 按分隔符拆成数字组,从左向右优先尝试最长的连续分组,识别出的号码脱敏,其余原样保留
+func redactSpan(s string) string {
+	var groups [][2]int
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || s[i] == ' ' || s[i] == '-' {
+			groups = append(groups, [2]int{start, i})
+			start = i + 1
+		}
+	}
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(groups); {
+		n := len(groups) - i
+		if n > redactMaxGroups {
+			n = redactMaxGroups
+		}
+		for ; n > 0; n-- {
+			sub := s[groups[i][0]:groups[i+n-1][1]]
+			if n > 1 && !isNumberGrouping(sub, groups[i:i+n]) {
+				continue
+			}
+			if r := redactNumber(sub); r != sub {
+				b.WriteString(s[last:groups[i][0]])
+				b.WriteString(r)
+				last = groups[i+n-1][1]
+				break
+			}
+		}
+		if n == 0 {
+			n = 1
+		}
+		i += n
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// isNumberGrouping 判断分组是否像卡号或手机号的书写方式:分隔符一致且每组2到6位,
+// 例如4111 1111 1111 1111、138-0013-8000、86 138 0013 8000
+func isNumberGrouping(s string, groups [][2]int) bool {
+	sep := s[groups[1][0]-groups[0][0]-1]
+	for i, g := range groups {
+		if n := g[1] - g[0]; n < 2 || n > 6 {
+			return false
+		}
+		if i > 0 && s[g[0]-groups[0][0]-1] != sep {
+			return false
+		}
+	}
+	return true
+}
+
+// redactNumber 按类型脱敏数字串,无法识别时原样返回
+func redactNumber(s string) string {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(digits) == 18 && len(digits) == len(s) && isIDCardNumber(digits) {
+		return MaskIDCard(s)
+	}
+	if last := digits[len(digits)-1]; last == 'X' || last == 'x' {
+		return s
+	}
+	switch n := len(digits); {
+	case n == 11 && isMobile(digits):
+		return MaskPhone(s)
+	case n == 13 && strings.HasPrefix(digits, "86") && isMobile(digits[2:]):
+		return maskDigits(s, 5, 4)
+	case n >= 14 && n <= 19 && luhn(digits):
+		return MaskBankCard(s)
+	}
+	return s
+}
+
+func isMobile(s string) bool {
+	return len(s) == 11 && s[0] == '1' && s[1] >= '3'
+}
+
+// isIDCardNumber 18位身份证号,只校验格式和出生日期,不校验末位校验码,宁可多脱敏
+func isIDCardNumber(s string) bool {
+	for i := 0; i < 17; i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	year, month, day := s[6:10], s[10:12], s[12:14]
+	return (year[:2] == "18" || year[:2] == "19" || year[:2] == "20") &&
+		month >= "01" && month <= "12" && day >= "01" && day <= "31"
+}
+
+// luhn 银行卡号的Luhn校验
+func luhn(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		if !isDigit(s[i]) {
+			return false
+		}
+		d := int(s[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,50 @@
+package utils
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	cases := []struct {
+		got, expected string
+	}{
+		{MaskString("张三丰", 1, 0), "张**"},
+		{MaskString("ab", 1, 1), "**"},
+		{MaskPhone("13800138000"), "138****8000"},
+		{MaskPhone("138-0013-8000"), "138-****-8000"},
+		{MaskIDCard("11010519491231002X"), "110***********002X"},
+		{MaskBankCard("6222 0212 3456 7890"), "**** **** **** 7890"},
+		{MaskEmail("alice@example.com"), "a***@example.com"},
+		{MaskEmail("not an email"), "************"},
+	}
+	for _, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("Mask:\n Expect => %s\n Got => %s\n", c.expected, c.got)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		text, expected string
+	}{
+		{"手机13800138000,邮箱bob.smith@mail.example.cn", "手机138****8000,邮箱b***@mail.example.cn"},
+		{"tel: +86 138 0013 8000", "tel: +86 138 **** 8000"},
+		{"身份证11010519491231002X已登记", "身份证110***********002X已登记"},
+		{"card=4111-1111-1111-1111&cvv=123", "card=****-****-****-1111&cvv=123"},
+		{"卡号6222021234567890128", "卡号***************0128"},
+		// 不是手机号、卡号或身份证号的数字保持不变
+		{"order 20240115000123 at 1700000000000", "order 20240115000123 at 1700000000000"},
+		{"ts=12345678901 id=a13800138000b", "ts=12345678901 id=a13800138000b"},
+		{"2024-01-15 10:00:00 ok", "2024-01-15 10:00:00 ok"},
+		{"13800138000@qq.com", "1***@qq.com"},
+		// 相邻的数字被合并成一段时分别识别
+		{"2024-01-15 13800138000 登录", "2024-01-15 138****8000 登录"},
+		{"a 13800138000 13900139000", "a 138****8000 139****9000"},
+		{"card 4111 1111 1111 1111 2 items", "card **** **** **** 1111 2 items"},
+		{"86 138 0013 8000 x", "86 138 **** 8000 x"},
+	}
+	for _, c := range cases {
+		if got := Redact(c.text); got != c.expected {
+			t.Errorf("Redact(%q):\n Expect => %s\n Got => %s\n", c.text, c.expected, got)
+		}
+	}
+}
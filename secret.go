@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"runtime"
+)
+
+// SecretRedacted Secret在日志和序列化中显示的内容
+const SecretRedacted = "[REDACTED]"
+
+// Secret 密码、令牌等敏感字符串,在fmt、log和JSON输出中显示为[REDACTED],
+// 只有Reveal和Bytes能取得原值。可以作为配置结构体的字段,JSON和文本反序列化时读取原值。
+//
+// 原值保存在指针指向的secretBuf中,Secret作为未导出字段被%+v等反射输出时只显示地址。
+// Secret的副本共享同一个secretBuf,Zero会清除所有副本。
+type Secret struct {
+	p *secretBuf
+}
+
+type secretBuf struct {
+	b []byte
+}
+
+// NewSecret 创建Secret,Go的字符串不可修改,s本身无法清除,能拿到[]byte时请使用NewSecretBytes
+func NewSecret(s string) Secret {
+	return Secret{p: &secretBuf{b: []byte(s)}}
+}
+
+// NewSecretBytes 复制b创建Secret,调用方可以随后用Zero清除b
+func NewSecretBytes(b []byte) Secret {
+	return Secret{p: &secretBuf{b: append([]byte(nil), b...)}}
+}
+
+// bytes 原值,零值Secret返回nil
+func (s Secret) bytes() []byte {
+	if s.p == nil {
+		return nil
+	}
+	return s.p.b
+}
+
+// Reveal 返回原值
+func (s Secret) Reveal() string {
+	return string(s.bytes())
+}
+
+// Bytes 返回原值,与Secret共享内存,不要修改
+func (s Secret) Bytes() []byte {
+	return s.bytes()
+}
+
+// Len 原值的字节数
+func (s Secret) Len() int {
+	return len(s.bytes())
+}
+
+// IsEmpty 是否为空
+func (s Secret) IsEmpty() bool {
+	return len(s.bytes()) == 0
+}
+
+// Equal 常量时间比较
+func (s Secret) Equal(other Secret) bool {
+	return SecureCompare(s.bytes(), other.bytes())
+}
+
+// EqualString 与明文进行常量时间比较,例如校验请求中的API密钥
+func (s Secret) EqualString(other string) bool {
+	return SecureCompareString(s.Reveal(), other)
+}
+
+// Zero 清除原值占用的内存,之后Secret及其所有副本都为空
+func (s *Secret) Zero() {
+	if s.p == nil {
+		return
+	}
+	Zero(s.p.b)
+	s.p.b = nil
+}
+
+func (s Secret) String() string {
+	return SecretRedacted
+}
+
+func (s Secret) GoString() string {
+	return "utils.Secret(" + SecretRedacted + ")"
+}
+
+// Format 实现fmt.Formatter,%v、%+v、%#v、%s、%q和%x等所有动词都不会输出原值
+func (s Secret) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'q':
+		fmt.Fprintf(f, "%q", SecretRedacted)
+	case 'v':
+		if f.Flag('#') {
+			f.Write([]byte(s.GoString()))
+			return
+		}
+		f.Write([]byte(SecretRedacted))
+	default:
+		f.Write([]byte(SecretRedacted))
+	}
+}
+
+// MarshalJSON 输出"[REDACTED]"
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SecretRedacted)
+}
+
+// MarshalText 输出[REDACTED],用于yaml、toml等文本格式
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(SecretRedacted), nil
+}
+
+// UnmarshalJSON 读取原值,用于从配置文件加载
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = NewSecret(v)
+	return nil
+}
+
+// UnmarshalText 读取原值
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = NewSecretBytes(text)
+	return nil
+}
+
+// SecureCompare 常量时间比较a和b,耗时只与长度有关,用于比较令牌、签名和密码哈希
+func SecureCompare(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// SecureCompareString 常量时间比较字符串
+func SecureCompareString(a, b string) bool {
+	return SecureCompare([]byte(a), []byte(b))
+}
+
+// Zero 尽力清除内存中的密钥等敏感数据;垃圾回收可能已经复制过数据,不能保证没有残留
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
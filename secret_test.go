@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	type config struct {
+		User     string
+		Password Secret
+	}
+	var c config
+	if err := json.Unmarshal([]byte(`{"User":"root","Password":"p@ssw0rd"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Password.Reveal() != "p@ssw0rd" {
+		t.Errorf("UnmarshalJSON:\n Expect => p@ssw0rd\n Got => %s\n", c.Password.Reveal())
+	}
+
+	var buf bytes.Buffer
+	log.New(&buf, "", 0).Printf("%v %+v %#v %s %q %x %d", c, c, c, c.Password, c.Password, c.Password, c.Password)
+	data, _ := json.Marshal(c)
+	text, _ := c.Password.MarshalText()
+	for _, out := range []string{buf.String(), string(data), string(text), fmt.Sprint(c.Password), c.Password.String()} {
+		if strings.Contains(out, "p@ssw0rd") || !strings.Contains(out, SecretRedacted) {
+			t.Errorf("Secret:\n Expect => %s\n Got => %s\n", SecretRedacted, out)
+		}
+	}
+	if string(data) != `{"User":"root","Password":"[REDACTED]"}` {
+		t.Errorf("MarshalJSON:\n Got => %s\n", data)
+	}
+
+	if !c.Password.Equal(NewSecret("p@ssw0rd")) || c.Password.EqualString("p@ssw0rD") || !c.Password.EqualString("p@ssw0rd") {
+		t.Errorf("Equal:\n Expect => only the same value is equal\n")
+	}
+
+	raw := []byte("token")
+	s := NewSecretBytes(raw)
+	Zero(raw)
+	if !bytes.Equal(raw, make([]byte, 5)) || s.Reveal() != "token" {
+		t.Errorf("Zero:\n Expect => raw cleared, secret kept\n Got => %q %q\n", raw, s.Reveal())
+	}
+	b := s.Bytes()
+	cp := s
+	s.Zero()
+	if !s.IsEmpty() || !bytes.Equal(b, make([]byte, 5)) {
+		t.Errorf("Secret.Zero:\n Expect => cleared\n Got => %q %q\n", s.Reveal(), b)
+	}
+	// 副本共享原值,不会留下一串NUL字节
+	if !cp.IsEmpty() || cp.Reveal() != "" {
+		t.Errorf("Secret.Zero(copy):\n Expect => empty\n Got => %q\n", cp.Reveal())
+	}
+
+	// 未导出字段不会调用Format,fmt通过反射输出
+	pw := struct{ pw Secret }{NewSecret("hunter2")}
+	for _, out := range []string{fmt.Sprintf("%v", pw), fmt.Sprintf("%+v", pw), fmt.Sprintf("%#v", pw)} {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, "104 117 110") {
+			t.Errorf("Secret(unexported):\n Expect => no plaintext\n Got => %s\n", out)
+		}
+	}
+
+	var zero Secret
+	zero.Zero()
+	if !zero.IsEmpty() || zero.Reveal() != "" || !zero.Equal(Secret{}) {
+		t.Errorf("Secret(zero value):\n Expect => empty\n Got => %q\n", zero.Reveal())
+	}
+}
+
+func TestSecureCompare(t *testing.T) {
+	if !SecureCompare([]byte("abc"), []byte("abc")) || SecureCompare([]byte("abc"), []byte("abd")) || SecureCompare([]byte("abc"), []byte("ab")) {
+		t.Errorf("SecureCompare:\n Expect => only equal values match\n")
+	}
+	if !SecureCompareString("", "") || SecureCompareString("a", "") {
+		t.Errorf("SecureCompareString:\n Expect => only equal values match\n")
+	}
+}
@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrSignature 签名不正确
+	ErrSignature = errors.New("sign: verification failed")
+	// ErrKeyFormat 无法解析的密钥
+	ErrKeyFormat = errors.New("sign: unrecognized key format")
+)
+
+// KeyType 生成密钥对时使用的算法
+type KeyType int
+
+const (
+	KeyRSA2048 KeyType = iota + 1
+	KeyRSA3072
+	KeyRSA4096
+	KeyECDSAP256
+	KeyECDSAP384
+	KeyECDSAP521
+	KeyEd25519
+)
+
+// GenerateKey 生成密钥对,返回的私钥可以通过Public()得到公钥
+func GenerateKey(t KeyType) (crypto.Signer, error) {
+	switch t {
+	case KeyRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case KeyRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyECDSAP521:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case KeyEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	}
+	return nil, fmt.Errorf("sign: unknown key type %d", t)
+}
+
+// keyBlocks 将PEM、不带头尾的base64或DER统一为DER,支付宝等平台提供的密钥通常是不带头尾的base64
+func keyBlocks(data []byte) (typ string, der []byte) {
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Type, block.Bytes
+	}
+	s := strings.Join(strings.Fields(string(data)), "")
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) > 0 {
+		return "", b
+	}
+	return "", data
+}
+
+// ParsePrivateKey 解析私钥,支持PEM、DER或不带头尾的base64,格式可以是PKCS#1、PKCS#8或SEC1。
+// 返回*rsa.PrivateKey、*ecdsa.PrivateKey或ed25519.PrivateKey
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	typ, der := keyBlocks(data)
+	switch typ {
+	case "", "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+	default:
+		return nil, fmt.Errorf("%w: unexpected PEM type %q", ErrKeyFormat, typ)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if s, ok := key.(crypto.Signer); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%w: unsupported private key type %T", ErrKeyFormat, key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, ErrKeyFormat
+}
+
+// ParsePublicKey 解析公钥,支持PEM、DER或不带头尾的base64,格式可以是PKIX、PKCS#1或X.509证书。
+// 返回*rsa.PublicKey、*ecdsa.PublicKey或ed25519.PublicKey
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	typ, der := keyBlocks(data)
+	switch typ {
+	case "", "PUBLIC KEY", "RSA PUBLIC KEY", "CERTIFICATE":
+	default:
+		return nil, fmt.Errorf("%w: unexpected PEM type %q", ErrKeyFormat, typ)
+	}
+	if typ == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrKeyFormat, err)
+		}
+		return cert.PublicKey, nil
+	}
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		return cert.PublicKey, nil
+	}
+	return nil, ErrKeyFormat
+}
+
+// MarshalPrivateKeyPEM 将私钥编码为PEM格式的PKCS#8("PRIVATE KEY")
+func MarshalPrivateKeyPEM(key crypto.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalRSAPrivateKeyPEM 将RSA私钥编码为PEM格式的PKCS#1("RSA PRIVATE KEY")
+func MarshalRSAPrivateKeyPEM(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// MarshalPublicKeyPEM 将公钥编码为PEM格式的PKIX("PUBLIC KEY")
+func MarshalPublicKeyPEM(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// MarshalRSAPublicKeyPEM 将RSA公钥编码为PEM格式的PKCS#1("RSA PUBLIC KEY")
+func MarshalRSAPublicKeyPEM(pub *rsa.PublicKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(pub)})
+}
+
+// SignOptions 签名参数,签名算法由密钥类型决定
+type SignOptions struct {
+	// Hash 摘要算法,默认为SHA-256;Ed25519忽略此项
+	Hash crypto.Hash
+	// PSS RSA密钥使用RSA-PSS(盐长度等于摘要长度),默认为PKCS#1 v1.5
+	PSS bool
+}
+
+func (o SignOptions) hash() (crypto.Hash, error) {
+	h := o.Hash
+	if h == 0 {
+		h = crypto.SHA256
+	}
+	if !h.Available() {
+		return 0, fmt.Errorf("sign: hash %v is not available", h)
+	}
+	return h, nil
+}
+
+func (o SignOptions) digest(data []byte) (crypto.Hash, []byte, error) {
+	h, err := o.hash()
+	if err != nil {
+		return 0, nil, err
+	}
+	d := h.New()
+	d.Write(data)
+	return h, d.Sum(nil), nil
+}
+
+// SignMessage 对data签名:RSA使用PKCS#1 v1.5或PSS,ECDSA输出ASN.1 DER编码,Ed25519直接对data签名
+func SignMessage(key crypto.Signer, data []byte, opts SignOptions) ([]byte, error) {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	h, digest, err := opts.digest(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := key.(*rsa.PrivateKey); ok && opts.PSS {
+		return key.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h})
+	}
+	return key.Sign(rand.Reader, digest, h)
+}
+
+// VerifyMessage 校验SignMessage生成的签名,签名不正确时返回ErrSignature
+func VerifyMessage(pub crypto.PublicKey, data, sig []byte, opts SignOptions) error {
+	if k, ok := pub.(ed25519.PublicKey); ok {
+		if len(k) != ed25519.PublicKeySize || !ed25519.Verify(k, data, sig) {
+			return ErrSignature
+		}
+		return nil
+	}
+	h, digest, err := opts.digest(data)
+	if err != nil {
+		return err
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if opts.PSS {
+			err = rsa.VerifyPSS(k, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: h})
+		} else {
+			err = rsa.VerifyPKCS1v15(k, h, digest, sig)
+		}
+		if err != nil {
+			return ErrSignature
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest, sig) {
+			return ErrSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("sign: unsupported public key type %T", pub)
+}
+
+// CanonicalizeParams 将参数按键名的ASCII顺序排列并拼接为"k1=v1&k2=v2",跳过空值和exclude中的键,
+// 与支付宝、微信支付等平台的待签名字符串规则一致。值不做URL编码。
+func CanonicalizeParams(params map[string]string, exclude ...string) string {
+	skip := make(map[string]bool, len(exclude))
+	for _, k := range exclude {
+		skip[k] = true
+	}
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if v != "" && !skip[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(params[k])
+	}
+	return b.String()
+}
+
+// SignParams 对规范化后的参数签名并返回base64编码的签名,参数中的"sign"总是被跳过。
+// 例如支付宝的RSA2签名:SignParams(key, params, SignOptions{Hash: crypto.SHA256})
+func SignParams(key crypto.Signer, params map[string]string, opts SignOptions, exclude ...string) (string, error) {
+	sig, err := SignMessage(key, []byte(CanonicalizeParams(params, append([]string{"sign"}, exclude...)...)), opts)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// VerifyParams 校验参数的签名,signature为base64编码;支付宝异步通知需要同时排除"sign_type"
+func VerifyParams(pub crypto.PublicKey, params map[string]string, signature string, opts SignOptions, exclude ...string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrSignature
+	}
+	return VerifyMessage(pub, []byte(CanonicalizeParams(params, append([]string{"sign"}, exclude...)...)), sig, opts)
+}
+
+// RSAEncryptOAEP 使用RSA-OAEP加密,hash同时用于MGF1,默认为SHA-256。
+// 微信支付敏感字段等使用SHA-1的场景需要传入crypto.SHA1
+func RSAEncryptOAEP(pub *rsa.PublicKey, plaintext, label []byte, hash crypto.Hash) ([]byte, error) {
+	h, err := SignOptions{Hash: hash}.hash()
+	if err != nil {
+		return nil, err
+	}
+	return rsa.EncryptOAEP(h.New(), rand.Reader, pub, plaintext, label)
+}
+
+// RSADecryptOAEP 解密RSAEncryptOAEP的结果
+func RSADecryptOAEP(priv *rsa.PrivateKey, ciphertext, label []byte, hash crypto.Hash) ([]byte, error) {
+	h, err := SignOptions{Hash: hash}.hash()
+	if err != nil {
+		return nil, err
+	}
+	return rsa.DecryptOAEP(h.New(), rand.Reader, priv, ciphertext, label)
+}
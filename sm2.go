@@ -0,0 +1,504 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/subtle"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// SM2CipherFormat SM2密文的格式
+type SM2CipherFormat int
+
+const (
+	// SM2C1C3C2 GB/T 32918.4-2016规定的顺序,04|x|y|SM3摘要|密文
+	SM2C1C3C2 SM2CipherFormat = iota
+	// SM2C1C2C3 旧标准和部分早期实现(如BouncyCastle默认)使用的顺序
+	SM2C1C2C3
+	// SM2ASN1 GB/T 35276的ASN.1格式,OpenSSL使用此格式
+	SM2ASN1
+)
+
+var (
+	// ErrSM2Decrypt SM2密文格式错误、密钥不匹配或密文被篡改
+	ErrSM2Decrypt = errors.New("sm2: decryption failed")
+	// ErrSM2Key 不是SM2曲线上的合法密钥
+	ErrSM2Key = errors.New("sm2: invalid key")
+)
+
+var (
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSM2            = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+)
+
+// sm2DefaultUID 未指定用户身份标识时使用的默认值
+var sm2DefaultUID = []byte("1234567812345678")
+
+var (
+	sm2Once  sync.Once
+	sm2Curve *elliptic.CurveParams
+)
+
+// SM2P256 返回GB/T 32918.5推荐的256位椭圆曲线
+//
+// 曲线基于elliptic.CurveParams的通用实现,点乘使用math/big运算,不是常量时间的(该实现已被标准库标记为Deprecated)。
+// 生成密钥、签名和解密的耗时与私钥、随机数k相关,能够精确测量耗时的攻击者可能据此恢复私钥;
+// 需要抵抗侧信道攻击的场景(如对外提供签名或解密服务)应使用经过审计的常量时间SM2实现。
+func SM2P256() elliptic.Curve {
+	sm2Once.Do(func() {
+		sm2Curve = &elliptic.CurveParams{Name: "SM2-P-256", BitSize: 256}
+		sm2Curve.P, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF", 16)
+		sm2Curve.N, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123", 16)
+		sm2Curve.B, _ = new(big.Int).SetString("28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93", 16)
+		sm2Curve.Gx, _ = new(big.Int).SetString("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7", 16)
+		sm2Curve.Gy, _ = new(big.Int).SetString("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0", 16)
+	})
+	// CurveParams按a=-3计算,SM2曲线的a正好是p-3;
+	// 调用方按32字节定长传入私钥和k,避免点乘的循环次数暴露标量的前导零字节
+	return sm2Curve
+}
+
+// GenerateSM2Key 生成SM2密钥对,不是常量时间的,参见SM2P256
+func GenerateSM2Key() (*ecdsa.PrivateKey, error) {
+	c := SM2P256()
+	// 签名需要计算(1+d)的逆,私钥取值范围为[1, n-2]
+	d, err := sm2RandScalar(new(big.Int).Sub(c.Params().N, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return sm2PrivateKey(d), nil
+}
+
+func sm2PrivateKey(d *big.Int) *ecdsa.PrivateKey {
+	c := SM2P256()
+	priv := &ecdsa.PrivateKey{D: d}
+	priv.Curve = c
+	priv.X, priv.Y = c.ScalarBaseMult(sm2Bytes(d))
+	return priv
+}
+
+// sm2RandScalar 返回[1, max)中的随机数
+func sm2RandScalar(max *big.Int) (*big.Int, error) {
+	buf := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(randReader, buf); err != nil {
+			return nil, err
+		}
+		k := new(big.Int).SetBytes(buf)
+		if k.Sign() > 0 && k.Cmp(max) < 0 {
+			return k, nil
+		}
+	}
+}
+
+func sm2CheckPublicKey(pub *ecdsa.PublicKey) error {
+	if pub == nil || pub.Curve == nil || pub.Curve.Params() != SM2P256().Params() ||
+		pub.X == nil || pub.Y == nil || !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return ErrSM2Key
+	}
+	return nil
+}
+
+func sm2CheckPrivateKey(priv *ecdsa.PrivateKey) error {
+	if priv == nil || priv.D == nil || priv.D.Sign() <= 0 ||
+		priv.D.Cmp(new(big.Int).Sub(SM2P256().Params().N, big.NewInt(1))) >= 0 {
+		return ErrSM2Key
+	}
+	return sm2CheckPublicKey(&priv.PublicKey)
+}
+
+// sm2ZA 用户身份摘要 ZA = SM3(ENTLA || ID || a || b || xG || yG || xA || yA)
+func sm2ZA(pub *ecdsa.PublicKey, uid []byte) ([]byte, error) {
+	if uid == nil {
+		uid = sm2DefaultUID
+	}
+	if len(uid) >= 8192 {
+		return nil, errors.New("sm2: uid too long")
+	}
+	p := SM2P256().Params()
+	a := new(big.Int).Sub(p.P, big.NewInt(3))
+	h := NewSM3()
+	var entl [2]byte
+	binary.BigEndian.PutUint16(entl[:], uint16(len(uid)*8))
+	h.Write(entl[:])
+	h.Write(uid)
+	for _, v := range []*big.Int{a, p.B, p.Gx, p.Gy, pub.X, pub.Y} {
+		h.Write(sm2Bytes(v))
+	}
+	return h.Sum(nil), nil
+}
+
+// sm2Bytes 大端32字节表示
+func sm2Bytes(v *big.Int) []byte {
+	b := make([]byte, 32)
+	return v.FillBytes(b)
+}
+
+type sm2Signature struct {
+	R, S *big.Int
+}
+
+// SM2Sign 使用SM2和SM3对msg签名,返回DER编码的(r, s)。uid为用户身份标识,为nil时使用默认的"1234567812345678"。
+// 不是常量时间的,参见SM2P256
+func SM2Sign(priv *ecdsa.PrivateKey, msg, uid []byte) ([]byte, error) {
+	if err := sm2CheckPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	za, err := sm2ZA(&priv.PublicKey, uid)
+	if err != nil {
+		return nil, err
+	}
+	c := SM2P256()
+	n := c.Params().N
+	e := new(big.Int).SetBytes(SM3(append(za, msg...)))
+	// (1+d)^-1
+	dInv := new(big.Int).Add(priv.D, big.NewInt(1))
+	dInv.ModInverse(dInv, n)
+	for {
+		k, err := sm2RandScalar(n)
+		if err != nil {
+			return nil, err
+		}
+		x1, _ := c.ScalarBaseMult(sm2Bytes(k))
+		r := new(big.Int).Add(e, x1)
+		r.Mod(r, n)
+		if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+			continue
+		}
+		// s = (1+d)^-1 * (k - r*d) mod n
+		s := new(big.Int).Mul(r, priv.D)
+		s.Sub(k, s)
+		s.Mul(s, dInv)
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		return asn1.Marshal(sm2Signature{r, s})
+	}
+}
+
+// SM2Verify 校验SM2Sign生成的签名,uid必须与签名时相同
+func SM2Verify(pub *ecdsa.PublicKey, msg, uid, sig []byte) bool {
+	if sm2CheckPublicKey(pub) != nil {
+		return false
+	}
+	var rs sm2Signature
+	if rest, err := asn1.Unmarshal(sig, &rs); err != nil || len(rest) != 0 {
+		return false
+	}
+	c := SM2P256()
+	n := c.Params().N
+	if rs.R.Sign() <= 0 || rs.S.Sign() <= 0 || rs.R.Cmp(n) >= 0 || rs.S.Cmp(n) >= 0 {
+		return false
+	}
+	t := new(big.Int).Add(rs.R, rs.S)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	za, err := sm2ZA(pub, uid)
+	if err != nil {
+		return false
+	}
+	e := new(big.Int).SetBytes(SM3(append(za, msg...)))
+	x1, y1 := c.ScalarBaseMult(rs.S.Bytes())
+	x2, y2 := c.ScalarMult(pub.X, pub.Y, t.Bytes())
+	x, _ := c.Add(x1, y1, x2, y2)
+	x.Add(x, e)
+	x.Mod(x, n)
+	return x.Cmp(rs.R) == 0
+}
+
+// sm2KDF GB/T 32918.4中基于SM3的密钥派生函数
+func sm2KDF(z []byte, length int) []byte {
+	out := make([]byte, 0, length+SM3Size)
+	var ct [4]byte
+	for i := uint32(1); len(out) < length; i++ {
+		binary.BigEndian.PutUint32(ct[:], i)
+		h := NewSM3()
+		h.Write(z)
+		h.Write(ct[:])
+		out = h.Sum(out)
+	}
+	return out[:length]
+}
+
+type sm2ASN1Cipher struct {
+	X, Y       *big.Int
+	Hash       []byte
+	CipherText []byte
+}
+
+// SM2Encrypt 使用SM2公钥加密
+func SM2Encrypt(pub *ecdsa.PublicKey, plaintext []byte, format SM2CipherFormat) ([]byte, error) {
+	if err := sm2CheckPublicKey(pub); err != nil {
+		return nil, err
+	}
+	if len(plaintext) == 0 {
+		return nil, errors.New("sm2: empty plaintext")
+	}
+	c := SM2P256()
+	for {
+		k, err := sm2RandScalar(c.Params().N)
+		if err != nil {
+			return nil, err
+		}
+		x1, y1 := c.ScalarBaseMult(sm2Bytes(k))
+		x2, y2 := c.ScalarMult(pub.X, pub.Y, sm2Bytes(k))
+		x2b, y2b := sm2Bytes(x2), sm2Bytes(y2)
+		t := sm2KDF(append(append([]byte(nil), x2b...), y2b...), len(plaintext))
+		if isZeroBytes(t) {
+			continue
+		}
+		c2 := make([]byte, len(plaintext))
+		for i := range c2 {
+			c2[i] = plaintext[i] ^ t[i]
+		}
+		h := NewSM3()
+		h.Write(x2b)
+		h.Write(plaintext)
+		h.Write(y2b)
+		c3 := h.Sum(nil)
+		switch format {
+		case SM2ASN1:
+			return asn1.Marshal(sm2ASN1Cipher{x1, y1, c3, c2})
+		case SM2C1C2C3:
+			c2, c3 = c3, c2
+		case SM2C1C3C2:
+		default:
+			return nil, fmt.Errorf("sm2: unknown ciphertext format %d", format)
+		}
+		out := make([]byte, 0, 65+len(c2)+len(c3))
+		out = append(out, 4)
+		out = append(out, sm2Bytes(x1)...)
+		out = append(out, sm2Bytes(y1)...)
+		out = append(out, c3...)
+		return append(out, c2...), nil
+	}
+}
+
+// SM2Decrypt 使用SM2私钥解密,format必须与加密时相同。不是常量时间的,参见SM2P256
+func SM2Decrypt(priv *ecdsa.PrivateKey, ciphertext []byte, format SM2CipherFormat) ([]byte, error) {
+	if err := sm2CheckPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	var x1, y1 *big.Int
+	var c2, c3 []byte
+	switch format {
+	case SM2ASN1:
+		var v sm2ASN1Cipher
+		if rest, err := asn1.Unmarshal(ciphertext, &v); err != nil || len(rest) != 0 {
+			return nil, ErrSM2Decrypt
+		}
+		x1, y1, c2, c3 = v.X, v.Y, v.CipherText, v.Hash
+	case SM2C1C3C2, SM2C1C2C3:
+		if len(ciphertext) <= 65+SM3Size || ciphertext[0] != 4 {
+			return nil, ErrSM2Decrypt
+		}
+		x1 = new(big.Int).SetBytes(ciphertext[1:33])
+		y1 = new(big.Int).SetBytes(ciphertext[33:65])
+		if format == SM2C1C3C2 {
+			c3, c2 = ciphertext[65:65+SM3Size], ciphertext[65+SM3Size:]
+		} else {
+			c2, c3 = ciphertext[65:len(ciphertext)-SM3Size], ciphertext[len(ciphertext)-SM3Size:]
+		}
+	default:
+		return nil, fmt.Errorf("sm2: unknown ciphertext format %d", format)
+	}
+	c := SM2P256()
+	if len(c2) == 0 || len(c3) != SM3Size || x1.Sign() < 0 || y1.Sign() < 0 || !c.IsOnCurve(x1, y1) {
+		return nil, ErrSM2Decrypt
+	}
+	x2, y2 := c.ScalarMult(x1, y1, sm2Bytes(priv.D))
+	x2b, y2b := sm2Bytes(x2), sm2Bytes(y2)
+	t := sm2KDF(append(append([]byte(nil), x2b...), y2b...), len(c2))
+	if isZeroBytes(t) {
+		return nil, ErrSM2Decrypt
+	}
+	plaintext := make([]byte, len(c2))
+	for i := range plaintext {
+		plaintext[i] = c2[i] ^ t[i]
+	}
+	h := NewSM3()
+	h.Write(x2b)
+	h.Write(plaintext)
+	h.Write(y2b)
+	if subtle.ConstantTimeCompare(h.Sum(nil), c3) != 1 {
+		return nil, ErrSM2Decrypt
+	}
+	return plaintext, nil
+}
+
+func isZeroBytes(b []byte) bool {
+	var v byte
+	for _, c := range b {
+		v |= c
+	}
+	return v == 0
+}
+
+type sm2PKIXPublicKey struct {
+	Algo      pkix.AlgorithmIdentifier
+	BitString asn1.BitString
+}
+
+type sm2PKCS8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// sm2ECPrivateKey RFC 5915 / SEC1 中的ECPrivateKey
+type sm2ECPrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+func sm2AlgorithmIdentifier() pkix.AlgorithmIdentifier {
+	param, _ := asn1.Marshal(oidSM2)
+	return pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: param}}
+}
+
+// sm2CheckAlgorithm 接受id-ecPublicKey加SM2曲线参数,也接受部分实现直接使用的SM2算法标识
+func sm2CheckAlgorithm(algo pkix.AlgorithmIdentifier) error {
+	if algo.Algorithm.Equal(oidSM2) {
+		return nil
+	}
+	var curve asn1.ObjectIdentifier
+	if !algo.Algorithm.Equal(oidPublicKeyECDSA) {
+		return fmt.Errorf("%w: unexpected algorithm %v", ErrSM2Key, algo.Algorithm)
+	}
+	if _, err := asn1.Unmarshal(algo.Parameters.FullBytes, &curve); err != nil || !curve.Equal(oidSM2) {
+		return fmt.Errorf("%w: not an SM2 curve", ErrSM2Key)
+	}
+	return nil
+}
+
+func sm2MarshalPoint(pub *ecdsa.PublicKey) []byte {
+	return append(append([]byte{4}, sm2Bytes(pub.X)...), sm2Bytes(pub.Y)...)
+}
+
+func sm2UnmarshalPoint(b []byte) (*ecdsa.PublicKey, error) {
+	if len(b) != 65 || b[0] != 4 {
+		return nil, fmt.Errorf("%w: only uncompressed points are supported", ErrSM2Key)
+	}
+	pub := &ecdsa.PublicKey{Curve: SM2P256(), X: new(big.Int).SetBytes(b[1:33]), Y: new(big.Int).SetBytes(b[33:])}
+	if err := sm2CheckPublicKey(pub); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// MarshalSM2PublicKey 将SM2公钥编码为PKIX(SubjectPublicKeyInfo) DER
+func MarshalSM2PublicKey(pub *ecdsa.PublicKey) ([]byte, error) {
+	if err := sm2CheckPublicKey(pub); err != nil {
+		return nil, err
+	}
+	point := sm2MarshalPoint(pub)
+	return asn1.Marshal(sm2PKIXPublicKey{
+		Algo:      sm2AlgorithmIdentifier(),
+		BitString: asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+}
+
+// ParseSM2PublicKey 解析PKIX DER编码的SM2公钥
+func ParseSM2PublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	var v sm2PKIXPublicKey
+	if rest, err := asn1.Unmarshal(der, &v); err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed public key", ErrSM2Key)
+	}
+	if err := sm2CheckAlgorithm(v.Algo); err != nil {
+		return nil, err
+	}
+	return sm2UnmarshalPoint(v.BitString.RightAlign())
+}
+
+// MarshalSM2PrivateKey 将SM2私钥编码为PKCS#8 DER,与OpenSSL的genpkey -algorithm SM2相同
+func MarshalSM2PrivateKey(priv *ecdsa.PrivateKey) ([]byte, error) {
+	if err := sm2CheckPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	point := sm2MarshalPoint(&priv.PublicKey)
+	inner, err := asn1.Marshal(sm2ECPrivateKey{
+		Version:    1,
+		PrivateKey: sm2Bytes(priv.D),
+		PublicKey:  asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(sm2PKCS8{Algo: sm2AlgorithmIdentifier(), PrivateKey: inner})
+}
+
+// ParseSM2PrivateKey 解析PKCS#8或SEC1(EC PRIVATE KEY) DER编码的SM2私钥
+func ParseSM2PrivateKey(der []byte) (*ecdsa.PrivateKey, error) {
+	var p8 sm2PKCS8
+	if rest, err := asn1.Unmarshal(der, &p8); err == nil && len(rest) == 0 {
+		if err := sm2CheckAlgorithm(p8.Algo); err != nil {
+			return nil, err
+		}
+		der = p8.PrivateKey
+	}
+	var ec sm2ECPrivateKey
+	if rest, err := asn1.Unmarshal(der, &ec); err != nil || len(rest) != 0 || ec.Version != 1 {
+		return nil, fmt.Errorf("%w: malformed private key", ErrSM2Key)
+	}
+	if ec.NamedCurveOID != nil && !ec.NamedCurveOID.Equal(oidSM2) {
+		return nil, fmt.Errorf("%w: not an SM2 curve", ErrSM2Key)
+	}
+	if len(ec.PrivateKey) > 32 {
+		return nil, fmt.Errorf("%w: private key too long", ErrSM2Key)
+	}
+	// 公钥由私钥重新计算,不使用编码中的公钥
+	priv := sm2PrivateKey(new(big.Int).SetBytes(ec.PrivateKey))
+	if err := sm2CheckPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+// SM2PrivateKeyToPEM 将SM2私钥编码为PEM格式的PKCS#8("PRIVATE KEY")
+func SM2PrivateKeyToPEM(priv *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := MarshalSM2PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// SM2PublicKeyToPEM 将SM2公钥编码为PEM格式("PUBLIC KEY")
+func SM2PublicKeyToPEM(pub *ecdsa.PublicKey) ([]byte, error) {
+	der, err := MarshalSM2PublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParseSM2PrivateKeyPEM 解析PEM格式的SM2私钥,支持"PRIVATE KEY"和"EC PRIVATE KEY"
+func ParseSM2PrivateKeyPEM(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || (block.Type != "PRIVATE KEY" && block.Type != "EC PRIVATE KEY") {
+		return nil, fmt.Errorf("%w: no private key PEM block", ErrSM2Key)
+	}
+	return ParseSM2PrivateKey(block.Bytes)
+}
+
+// ParseSM2PublicKeyPEM 解析PEM格式的SM2公钥
+func ParseSM2PublicKeyPEM(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("%w: no public key PEM block", ErrSM2Key)
+	}
+	return ParseSM2PublicKey(block.Bytes)
+}
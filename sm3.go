@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	// SM3Size SM3摘要长度
+	SM3Size = 32
+	// SM3BlockSize SM3分组长度
+	SM3BlockSize = 64
+)
+
+var sm3IV = [8]uint32{0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600, 0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e}
+
+// sm3 GB/T 32905-2016 杂凑算法
+type sm3 struct {
+	h   [8]uint32
+	x   [SM3BlockSize]byte
+	nx  int
+	len uint64
+}
+
+// NewSM3 创建SM3哈希,可用于hmac.New等需要hash.Hash的地方
+func NewSM3() hash.Hash {
+	d := new(sm3)
+	d.Reset()
+	return d
+}
+
+// SM3 计算SM3摘要
+func SM3(data []byte) []byte {
+	d := NewSM3()
+	d.Write(data)
+	return d.Sum(nil)
+}
+
+// HmacSM3 计算HMAC-SM3
+func HmacSM3(key, data []byte) []byte {
+	m := hmac.New(NewSM3, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func (d *sm3) Reset() {
+	d.h = sm3IV
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *sm3) Size() int { return SM3Size }
+
+func (d *sm3) BlockSize() int { return SM3BlockSize }
+
+func (d *sm3) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx < SM3BlockSize {
+			return n, nil
+		}
+		d.block(d.x[:])
+		d.nx = 0
+	}
+	for len(p) >= SM3BlockSize {
+		d.block(p[:SM3BlockSize])
+		p = p[SM3BlockSize:]
+	}
+	d.nx = copy(d.x[:], p)
+	return n, nil
+}
+
+func (d *sm3) Sum(in []byte) []byte {
+	// 在副本上填充,调用方可以继续写入
+	c := *d
+	length := c.len << 3
+	var pad [SM3BlockSize + 8]byte
+	pad[0] = 0x80
+	n := 56 - int(c.len%SM3BlockSize)
+	if n <= 0 {
+		n += SM3BlockSize
+	}
+	binary.BigEndian.PutUint64(pad[n:], length)
+	c.Write(pad[:n+8])
+	var out [SM3Size]byte
+	for i, v := range c.h {
+		binary.BigEndian.PutUint32(out[4*i:], v)
+	}
+	return append(in, out[:]...)
+}
+
+func (d *sm3) block(p []byte) {
+	var w [68]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[4*i:])
+	}
+	for j := 16; j < 68; j++ {
+		x := w[j-16] ^ w[j-9] ^ rotl32(w[j-3], 15)
+		w[j] = x ^ rotl32(x, 15) ^ rotl32(x, 23) ^ rotl32(w[j-13], 7) ^ w[j-6]
+	}
+	a, b, c, dd, e, f, g, h := d.h[0], d.h[1], d.h[2], d.h[3], d.h[4], d.h[5], d.h[6], d.h[7]
+	for j := 0; j < 64; j++ {
+		var t, ff, gg uint32
+		if j < 16 {
+			t = 0x79cc4519
+			ff = a ^ b ^ c
+			gg = e ^ f ^ g
+		} else {
+			t = 0x7a879d8a
+			ff = a&b | a&c | b&c
+			gg = e&f | ^e&g
+		}
+		ss1 := rotl32(rotl32(a, 12)+e+rotl32(t, uint(j%32)), 7)
+		ss2 := ss1 ^ rotl32(a, 12)
+		tt1 := ff + dd + ss2 + (w[j] ^ w[j+4])
+		tt2 := gg + h + ss1 + w[j]
+		dd, c, b, a = c, rotl32(b, 9), a, tt1
+		h, g, f = g, rotl32(f, 19), e
+		e = tt2 ^ rotl32(tt2, 9) ^ rotl32(tt2, 17)
+	}
+	d.h[0] ^= a
+	d.h[1] ^= b
+	d.h[2] ^= c
+	d.h[3] ^= dd
+	d.h[4] ^= e
+	d.h[5] ^= f
+	d.h[6] ^= g
+	d.h[7] ^= h
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSM3(t *testing.T) {
+	cases := map[string]string{
+		// GB/T 32905-2016 附录A
+		"abc":                      "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0",
+		strings.Repeat("abcd", 16): "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732",
+		// 与 openssl dgst -sm3 一致
+		"":                        "1ab21d8355cfa17f8e61194831e81a8f22bec8c728fefb747ed035eb5082aa2b",
+		strings.Repeat("a", 1000): "f4bedca973227d45c5b822551d2e762d4cfb0e9af70b241452545727b5fb046f",
+	}
+	for in, expected := range cases {
+		if got := hex.EncodeToString(SM3([]byte(in))); got != expected {
+			t.Errorf("SM3(%.16q):\n Expect => %s\n Got => %s\n", in, expected, got)
+		}
+		// 分多次写入
+		h := NewSM3()
+		for i := 0; i < len(in); i += 7 {
+			end := i + 7
+			if end > len(in) {
+				end = len(in)
+			}
+			h.Write([]byte(in[i:end]))
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != expected {
+			t.Errorf("NewSM3(%.16q):\n Expect => %s\n Got => %s\n", in, expected, got)
+		}
+	}
+
+	// openssl dgst -sm3 -hmac key
+	expected := "8d54716c0553990422ad09e63a7237386696a8952f64e546a4dee3b89a931a35"
+	if got := hex.EncodeToString(HmacSM3([]byte("key"), []byte("The quick brown fox"))); got != expected {
+		t.Errorf("HmacSM3:\n Expect => %s\n Got => %s\n", expected, got)
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestSM4Cipher(t *testing.T) {
+	// GB/T 32907-2016 附录A
+	key := mustHex("0123456789abcdeffedcba9876543210")
+	c, err := NewSM4Cipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make([]byte, 16)
+	c.Encrypt(dst, key)
+	if expected := "681edf34d206965e86b3e94f536e4246"; hex.EncodeToString(dst) != expected {
+		t.Errorf("SM4 Encrypt:\n Expect => %s\n Got => %x\n", expected, dst)
+	}
+	c.Decrypt(dst, dst)
+	if !bytes.Equal(dst, key) {
+		t.Errorf("SM4 Decrypt:\n Expect => %x\n Got => %x\n", key, dst)
+	}
+	copy(dst, key)
+	for i := 0; i < 1000000; i++ {
+		c.Encrypt(dst, dst)
+	}
+	if expected := "595298c7c6fd271f0402f804c33d3f66"; hex.EncodeToString(dst) != expected {
+		t.Errorf("SM4 Encrypt x1000000:\n Expect => %s\n Got => %x\n", expected, dst)
+	}
+	if _, err := NewSM4Cipher(key[:15]); err == nil {
+		t.Errorf("NewSM4Cipher(15 bytes):\n Expect => error\n Got => nil\n")
+	}
+}
+
+func TestSM4Modes(t *testing.T) {
+	key := []byte("1234567890abcdef")
+	iv := []byte("fedcba0987654321")
+	pt := []byte("hello, 世界! Go/Java/Python")
+	// openssl enc -sm4-cbc / -sm4-ecb
+	cbc, err := SM4EncryptCBC(pt, key, iv)
+	if expected := "837130a4193f8f2467212b2849bd41aacad51b3a4d4bd41f672b644926fce522"; err != nil || hex.EncodeToString(cbc) != expected {
+		t.Errorf("SM4EncryptCBC:\n Expect => %s\n Got => %x %v\n", expected, cbc, err)
+	}
+	if got, err := SM4DecryptCBC(cbc, key, iv); err != nil || !bytes.Equal(got, pt) {
+		t.Errorf("SM4DecryptCBC:\n Expect => %s\n Got => %s %v\n", pt, got, err)
+	}
+	ecb, err := SM4EncryptECB(pt, key)
+	if expected := "b95fa03d280efc73b65f2b03969f7a2addf474f32ebfb66eccbeda75f9eb47ff"; err != nil || hex.EncodeToString(ecb) != expected {
+		t.Errorf("SM4EncryptECB:\n Expect => %s\n Got => %x %v\n", expected, ecb, err)
+	}
+	if got, err := SM4DecryptECB(ecb, key); err != nil || !bytes.Equal(got, pt) {
+		t.Errorf("SM4DecryptECB:\n Expect => %s\n Got => %s %v\n", pt, got, err)
+	}
+	if _, err := SM4DecryptCBC(cbc[:17], key, iv); !errors.Is(err, ErrCiphertextLength) {
+		t.Errorf("SM4DecryptCBC(truncated):\n Expect => %v\n Got => %v\n", ErrCiphertextLength, err)
+	}
+	if _, err := SM4EncryptCBC(pt, key[:8], iv); err == nil {
+		t.Errorf("SM4EncryptCBC(short key):\n Expect => error\n Got => nil\n")
+	}
+}
+
+func TestSM4GCM(t *testing.T) {
+	// RFC 8998 附录A.1
+	key := mustHex("0123456789abcdeffedcba9876543210")
+	nonce := mustHex("00001234567800000000abcd")
+	aad := mustHex("feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	pt := mustHex("aaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbccccccccccccccccddddddddddddddddeeeeeeeeeeeeeeeeffffffffffffffffeeeeeeeeeeeeeeeeaaaaaaaaaaaaaaaa")
+	expected := "17f399f08c67d5ee19d0dc9969c4bb7d5fd46fd3756489069157b282bb200735d82710ca5c22f0ccfa7cbf93d496ac15a56834cbcf98c397b4024a2691233b8d" +
+		"83de3541e4c2b58177e065a9bf7b62ec"
+	gcm, err := NewSM4GCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gcm.Seal(nil, nonce, pt, aad); hex.EncodeToString(got) != expected {
+		t.Errorf("SM4-GCM Seal:\n Expect => %s\n Got => %x\n", expected, got)
+	}
+
+	data, err := SM4EncryptGCM([]byte("payload"), key, aad)
+	if err != nil || len(data) != 12+7+16 {
+		t.Fatalf("SM4EncryptGCM:\n Expect => nonce+ciphertext+tag\n Got => %x %v\n", data, err)
+	}
+	if got, err := SM4DecryptGCM(data, key, aad); err != nil || string(got) != "payload" {
+		t.Errorf("SM4DecryptGCM:\n Expect => payload\n Got => %s %v\n", got, err)
+	}
+	tampered := append([]byte(nil), data...)
+	tampered[20] ^= 1
+	for _, bad := range [][]byte{data[:27], tampered} {
+		if _, err := SM4DecryptGCM(bad, key, aad); !errors.Is(err, ErrAEADAuth) {
+			t.Errorf("SM4DecryptGCM(tampered):\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+		}
+	}
+	if _, err := SM4DecryptGCM(data, key, nil); !errors.Is(err, ErrAEADAuth) {
+		t.Errorf("SM4DecryptGCM(wrong aad):\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+	}
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// 分块流式加密的格式:
+//
+//	header: version(1字节) | algorithm(1字节) | chunk size(4字节,大端) | salt(16字节) | nonce prefix
+//	chunk:  ciphertext+tag,每块明文长度为chunk size,最后一块可以更短
+//
+// 每个流使用HKDF-SHA256(key, salt)派生独立的子密钥,头部的其余字段作为派生参数参与认证。
+// 第i块的nonce为 nonce prefix | i(4字节,大端) | 是否为最后一块(1字节),
+// 因此块被重排、删除或流被截断都会导致认证失败。
+const (
+	streamVersion    = 1
+	streamSaltSize   = 16
+	streamNonceTail  = 5
+	streamKDFLabel   = "utils stream encryption"
+	streamHeaderBase = 6 + streamSaltSize
+
+	// DefaultStreamChunkSize 默认的分块大小
+	DefaultStreamChunkSize = 64 * KByte
+	// MaxStreamChunkSize 分块大小的上限,解密时也用于限制内存占用
+	MaxStreamChunkSize = 16 * MByte
+)
+
+// streamCipher 根据头部派生子密钥
+func streamCipher(key, header []byte) (cipher.AEAD, []byte, error) {
+	alg := AEADAlgorithm(header[1])
+	nonceSize, _ := aeadSizes(alg)
+	salt := header[6:streamHeaderBase]
+	info := append([]byte(streamKDFLabel), header[:6]...)
+	subkey, err := HKDF(key, salt, info, len(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newCipherAEAD(alg, subkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	copy(nonce, header[streamHeaderBase:])
+	return aead, nonce, nil
+}
+
+// setStreamNonce 写入块序号和最后一块标记
+func setStreamNonce(nonce []byte, counter uint32, last bool) {
+	tail := nonce[len(nonce)-streamNonceTail:]
+	binary.BigEndian.PutUint32(tail, counter)
+	tail[4] = 0
+	if last {
+		tail[4] = 1
+	}
+}
+
+var errStreamClosed = errors.New("aead: write to closed stream")
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte
+	out     []byte
+	err     error
+}
+
+// NewEncryptWriter 返回分块认证加密的io.WriteCloser,写入的明文加密后写入w,分块大小为DefaultStreamChunkSize。
+// 必须调用Close写入最后一块,Close不会关闭w。
+func NewEncryptWriter(w io.Writer, alg AEADAlgorithm, key, aad []byte) (io.WriteCloser, error) {
+	return NewEncryptWriterSize(w, alg, key, aad, DefaultStreamChunkSize)
+}
+
+// NewEncryptWriterSize 与NewEncryptWriter相同,可以指定分块大小
+func NewEncryptWriterSize(w io.Writer, alg AEADAlgorithm, key, aad []byte, chunkSize int) (io.WriteCloser, error) {
+	if chunkSize <= 0 || chunkSize > MaxStreamChunkSize {
+		return nil, fmt.Errorf("aead: chunk size must be between 1 and %d", MaxStreamChunkSize)
+	}
+	nonceSize, overhead := aeadSizes(alg)
+	if nonceSize == 0 {
+		return nil, fmt.Errorf("aead: unknown algorithm %s", alg)
+	}
+	header := make([]byte, streamHeaderBase+nonceSize-streamNonceTail)
+	header[0], header[1] = streamVersion, byte(alg)
+	binary.BigEndian.PutUint32(header[2:6], uint32(chunkSize))
+	if _, err := io.ReadFull(randReader, header[6:]); err != nil {
+		return nil, err
+	}
+	aead, nonce, err := streamCipher(key, header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:     w,
+		aead:  aead,
+		aad:   aad,
+		nonce: nonce,
+		buf:   make([]byte, 0, chunkSize),
+		out:   make([]byte, 0, chunkSize+overhead),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	total := len(p)
+	for len(p) > 0 {
+		// 缓冲区满且还有数据时才写出,保证最后一块留到Close
+		if len(e.buf) == cap(e.buf) {
+			if e.err = e.flush(false); e.err != nil {
+				return total - len(p), e.err
+			}
+		}
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (e *encryptWriter) flush(last bool) error {
+	if !last && e.counter == math.MaxUint32 {
+		return errors.New("aead: stream too long")
+	}
+	setStreamNonce(e.nonce, e.counter, last)
+	e.out = e.aead.Seal(e.out[:0], e.nonce, e.buf, e.aad)
+	if _, err := e.w.Write(e.out); err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Close 加密并写出最后一块,重复调用返回nil
+func (e *encryptWriter) Close() error {
+	if e.err == errStreamClosed {
+		return nil
+	}
+	if e.err != nil {
+		return e.err
+	}
+	if e.err = e.flush(true); e.err != nil {
+		return e.err
+	}
+	e.err = errStreamClosed
+	return nil
+}
+
+type decryptReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte
+	n       int
+	plain   []byte
+	out     []byte
+	err     error
+}
+
+// NewDecryptReader 返回解密NewEncryptWriter输出的io.Reader,算法和分块大小从头部读取。
+// 每一块在认证通过后才会返回,块被篡改或流被截断时Read返回包装了ErrAEADAuth的错误,
+// 此前已返回的数据属于已认证的前缀,调用方需要在读到io.EOF后才能认为数据完整。
+func NewDecryptReader(r io.Reader, key, aad []byte) (io.Reader, error) {
+	header := make([]byte, streamHeaderBase)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAEADEnvelope, err)
+	}
+	if header[0] != streamVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrAEADEnvelope, header[0])
+	}
+	nonceSize, overhead := aeadSizes(AEADAlgorithm(header[1]))
+	if nonceSize == 0 {
+		return nil, fmt.Errorf("%w: unknown algorithm %d", ErrAEADEnvelope, header[1])
+	}
+	chunkSize := int(binary.BigEndian.Uint32(header[2:6]))
+	if chunkSize <= 0 || chunkSize > MaxStreamChunkSize {
+		return nil, fmt.Errorf("%w: invalid chunk size %d", ErrAEADEnvelope, chunkSize)
+	}
+	header = append(header, make([]byte, nonceSize-streamNonceTail)...)
+	if _, err := io.ReadFull(r, header[streamHeaderBase:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAEADEnvelope, err)
+	}
+	aead, nonce, err := streamCipher(key, header)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:     r,
+		aead:  aead,
+		aad:   aad,
+		nonce: nonce,
+		buf:   make([]byte, chunkSize+overhead+1),
+		out:   make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.readChunk()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// readChunk 读取并解密一块。多读一个字节用于判断当前块是否为最后一块
+func (d *decryptReader) readChunk() error {
+	n, err := io.ReadFull(d.r, d.buf[d.n:])
+	d.n += n
+	last := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !last {
+		return err
+	}
+	size := len(d.buf) - 1
+	if last {
+		size = d.n
+	}
+	if size < d.aead.Overhead() {
+		return fmt.Errorf("%w: truncated stream", ErrAEADAuth)
+	}
+	setStreamNonce(d.nonce, d.counter, last)
+	d.plain, err = d.aead.Open(d.out[:0], d.nonce, d.buf[:size], d.aad)
+	if err != nil {
+		return ErrAEADAuth
+	}
+	if last {
+		return io.EOF
+	}
+	d.buf[0] = d.buf[size]
+	d.n = 1
+	d.counter++
+	return nil
+}
+
+// EncryptStream 使用AES-GCM分块加密src并写入dst,key为16、24或32字节
+func EncryptStream(dst io.Writer, src io.Reader, key, aad []byte) error {
+	w, err := NewEncryptWriter(dst, AEADAESGCM, key, aad)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// DecryptStream 解密EncryptStream或NewEncryptWriter的输出并写入dst
+func DecryptStream(dst io.Writer, src io.Reader, key, aad []byte) error {
+	r, err := NewDecryptReader(src, key, aad)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+// EncryptFile 使用AES-GCM分块加密文件,不会把整个文件读入内存
+func EncryptFile(src, dest string, key []byte) error {
+	return streamFile(src, dest, func(w io.Writer, r io.Reader) error {
+		return EncryptStream(w, r, key, nil)
+	})
+}
+
+// DecryptFile 解密EncryptFile生成的文件,目标文件原子写入,解密失败时不会留下部分明文
+func DecryptFile(src, dest string, key []byte) error {
+	return streamFile(src, dest, func(w io.Writer, r io.Reader) error {
+		return DecryptStream(w, r, key, nil)
+	})
+}
+
+func streamFile(src, dest string, fn func(io.Writer, io.Reader) error) error {
+	sr, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sr.Close()
+	dw, err := NewAtomicFile(dest, AtomicOptions{})
+	if err != nil {
+		return err
+	}
+	defer dw.Close()
+	if err = fn(dw, sr); err != nil {
+		return err
+	}
+	return dw.Commit()
+}
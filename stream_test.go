@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func streamEncrypt(t *testing.T, alg AEADAlgorithm, key, aad, data []byte, chunkSize int) []byte {
+	var buf bytes.Buffer
+	w, err := NewEncryptWriterSize(&buf, alg, key, aad, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分多次写入,覆盖跨块的情况
+	for len(data) > 0 {
+		n := 7
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err = w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	aad := []byte("file:42")
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, alg := range []AEADAlgorithm{AEADAESGCM, AEADChaCha20Poly1305, AEADXChaCha20Poly1305} {
+		for _, n := range []int{0, 1, 31, 32, 33, 64, 200} {
+			ct := streamEncrypt(t, alg, key, aad, data[:n], 32)
+			nonceSize, overhead := aeadSizes(alg)
+			chunks := n/32 + 1
+			if n > 0 && n%32 == 0 {
+				chunks--
+			}
+			if expected := streamHeaderBase + nonceSize - streamNonceTail + n + chunks*overhead; len(ct) != expected {
+				t.Errorf("NewEncryptWriter(%s, %d):\n Expect => %d bytes\n Got => %d\n", alg, n, expected, len(ct))
+			}
+			r, err := NewDecryptReader(iotest.OneByteReader(bytes.NewReader(ct)), key, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(got, data[:n]) {
+				t.Errorf("NewDecryptReader(%s, %d):\n Expect => %x\n Got => %x %v\n", alg, n, data[:n], got, err)
+			}
+		}
+	}
+
+	var dst bytes.Buffer
+	ct := streamEncrypt(t, AEADAESGCM, key[:16], nil, data, DefaultStreamChunkSize)
+	if err := DecryptStream(&dst, bytes.NewReader(ct), key[:16], nil); err != nil || !bytes.Equal(dst.Bytes(), data) {
+		t.Errorf("DecryptStream:\n Expect => %x\n Got => %x %v\n", data, dst.Bytes(), err)
+	}
+}
+
+func TestStreamTamper(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	ct := streamEncrypt(t, AEADAESGCM, key, nil, data, 32)
+	header := streamHeaderBase + 12 - streamNonceTail
+	chunk := 32 + 16
+
+	decrypt := func(ct, key, aad []byte) ([]byte, error) {
+		r, err := NewDecryptReader(bytes.NewReader(ct), key, aad)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	}
+	if _, err := decrypt(ct, key, []byte("aad")); !errors.Is(err, ErrAEADAuth) {
+		t.Errorf("aad mismatch:\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+	}
+	if _, err := decrypt(ct, bytes.Repeat([]byte{8}, 32), nil); !errors.Is(err, ErrAEADAuth) {
+		t.Errorf("wrong key:\n Expect => %v\n Got => %v\n", ErrAEADAuth, err)
+	}
+	cases := map[string][]byte{
+		"truncated at chunk": ct[:header+2*chunk],
+		"truncated in chunk": ct[:len(ct)-1],
+		"dropped last chunk": ct[:header+3*chunk],
+		"trailing data":      append(append([]byte(nil), ct...), 0),
+		"swapped chunks":     append(append(append(append([]byte(nil), ct[:header]...), ct[header+chunk:header+2*chunk]...), ct[header:header+chunk]...), ct[header+2*chunk:]...),
+	}
+	for _, i := range []int{0, 1, 5, 10, header - 1, header, len(ct) - 1} {
+		tampered := append([]byte(nil), ct...)
+		tampered[i] ^= 1
+		cases[fmt.Sprintf("flipped byte %d", i)] = tampered
+	}
+	for name, c := range cases {
+		got, err := decrypt(c, key, nil)
+		if !errors.Is(err, ErrAEADAuth) && !errors.Is(err, ErrAEADEnvelope) {
+			t.Errorf("%s:\n Expect => %v\n Got => %v\n", name, ErrAEADAuth, err)
+		}
+		if !bytes.HasPrefix(data, got) {
+			t.Errorf("%s:\n Expect => authenticated prefix\n Got => %x\n", name, got)
+		}
+	}
+
+	if _, err := NewEncryptWriterSize(ioutil.Discard, AEADAESGCM, key, nil, 0); err == nil {
+		t.Errorf("chunk size 0:\n Expect => error\n Got => nil\n")
+	}
+	if _, err := NewEncryptWriter(ioutil.Discard, AEADAESGCM, key[:15], nil); err == nil {
+		t.Errorf("key size:\n Expect => error\n Got => nil\n")
+	}
+	w, _ := NewEncryptWriter(ioutil.Discard, AEADAESGCM, key, nil)
+	w.Close()
+	if _, err := w.Write([]byte("x")); err == nil || w.Close() != nil {
+		t.Errorf("write after close:\n Expect => error\n Got => %v\n", err)
+	}
+}
+
+func TestStreamFormat(t *testing.T) {
+	old := randReader
+	defer func() { randReader = old }()
+	randReader = bytes.NewReader(make([]byte, 64))
+	var buf bytes.Buffer
+	if err := EncryptStream(&buf, bytes.NewReader([]byte("hello")), make([]byte, 16), nil); err != nil {
+		t.Fatal(err)
+	}
+	// version 1 | AES-GCM | 64KiB | salt | nonce prefix | ciphertext+tag
+	// 子密钥与 openssl kdf -keylen 16 -kdfopt digest:SHA256 ... HKDF 的结果相同
+	expected := "0101" + "00010000" + strings.Repeat("00", 16) + strings.Repeat("00", 7) + "f3e041d77a4a97f1688c572e2dff61d54d040a27fd"
+	if got := hex.EncodeToString(buf.Bytes()); got != expected {
+		t.Errorf("EncryptStream:\n Expect => %s\n Got => %s\n", expected, got)
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	dir := t.TempDir()
+	src, enc, dec := filepath.Join(dir, "src"), filepath.Join(dir, "enc"), filepath.Join(dir, "dec")
+	data := bytes.Repeat([]byte("large file "), 20000)
+	if err := ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{9}, 32)
+	if err := EncryptFile(src, enc, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile(enc, dec, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(dec); !bytes.Equal(got, data) {
+		t.Errorf("DecryptFile:\n Expect => %d bytes\n Got => %d bytes\n", len(data), len(got))
+	}
+	if err := DecryptFile(enc, filepath.Join(dir, "bad"), key[:16]); !errors.Is(err, ErrAEADAuth) || IsExist(filepath.Join(dir, "bad")) {
+		t.Errorf("DecryptFile(wrong key):\n Expect => %v and no file\n Got => %v\n", ErrAEADAuth, err)
+	}
+}
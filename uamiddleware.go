@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// 设备类型
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// NameVersion 名称和版本
+type NameVersion struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// UserAgentInfo User-Agent解析结果的公开结构,可直接序列化到日志或放入请求上下文。
+type UserAgentInfo struct {
+	UA           string      `json:"ua"`
+	Mozilla      string      `json:"mozilla,omitempty"`
+	Browser      NameVersion `json:"browser"`
+	Engine       NameVersion `json:"engine"`
+	OS           OSInfo      `json:"os"`
+	Platform     string      `json:"platform,omitempty"`
+	Localization string      `json:"localization,omitempty"`
+	Device       string      `json:"device"`
+	Mobile       bool        `json:"mobile"`
+	Bot          *BotInfo    `json:"bot,omitempty"`
+}
+
+// IsBot 是否为爬虫
+func (i UserAgentInfo) IsBot() bool {
+	return i.Bot != nil
+}
+
+// Info 导出解析结果
+func (p *UserAgent) Info() UserAgentInfo {
+	info := UserAgentInfo{
+		UA:           p.ua,
+		Mozilla:      p.mozilla,
+		Browser:      NameVersion{Name: p.browser.Name, Version: p.browser.Version},
+		Engine:       NameVersion{Name: p.browser.Engine, Version: p.browser.EngineVersion},
+		OS:           p.OSInfo(),
+		Platform:     p.platform,
+		Localization: p.localization,
+		Device:       p.device(),
+		Mobile:       p.mobile,
+	}
+	if p.bot {
+		bot := p.botInfo
+		info.Bot = &bot
+	}
+	return info
+}
+
+// MarshalJSON 按UserAgentInfo的结构序列化
+func (p *UserAgent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Info())
+}
+
+// device 根据已解析的信息推断设备类型
+func (p *UserAgent) device() string {
+	switch {
+	case p.bot:
+		return DeviceBot
+	case p.platform == "iPad" || strings.Contains(p.ua, "Tablet"):
+		return DeviceTablet
+	case p.mobile:
+		return DeviceMobile
+	}
+	return DeviceDesktop
+}
+
+type userAgentContextKey struct{}
+
+// NewUserAgentContext 将解析结果存入上下文
+func NewUserAgentContext(ctx context.Context, ua *UserAgent) context.Context {
+	return context.WithValue(ctx, userAgentContextKey{}, ua)
+}
+
+// UserAgentFromContext 取出UserAgentMiddleware存入的解析结果,返回的对象只读。
+func UserAgentFromContext(ctx context.Context) (*UserAgent, bool) {
+	ua, ok := ctx.Value(userAgentContextKey{}).(*UserAgent)
+	return ua, ok
+}
+
+// UserAgentMiddleware 每个请求只解析一次User-Agent并存入请求上下文。
+// parser为nil时不使用缓存。
+func UserAgentMiddleware(parser *UAParser) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := UserAgentFromContext(r.Context()); !ok {
+				r = r.WithContext(NewUserAgentContext(r.Context(), parseRequestUA(parser, r)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func parseRequestUA(parser *UAParser, r *http.Request) *UserAgent {
+	if parser != nil {
+		return parser.Parse(r.UserAgent())
+	}
+	return New(r.UserAgent())
+}
+
+// ClientFingerprint 客户端指纹,由客户端IP、User-Agent和语言等请求特征组成。
+type ClientFingerprint struct {
+	IP             string        `json:"ip"`
+	AcceptLanguage string        `json:"accept_language,omitempty"`
+	UserAgent      UserAgentInfo `json:"user_agent"`
+	Hash           string        `json:"hash"`
+}
+
+// Fingerprint 生成请求的客户端指纹,优先使用上下文中已解析的User-Agent。
+//
+// 客户端IP由SetTrustedProxies设置的解析器解析,未设置时使用RemoteAddr,不读取可被伪造的请求头。
+// Hash为IP、原始User-Agent和Accept-Language的SHA-256十六进制摘要,
+// 同一客户端的请求得到相同的值。
+func Fingerprint(r *http.Request) ClientFingerprint {
+	return FingerprintWith(r, DefaultIPResolver())
+}
+
+// FingerprintWith 与Fingerprint相同,使用resolver解析客户端IP,resolver为nil时使用RemoteAddr。
+func FingerprintWith(r *http.Request, resolver *IPResolver) ClientFingerprint {
+	ua, ok := UserAgentFromContext(r.Context())
+	if !ok {
+		ua = New(r.UserAgent())
+	}
+	fp := ClientFingerprint{
+		IP:             requestIP(r, resolver),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		UserAgent:      ua.Info(),
+	}
+	h := sha256.New()
+	h.Write([]byte(fp.IP))
+	h.Write([]byte{0})
+	h.Write([]byte(ua.UA()))
+	h.Write([]byte{0})
+	h.Write([]byte(fp.AcceptLanguage))
+	fp.Hash = hex.EncodeToString(h.Sum(nil))
+	return fp
+}
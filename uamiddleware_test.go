@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserAgentInfoJSON(t *testing.T) {
+	ua := New("Mozilla/5.0 (iPhone; CPU iPhone OS 7_0_3 like Mac OS X) AppleWebKit/537.51.1 (KHTML, like Gecko) Version/7.0 Mobile/11B511 Safari/9537.53")
+	data, err := json.Marshal(ua)
+	if err != nil {
+		t.Fatalf("MarshalJSON:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	var info UserAgentInfo
+	if err = json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("UnmarshalJSON:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	if info.Browser.Name != "Safari" || info.OS.Name != "iPhone OS" || info.Device != DeviceMobile || !info.Mobile || info.IsBot() {
+		t.Errorf("UserAgentInfo:\n Expect => Safari/iPhone OS/mobile\n Got => %s\n", data)
+	}
+
+	data, _ = json.Marshal(New("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"))
+	info = UserAgentInfo{}
+	json.Unmarshal(data, &info)
+	if !info.IsBot() || info.Bot.Category != BotSearchEngine || info.Device != DeviceBot {
+		t.Errorf("UserAgentInfo:\n Expect => search-engine bot\n Got => %s\n", data)
+	}
+}
+
+func TestUserAgentMiddleware(t *testing.T) {
+	const chrome = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/55.0.2883.75 Safari/537.36"
+	var got *UserAgent
+	var fp ClientFingerprint
+	h := UserAgentMiddleware(NewUAParser(16))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = UserAgentFromContext(r.Context())
+		fp = Fingerprint(r)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.10:5000"
+	r.Header.Set("User-Agent", chrome)
+	r.Header.Set("Accept-Language", "zh-CN")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got == nil {
+		t.Fatalf("UserAgentFromContext:\n Expect => parsed\n Got => %v\n", got)
+	}
+	if name, _ := got.Browser(); name != "Chrome" {
+		t.Errorf("UserAgentFromContext:\n Expect => %s\n Got => %s\n", "Chrome", name)
+	}
+	if fp.IP != "192.0.2.10" || fp.UserAgent.Browser.Name != "Chrome" || len(fp.Hash) != 64 {
+		t.Errorf("Fingerprint:\n Expect => 192.0.2.10 Chrome\n Got => %+v\n", fp)
+	}
+
+	// 不经过中间件时也能生成相同的指纹。
+	if direct := Fingerprint(r); direct.Hash != fp.Hash {
+		t.Errorf("Fingerprint:\n Expect => %s\n Got => %s\n", fp.Hash, direct.Hash)
+	}
+	r.Header.Set("Accept-Language", "en-US")
+	if other := Fingerprint(r); other.Hash == fp.Hash {
+		t.Errorf("Fingerprint:\n Expect => different hash\n Got => %s\n", other.Hash)
+	}
+
+	// 客户端伪造的X-Forwarded-For不影响指纹
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Real-IP", "198.51.100.1")
+	if spoofed := Fingerprint(r); spoofed.IP != "192.0.2.10" {
+		t.Errorf("Fingerprint(spoofed):\n Expect => 192.0.2.10\n Got => %s\n", spoofed.IP)
+	}
+	resolver, _ := NewIPResolver("192.0.2.0/24")
+	if proxied := FingerprintWith(r, resolver); proxied.IP != "198.51.100.1" {
+		t.Errorf("FingerprintWith:\n Expect => 198.51.100.1\n Got => %s\n", proxied.IP)
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// uaParserShards 缓存分片数,降低并发访问时的锁竞争
+	uaParserShards = 16
+	// uaParserShardMin 容量小于该值时只使用一个分片,保证严格的LRU
+	uaParserShardMin = 1024
+)
+
+// UAParser 带LRU缓存的User-Agent解析器,可被多个goroutine并发使用。
+//
+// 实际流量中User-Agent高度重复,命中缓存时解析只需一次哈希查找。
+type UAParser struct {
+	hits   uint64 // 原子操作的字段放在开头以保证64位对齐
+	misses uint64
+	seed   maphash.Seed
+	shards []uaShard
+}
+
+// uaShard 一个独立加锁的LRU分片
+type uaShard struct {
+	sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// UAParserStats 缓存命中统计
+type UAParserStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+// NewUAParser 创建最多缓存capacity个解析结果的解析器,capacity<=0时不缓存。
+//
+// capacity不小于1024时缓存分为16个独立淘汰的分片,总数仍不超过capacity,
+// 但淘汰只在分片内进行,某个分片较热时可能在整个缓存未满前就开始淘汰。
+func NewUAParser(capacity int) *UAParser {
+	n := 1
+	if capacity >= uaParserShardMin {
+		n = uaParserShards
+	}
+	p := &UAParser{seed: maphash.MakeSeed(), shards: make([]uaShard, n)}
+	for i := range p.shards {
+		if capacity > 0 {
+			p.shards[i].capacity = capacity / n
+			if i < capacity%n {
+				p.shards[i].capacity++
+			}
+		}
+		p.shards[i].ll = list.New()
+		p.shards[i].items = make(map[string]*list.Element)
+	}
+	return p
+}
+
+// Parse 解析给定的User-Agent字符串。
+//
+// 每次返回缓存结果的副本,调用方修改或再对其调用Parse不会影响缓存和其他调用方。
+func (p *UAParser) Parse(ua string) *UserAgent {
+	shard := p.shard(ua)
+	if shard.capacity <= 0 {
+		atomic.AddUint64(&p.misses, 1)
+		return New(ua)
+	}
+	shard.Lock()
+	if e, ok := shard.items[ua]; ok {
+		shard.ll.MoveToFront(e)
+		shard.Unlock()
+		atomic.AddUint64(&p.hits, 1)
+		return copyUserAgent(e.Value.(*UserAgent))
+	}
+	shard.Unlock()
+	atomic.AddUint64(&p.misses, 1)
+
+	// 解析在锁外进行,并发解析同一字符串时以先写入缓存的结果为准。
+	parsed := New(ua)
+	shard.Lock()
+	defer shard.Unlock()
+	if e, ok := shard.items[ua]; ok {
+		shard.ll.MoveToFront(e)
+		return copyUserAgent(e.Value.(*UserAgent))
+	}
+	shard.items[ua] = shard.ll.PushFront(parsed)
+	if shard.ll.Len() > shard.capacity {
+		oldest := shard.ll.Back()
+		shard.ll.Remove(oldest)
+		delete(shard.items, oldest.Value.(*UserAgent).ua)
+	}
+	return copyUserAgent(parsed)
+}
+
+// copyUserAgent UserAgent只包含字符串和值类型字段,浅拷贝即可与缓存完全分离
+func copyUserAgent(ua *UserAgent) *UserAgent {
+	cp := *ua
+	return &cp
+}
+
+// Stats 返回缓存命中统计
+func (p *UAParser) Stats() UAParserStats {
+	s := UAParserStats{Hits: atomic.LoadUint64(&p.hits), Misses: atomic.LoadUint64(&p.misses)}
+	for i := range p.shards {
+		p.shards[i].Lock()
+		s.Len += p.shards[i].ll.Len()
+		p.shards[i].Unlock()
+	}
+	return s
+}
+
+// Purge 清空缓存
+func (p *UAParser) Purge() {
+	for i := range p.shards {
+		p.shards[i].Lock()
+		p.shards[i].ll.Init()
+		p.shards[i].items = make(map[string]*list.Element)
+		p.shards[i].Unlock()
+	}
+}
+
+func (p *UAParser) shard(ua string) *uaShard {
+	if len(p.shards) == 1 {
+		return &p.shards[0]
+	}
+	var h maphash.Hash
+	h.SetSeed(p.seed)
+	h.WriteString(ua)
+	return &p.shards[h.Sum64()%uint64(len(p.shards))]
+}
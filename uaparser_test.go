@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestUAParser(t *testing.T) {
+	p := NewUAParser(64)
+	for _, tt := range uaStrings {
+		if got := beautify(p.Parse(tt.ua)); got != tt.expected {
+			t.Errorf("UAParser.Parse(%q):\n Expect => %s\n Got => %s\n", tt.title, tt.expected, got)
+		}
+	}
+	// 最近解析的10个应来自缓存,结果与首次解析一致。
+	before := p.Stats()
+	if before.Len != 64 {
+		t.Errorf("UAParser.Stats:\n Expect => len 64\n Got => %+v\n", before)
+	}
+	for _, tt := range uaStrings[len(uaStrings)-10:] {
+		if got := beautify(p.Parse(tt.ua)); got != tt.expected {
+			t.Errorf("UAParser.Parse(%q):\n Expect => %s\n Got => %s\n", tt.title, tt.expected, got)
+		}
+	}
+	after := p.Stats()
+	if after.Hits-before.Hits != 10 || after.Len != 64 {
+		t.Errorf("UAParser.Stats:\n Expect => 10 hits, len 64\n Got => %+v\n", after)
+	}
+	// 调用方修改返回的对象不影响缓存
+	tt := uaStrings[len(uaStrings)-1]
+	p.Parse(tt.ua).Parse(uaStrings[0].ua)
+	if got := beautify(p.Parse(tt.ua)); got != tt.expected {
+		t.Errorf("UAParser.Parse(%q, after caller mutation):\n Expect => %s\n Got => %s\n", tt.title, tt.expected, got)
+	}
+	p.Purge()
+	if s := p.Stats(); s.Len != 0 {
+		t.Errorf("UAParser.Purge:\n Expect => %d\n Got => %d\n", 0, s.Len)
+	}
+}
+
+func TestUAParserEviction(t *testing.T) {
+	for _, capacity := range []int{1, uaParserShards, uaParserShardMin + 7} {
+		p := NewUAParser(capacity)
+		for i := 0; i < 3000; i++ {
+			p.Parse(fmt.Sprintf("curl/7.%d.0", i))
+		}
+		if s := p.Stats(); s.Len > capacity || s.Misses != 3000 {
+			t.Errorf("UAParser.Stats(%d):\n Expect => len <= %d, misses 3000\n Got => %+v\n", capacity, capacity, s)
+		}
+	}
+	// 单分片时严格按LRU淘汰
+	p := NewUAParser(2)
+	p.Parse("a/1")
+	p.Parse("b/1")
+	p.Parse("a/1")
+	p.Parse("c/1")
+	before := p.Stats()
+	p.Parse("a/1")
+	if p.Stats().Hits != before.Hits+1 {
+		t.Errorf("UAParser(LRU):\n Expect => a/1 cached\n Got => %+v\n", p.Stats())
+	}
+	if ua := p.Parse("curl/7.999.0"); ua.UA() != "curl/7.999.0" {
+		t.Errorf("UAParser.Parse:\n Expect => %s\n Got => %s\n", "curl/7.999.0", ua.UA())
+	}
+}
+
+func TestUAParserNoCache(t *testing.T) {
+	p := NewUAParser(0)
+	p.Parse(uaStrings[0].ua)
+	p.Parse(uaStrings[0].ua)
+	if s := p.Stats(); s.Hits != 0 || s.Len != 0 {
+		t.Errorf("UAParser.Stats:\n Expect => no cache\n Got => %+v\n", s)
+	}
+}
+
+func TestUAParserConcurrent(t *testing.T) {
+	p := NewUAParser(32)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, tt := range uaStrings {
+				if got := beautify(p.Parse(tt.ua)); got != tt.expected {
+					t.Errorf("UAParser.Parse(%q):\n Expect => %s\n Got => %s\n", tt.title, tt.expected, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkUAParserHit(b *testing.B) {
+	p := NewUAParser(1024)
+	for _, tt := range uaStrings {
+		p.Parse(tt.ua)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Parse(uaStrings[i%len(uaStrings)].ua)
+	}
+}
+
+func BenchmarkUAParserParallel(b *testing.B) {
+	p := NewUAParser(1024)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			p.Parse(uaStrings[i%len(uaStrings)].ua)
+			i++
+		}
+	})
+}
+
+func BenchmarkUAParserMiss(b *testing.B) {
+	p := NewUAParser(0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Parse(uaStrings[i%len(uaStrings)].ua)
+	}
+}
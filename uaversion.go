@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version 解析后的版本号,可进行比较。例如"14.1.2"解析为[14 1 2]。
+type Version struct {
+	Segments []int
+	Raw      string
+}
+
+// ParseVersion 宽松地解析版本号:支持"."和"_"分隔,遇到非数字字符时停止,
+// 例如"10_15_7"解析为10.15.7,"9.0b2"解析为9.0。
+func ParseVersion(s string) Version {
+	v := Version{Raw: s}
+	n, digits := 0, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+			digits = true
+		case (c == '.' || c == '_') && digits:
+			v.Segments = append(v.Segments, n)
+			n, digits = 0, false
+		default:
+			if digits {
+				v.Segments = append(v.Segments, n)
+			}
+			return v
+		}
+	}
+	if digits {
+		v.Segments = append(v.Segments, n)
+	}
+	return v
+}
+
+// IsZero 是否未解析出任何版本号
+func (v Version) IsZero() bool {
+	return len(v.Segments) == 0
+}
+
+// Major 主版本号
+func (v Version) Major() int {
+	return v.segment(0)
+}
+
+// Minor 次版本号
+func (v Version) Minor() int {
+	return v.segment(1)
+}
+
+// Patch 修订号
+func (v Version) Patch() int {
+	return v.segment(2)
+}
+
+func (v Version) segment(i int) int {
+	if i < len(v.Segments) {
+		return v.Segments[i]
+	}
+	return 0
+}
+
+// Compare 比较两个版本,v小于、等于、大于o时分别返回-1、0、1,缺少的段按0处理。
+func (v Version) Compare(o Version) int {
+	n := len(v.Segments)
+	if len(o.Segments) > n {
+		n = len(o.Segments)
+	}
+	for i := 0; i < n; i++ {
+		a, b := v.segment(i), o.segment(i)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	}
+	return 0
+}
+
+// truncate 只保留前n段,用于"Safari 14"这种只给出部分版本号的比较。
+func (v Version) truncate(n int) Version {
+	if len(v.Segments) > n {
+		v.Segments = v.Segments[:n]
+	}
+	return v
+}
+
+func (v Version) String() string {
+	if v.IsZero() {
+		return v.Raw
+	}
+	parts := make([]string, len(v.Segments))
+	for i, s := range v.Segments {
+		parts[i] = strconv.Itoa(s)
+	}
+	return strings.Join(parts, ".")
+}
+
+// BrowserVersion 浏览器版本
+func (p *UserAgent) BrowserVersion() Version {
+	return ParseVersion(p.browser.Version)
+}
+
+// EngineVersion 引擎版本
+func (p *UserAgent) EngineVersion() Version {
+	return ParseVersion(p.browser.EngineVersion)
+}
+
+// OSVersion 操作系统版本
+func (p *UserAgent) OSVersion() Version {
+	return ParseVersion(p.OSInfo().Version)
+}
+
+// FirefoxESRVersions 仍在维护的Firefox ESR主版本,供"Firefox ESR"查询使用,可按需更新。
+var FirefoxESRVersions = []int{115, 128, 140}
+
+// ErrInvalidUAQuery 查询语句格式错误
+var ErrInvalidUAQuery = errors.New("invalid user agent query")
+
+// uaBrowserAliases 查询中允许使用的浏览器别名,值为解析结果中的名称(小写)
+var uaBrowserAliases = map[string]string{
+	"ie":                "internet explorer",
+	"explorer":          "internet explorer",
+	"ff":                "firefox",
+	"chromium":          "chromium",
+	"ios":               "ios",
+	"ios_saf":           "ios",
+	"ios safari":        "ios",
+	"and_chr":           "chrome",
+	"android":           "android",
+	"internet explorer": "internet explorer",
+}
+
+// uaQueryTerm 查询中的一项,如"Chrome >= 90"
+type uaQueryTerm struct {
+	not     bool
+	browser string
+	op      string
+	version Version
+	esr     bool
+}
+
+// UAQuery 类似browserslist的浏览器版本查询,多项之间为"或"关系,
+// 以"not"开头的项用于排除。
+type UAQuery struct {
+	terms []uaQueryTerm
+}
+
+// ParseUAQuery 解析查询语句,多项之间用","或" or "分隔,每项支持以下形式:
+//
+//	Chrome >= 90    比较版本,支持>=、>、<=、<、=
+//	Safari 14       指定版本,只比较给出的版本段,14.1也满足
+//	Edge            任意版本
+//	Firefox ESR     Firefox的ESR版本,见FirefoxESRVersions
+//	not IE <= 11    排除匹配的浏览器
+//
+// iOS(ios_saf)按iOS系统版本比较,与browserslist一致。
+func ParseUAQuery(query string) (*UAQuery, error) {
+	q := &UAQuery{}
+	for _, part := range strings.Split(strings.Replace(query, " or ", ",", -1), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		term, err := parseUAQueryTerm(part)
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, term)
+	}
+	if len(q.terms) == 0 {
+		return nil, ErrInvalidUAQuery
+	}
+	return q, nil
+}
+
+// MustParseUAQuery 与ParseUAQuery相同,查询格式错误时panic,用于初始化全局变量。
+func MustParseUAQuery(query string) *UAQuery {
+	q, err := ParseUAQuery(query)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func parseUAQueryTerm(s string) (term uaQueryTerm, err error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) > 0 && fields[0] == "not" {
+		term.not = true
+		fields = fields[1:]
+	}
+	// 浏览器名称可能包含空格,如"Internet Explorer",名称之后是运算符或版本号。
+	i := 0
+	for i < len(fields) && !isUAQueryOp(fields[i]) && ParseVersion(fields[i]).IsZero() && fields[i] != "esr" {
+		i++
+	}
+	if i == 0 {
+		return term, fmt.Errorf("%w: %q", ErrInvalidUAQuery, s)
+	}
+	term.browser = strings.Join(fields[:i], " ")
+	if alias, ok := uaBrowserAliases[term.browser]; ok {
+		term.browser = alias
+	}
+	rest := fields[i:]
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 1 && rest[0] == "esr":
+		if term.browser != "firefox" {
+			return term, fmt.Errorf("%w: ESR only applies to Firefox: %q", ErrInvalidUAQuery, s)
+		}
+		term.esr = true
+	case len(rest) == 1:
+		term.op = "="
+		term.version = ParseVersion(rest[0])
+	case len(rest) == 2 && isUAQueryOp(rest[0]):
+		term.op = rest[0]
+		term.version = ParseVersion(rest[1])
+	default:
+		return term, fmt.Errorf("%w: %q", ErrInvalidUAQuery, s)
+	}
+	if term.op != "" && term.version.IsZero() {
+		return term, fmt.Errorf("%w: bad version in %q", ErrInvalidUAQuery, s)
+	}
+	if term.op == "==" {
+		term.op = "="
+	}
+	return term, nil
+}
+
+func isUAQueryOp(s string) bool {
+	switch s {
+	case ">=", ">", "<=", "<", "=", "==":
+		return true
+	}
+	return false
+}
+
+// Match 判断ua是否满足查询:至少命中一项,且没有命中任何not项。
+func (q *UAQuery) Match(ua *UserAgent) bool {
+	matched := false
+	for _, t := range q.terms {
+		if !t.match(ua) {
+			continue
+		}
+		if t.not {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+func (t uaQueryTerm) match(ua *UserAgent) bool {
+	var v Version
+	switch t.browser {
+	case "ios":
+		if ua.platform != "iPhone" && ua.platform != "iPad" && ua.platform != "iPod" {
+			return false
+		}
+		v = ua.OSVersion()
+	default:
+		if !strings.EqualFold(ua.browser.Name, t.browser) {
+			return false
+		}
+		v = ua.BrowserVersion()
+	}
+	if t.esr {
+		for _, esr := range FirefoxESRVersions {
+			if v.Major() == esr {
+				return true
+			}
+		}
+		return false
+	}
+	if t.op == "" {
+		return true
+	}
+	if v.IsZero() {
+		return false
+	}
+	c := v.truncate(len(t.version.Segments)).Compare(t.version)
+	switch t.op {
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	case "<":
+		return c < 0
+	}
+	return c == 0
+}
+
+// Satisfies 判断是否满足查询语句,如ua.Satisfies("Chrome >= 90, Safari >= 14, Firefox ESR")。
+// 查询格式错误时返回false,需要区分错误时使用ParseUAQuery。
+func (p *UserAgent) Satisfies(query string) bool {
+	q, err := ParseUAQuery(query)
+	if err != nil {
+		return false
+	}
+	return q.Match(p)
+}
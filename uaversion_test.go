@@ -0,0 +1,84 @@
+package utils
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	cases := map[string]string{
+		"55.0.2883.75": "55.0.2883.75",
+		"10_15_7":      "10.15.7",
+		"9.0b2":        "9.0",
+		"rv:11":        "rv:11",
+		"":             "",
+	}
+	for raw, expected := range cases {
+		if got := ParseVersion(raw).String(); got != expected {
+			t.Errorf("ParseVersion(%q):\n Expect => %s\n Got => %s\n", raw, expected, got)
+		}
+	}
+	if ParseVersion("14.1").Compare(ParseVersion("14.0.3")) != 1 || ParseVersion("14").Compare(ParseVersion("14.0.0")) != 0 {
+		t.Errorf("Version.Compare:\n Expect => ordered\n Got => unordered\n")
+	}
+}
+
+const (
+	uaChrome89   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/89.0.4389.90 Safari/537.36"
+	uaChrome120  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	uaSafari13   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_6) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1.2 Safari/605.1.15"
+	uaSafari14   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1 Safari/605.1.15"
+	uaFirefox128 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	uaFirefox130 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:130.0) Gecko/20100101 Firefox/130.0"
+	uaEdge120    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91"
+	uaIPhone12   = "Mozilla/5.0 (iPhone; CPU iPhone OS 12_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1.2 Mobile/15E148 Safari/604.1"
+)
+
+func TestSatisfies(t *testing.T) {
+	modern := "Chrome >= 90, Safari >= 14, Firefox ESR"
+	cases := []struct {
+		ua       string
+		query    string
+		expected bool
+	}{
+		{uaChrome89, modern, false},
+		{uaChrome120, modern, true},
+		{uaSafari13, modern, false},
+		{uaSafari14, modern, true},
+		{uaFirefox128, modern, true},
+		{uaFirefox130, modern, false},
+		{uaSafari13, "Safari < 14", true},
+		{uaSafari14, "Safari < 14", false},
+		{uaSafari14, "Safari 14", true},
+		{uaSafari14, "safari = 14.0", false},
+		{uaSafari14, "Safari > 14", false},
+		{uaEdge120, "Edge >= 100", true},
+		{uaEdge120, "Chrome", false},
+		{uaChrome120, "Chrome or Edge, not Chrome < 100", true},
+		{uaChrome89, "Chrome or Edge, not Chrome < 100", false},
+		{uaIPhone12, "iOS >= 13", false},
+		{uaIPhone12, "ios_saf 12", true},
+		{uaChrome120, "Chrome >=", false},
+	}
+	for _, c := range cases {
+		if got := New(c.ua).Satisfies(c.query); got != c.expected {
+			t.Errorf("Satisfies(%q) for %q:\n Expect => %v\n Got => %v\n", c.query, c.ua, c.expected, got)
+		}
+	}
+}
+
+func TestParseUAQuery(t *testing.T) {
+	for _, q := range []string{"", ">= 90", "Chrome >= x", "Chrome ESR", "Chrome >= 90 91"} {
+		if _, err := ParseUAQuery(q); err == nil {
+			t.Errorf("ParseUAQuery(%q):\n Expect => error\n Got => %v\n", q, err)
+		}
+	}
+	if _, err := ParseUAQuery("Internet Explorer 11, not IE <= 10"); err != nil {
+		t.Errorf("ParseUAQuery:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+}
+
+func BenchmarkUAQueryMatch(b *testing.B) {
+	q := MustParseUAQuery("Chrome >= 90, Safari >= 14, Firefox ESR")
+	ua := New(uaSafari14)
+	for i := 0; i < b.N; i++ {
+		q.Match(ua)
+	}
+}